@@ -0,0 +1,299 @@
+// Package admin provides an http.Handler to inspect and close the live sessions
+// of an engine server.
+//
+//	GET    /sessions        lists the sessions, see Filter for the query parameters
+//	GET    /sessions/{sid}  returns a session
+//	DELETE /sessions/{sid}  force-closes a session
+//	GET    /debug           returns the enabled debug namespaces
+//	PUT    /debug           enables the debug namespaces of the body, see log.Enable
+//	DELETE /debug           disables the debug output
+//
+// The handler can be mounted under any prefix, only the trailing segments of the
+// path are considered.
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zishang520/engine.io/engine"
+	"github.com/zishang520/engine.io/errors"
+	"github.com/zishang520/engine.io/log"
+)
+
+var admin_log = log.NewLog("engine:admin")
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Authorizer decides whether a request may access the admin handler, a non-nil
+// error rejects it.
+type Authorizer func(*http.Request) error
+
+// Authorizes requests carrying the given bearer token.
+func BearerToken(token string) Authorizer {
+	return func(r *http.Request) error {
+		if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") && token != "" &&
+			subtle.ConstantTimeCompare([]byte(auth[len("Bearer "):]), []byte(token)) == 1 {
+			return nil
+		}
+		return errors.New("invalid bearer token").Err()
+	}
+}
+
+// Authorizes requests carrying the given basic auth credentials.
+func BasicAuth(username string, password string) Authorizer {
+	return func(r *http.Request) error {
+		if u, p, ok := r.BasicAuth(); ok && username != "" &&
+			subtle.ConstantTimeCompare([]byte(u), []byte(username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1 {
+			return nil
+		}
+		return errors.New("invalid credentials").Err()
+	}
+}
+
+// Session describes a live socket.
+type Session struct {
+	Id             string         `json:"id"`
+	Transport      string         `json:"transport"`
+	Protocol       int            `json:"protocol"`
+	RemoteAddress  string         `json:"remoteAddress"`
+	ReadyState     string         `json:"readyState"`
+	Upgraded       bool           `json:"upgraded"`
+	Upgrading      bool           `json:"upgrading"`
+	WriteBufferLen int            `json:"writeBufferLen"`
+	CreatedAt      time.Time      `json:"createdAt"`
+	Age            float64        `json:"age"` // seconds
+	Latency        engine.Latency `json:"latency"`
+}
+
+// Sessions is a page of sessions.
+type Sessions struct {
+	Total      int            `json:"total"`
+	Offset     int            `json:"offset"`
+	Limit      int            `json:"limit"`
+	Transports map[string]int `json:"transports"` // number of matching sessions by transport
+	Sessions   []*Session     `json:"sessions"`
+}
+
+// Filter selects sessions, it is read from the query parameters `transport`,
+// `protocol`, `readyState`, `upgraded`, `upgrading`, `remoteAddress` (prefix),
+// `minAge` (duration), `offset` and `limit` (at least 1, capped at MaxLimit).
+type Filter struct {
+	Transport     string
+	Protocol      int
+	ReadyState    string
+	Upgraded      *bool
+	Upgrading     *bool
+	RemoteAddress string
+	MinAge        time.Duration
+	Offset        int
+	Limit         int
+}
+
+func (f *Filter) match(s *Session) bool {
+	return (f.Transport == "" || s.Transport == f.Transport) &&
+		(f.Protocol == 0 || s.Protocol == f.Protocol) &&
+		(f.ReadyState == "" || s.ReadyState == f.ReadyState) &&
+		(f.Upgraded == nil || s.Upgraded == *f.Upgraded) &&
+		(f.Upgrading == nil || s.Upgrading == *f.Upgrading) &&
+		strings.HasPrefix(s.RemoteAddress, f.RemoteAddress) &&
+		(f.MinAge == 0 || time.Duration(s.Age*float64(time.Second)) >= f.MinAge)
+}
+
+type handler struct {
+	server    engine.Server
+	authorize Authorizer
+}
+
+// Returns the admin handler of server, every request is rejected when authorize is nil.
+func NewHandler(server engine.Server, authorize Authorizer) http.Handler {
+	return &handler{server: server, authorize: authorize}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.authorize == nil {
+		writeError(w, http.StatusForbidden, "no authorizer configured")
+		return
+	}
+	if err := h.authorize(r); err != nil {
+		admin_log.Debug("unauthorized admin request: %v", err)
+		writeError(w, http.StatusForbidden, err.Error())
+		return
+	}
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch l := len(segments); {
+	case l > 1 && segments[l-2] == "sessions":
+		// before /debug, a session id may be any string
+		h.serveSession(w, r, segments[l-1])
+	case segments[l-1] == "debug":
+		h.serveDebug(w, r)
+	case segments[l-1] == "sessions":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		filter, err := parseFilter(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, h.List(filter))
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *handler) serveSession(w http.ResponseWriter, r *http.Request, sid string) {
+	client, ok := h.server.Clients().Load(sid)
+	if !ok {
+		writeError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	socket := client.(engine.Socket)
+
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, describe(socket, h.server.Opts().Clock().Now()))
+	case http.MethodDelete:
+		admin_log.Debug(`force-closing session "%s"`, sid)
+		socket.Close(r.URL.Query().Get("discard") != "false")
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+// Debug describes the debug namespaces.
+type Debug struct {
+	Namespaces string `json:"namespaces"`
+}
+
+func (h *handler) serveDebug(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var debug Debug
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&debug); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		admin_log.Debug(`enabling debug namespaces "%s"`, debug.Namespaces)
+		log.Enable(debug.Namespaces)
+	case http.MethodDelete:
+		admin_log.Debug("disabling debug namespaces")
+		log.Disable()
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, &Debug{Namespaces: log.Namespaces()})
+}
+
+// Returns the page of sessions matching the filter, oldest first.
+func (h *handler) List(filter *Filter) *Sessions {
+	now := h.server.Opts().Clock().Now()
+	result := &Sessions{Offset: filter.Offset, Limit: filter.Limit, Transports: map[string]int{}, Sessions: []*Session{}}
+
+	matches := []*Session{}
+	h.server.Clients().Range(func(_, client any) bool {
+		if session := describe(client.(engine.Socket), now); filter.match(session) {
+			matches = append(matches, session)
+			result.Transports[session.Transport]++
+		}
+		return true
+	})
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].CreatedAt.Equal(matches[j].CreatedAt) {
+			return matches[i].Id < matches[j].Id
+		}
+		return matches[i].CreatedAt.Before(matches[j].CreatedAt)
+	})
+
+	result.Total = len(matches)
+	if filter.Offset < len(matches) {
+		matches = matches[filter.Offset:]
+		if len(matches) > filter.Limit {
+			matches = matches[:filter.Limit]
+		}
+		result.Sessions = matches
+	}
+	return result
+}
+
+func describe(socket engine.Socket, now time.Time) *Session {
+	return &Session{
+		Id:             socket.Id(),
+		Transport:      socket.Transport().Name(),
+		Protocol:       socket.Protocol(),
+		RemoteAddress:  socket.RemoteAddress(),
+		ReadyState:     socket.ReadyState(),
+		Upgraded:       socket.Upgraded(),
+		Upgrading:      socket.Upgrading(),
+		WriteBufferLen: socket.WriteBufferLen(),
+		CreatedAt:      socket.CreatedAt(),
+		Age:            now.Sub(socket.CreatedAt()).Seconds(),
+		Latency:        socket.Latency(),
+	}
+}
+
+func parseFilter(r *http.Request) (*Filter, error) {
+	query := r.URL.Query()
+	filter := &Filter{
+		Transport:     query.Get("transport"),
+		ReadyState:    query.Get("readyState"),
+		RemoteAddress: query.Get("remoteAddress"),
+		Limit:         DefaultLimit,
+	}
+
+	var err error
+	parseInt := func(name string, value *int) {
+		if v := query.Get(name); v != "" && err == nil {
+			if *value, err = strconv.Atoi(v); err == nil && (*value < 0 || name == "limit" && *value == 0) {
+				err = errors.New("invalid " + name).Err()
+			}
+		}
+	}
+	parseBool := func(name string, value **bool) {
+		if v := query.Get(name); v != "" && err == nil {
+			b, e := strconv.ParseBool(v)
+			*value, err = &b, e
+		}
+	}
+	parseInt("protocol", &filter.Protocol)
+	parseInt("offset", &filter.Offset)
+	parseInt("limit", &filter.Limit)
+	parseBool("upgraded", &filter.Upgraded)
+	parseBool("upgrading", &filter.Upgrading)
+	if v := query.Get("minAge"); v != "" && err == nil {
+		filter.MinAge, err = time.ParseDuration(v)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if filter.Limit > MaxLimit {
+		filter.Limit = MaxLimit
+	}
+	return filter, nil
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	writeJSON(w, statusCode, map[string]any{"code": statusCode, "message": message})
+}
@@ -0,0 +1,149 @@
+package admin
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/engine"
+	"github.com/zishang520/engine.io/log"
+)
+
+func handshake(t *testing.T, url string, eio string) string {
+	res, err := http.Get(url + "/engine.io/?EIO=" + eio + "&transport=polling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	body, _ := io.ReadAll(res.Body)
+	parts := strings.SplitN(string(body), `"sid":"`, 2)
+	if len(parts) != 2 {
+		t.Fatalf("handshake() = %s, want match for a sid", body)
+	}
+	return strings.SplitN(parts[1], `"`, 2)[0]
+}
+
+func request(t *testing.T, handler http.Handler, method string, target string, v any) int {
+	r := httptest.NewRequest(method, target, nil)
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if v != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), v); err != nil {
+			t.Fatalf("json.Unmarshal(%s) = %v", w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+func TestHandler(t *testing.T) {
+	opts := &config.ServerOptions{}
+	opts.SetAllowEIO3(true)
+	server := engine.New(opts)
+	defer server.Close()
+	mux := http.NewServeMux()
+	mux.Handle("/engine.io/", server)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	sid3 := handshake(t, ts.URL, "3")
+	time.Sleep(time.Millisecond)
+	sid4 := handshake(t, ts.URL, "4")
+
+	handler := NewHandler(server, BearerToken("secret"))
+
+	t.Run("Authorizer", func(t *testing.T) {
+		for name, h := range map[string]http.Handler{"nil": NewHandler(server, nil), "token": NewHandler(server, BearerToken("other"))} {
+			if code := request(t, h, "GET", "/admin/sessions", nil); code != http.StatusForbidden {
+				t.Fatalf("%s: ServeHTTP() = %d, want match for %d", name, code, http.StatusForbidden)
+			}
+		}
+		r := httptest.NewRequest("GET", "/sessions", nil)
+		r.SetBasicAuth("admin", "pass")
+		if err := BasicAuth("admin", "pass")(r); err != nil {
+			t.Fatalf("BasicAuth() = %v, want match for nil", err)
+		}
+		if err := BasicAuth("admin", "other")(r); err == nil {
+			t.Fatal("BasicAuth() should reject a wrong password")
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		var sessions Sessions
+		if code := request(t, handler, "GET", "/admin/sessions", &sessions); code != http.StatusOK {
+			t.Fatalf("ServeHTTP() = %d, want match for %d", code, http.StatusOK)
+		}
+		if sessions.Total != 2 || len(sessions.Sessions) != 2 || sessions.Transports["polling"] != 2 {
+			t.Fatalf("sessions = %+v, want match for 2 polling sessions", sessions)
+		}
+		if s := sessions.Sessions[0]; s.Id != sid3 || s.Protocol != 3 || s.ReadyState != "open" || s.Upgraded || s.RemoteAddress == "" {
+			t.Fatalf("sessions[0] = %+v, want match for %s", s, sid3)
+		}
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		var sessions Sessions
+		request(t, handler, "GET", "/admin/sessions?protocol=4&transport=polling", &sessions)
+		if sessions.Total != 1 || sessions.Sessions[0].Id != sid4 {
+			t.Fatalf("sessions = %+v, want match for %s", sessions, sid4)
+		}
+		request(t, handler, "GET", "/admin/sessions?offset=1&limit=1", &sessions)
+		if sessions.Total != 2 || len(sessions.Sessions) != 1 || sessions.Sessions[0].Id != sid4 {
+			t.Fatalf("sessions = %+v, want match for the second page", sessions)
+		}
+		request(t, handler, "GET", "/admin/sessions?transport=websocket", &sessions)
+		if sessions.Total != 0 || len(sessions.Sessions) != 0 {
+			t.Fatalf("sessions = %+v, want match for no session", sessions)
+		}
+		for _, query := range []string{"upgraded=maybe", "limit=0", "limit=-1"} {
+			if code := request(t, handler, "GET", "/admin/sessions?"+query, nil); code != http.StatusBadRequest {
+				t.Fatalf("%s: ServeHTTP() = %d, want match for %d", query, code, http.StatusBadRequest)
+			}
+		}
+		if request(t, handler, "GET", "/admin/sessions?limit=5000", &sessions); sessions.Limit != MaxLimit {
+			t.Fatalf("sessions.Limit = %d, want match for %d", sessions.Limit, MaxLimit)
+		}
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		var session Session
+		if code := request(t, handler, "GET", "/admin/sessions/"+sid4, &session); code != http.StatusOK || session.Id != sid4 {
+			t.Fatalf("ServeHTTP() = %d %+v, want match for %s", code, session, sid4)
+		}
+		if code := request(t, handler, "DELETE", "/admin/sessions/"+sid4, nil); code != http.StatusNoContent {
+			t.Fatalf("ServeHTTP() = %d, want match for %d", code, http.StatusNoContent)
+		}
+		// not the /debug route
+		if code := request(t, handler, "DELETE", "/admin/sessions/debug", nil); code != http.StatusNotFound {
+			t.Fatalf("ServeHTTP() = %d, want match for %d", code, http.StatusNotFound)
+		}
+		if _, ok := server.Clients().Load(sid4); ok {
+			t.Fatalf("session %s should be closed", sid4)
+		}
+		if code := request(t, handler, "DELETE", "/admin/sessions/"+sid4, nil); code != http.StatusNotFound {
+			t.Fatalf("ServeHTTP() = %d, want match for %d", code, http.StatusNotFound)
+		}
+	})
+
+	t.Run("Debug", func(t *testing.T) {
+		defer log.Disable()
+
+		r := httptest.NewRequest("PUT", "/admin/debug", strings.NewReader(`{"namespaces":"engine:*,-engine:polling"}`))
+		r.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != http.StatusOK || !log.Enabled("engine:socket") || log.Enabled("engine:polling") {
+			t.Fatalf("ServeHTTP() = %d, want match for the namespaces enabled", w.Code)
+		}
+
+		var debug Debug
+		if request(t, handler, "DELETE", "/admin/debug", &debug); debug.Namespaces != "" || log.Enabled("engine:socket") {
+			t.Fatalf("debug = %+v, want match for no namespace", debug)
+		}
+	})
+}
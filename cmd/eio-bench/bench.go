@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zishang520/engine.io/internal/eioclient"
+)
+
+var errClosedByServer = errors.New("closed by server")
+
+// Returns the code an error is reported under.
+func errorCode(err error) string {
+	var server *eioclient.ServerError
+	var protocol eioclient.ProtocolError
+	var closed *websocket.CloseError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &server) && server.Code < 0:
+		return fmt.Sprintf("HTTP %d", server.Status)
+	case errors.As(err, &server):
+		return fmt.Sprintf("%d %s", server.Code, server.Message)
+	case errors.As(err, &protocol):
+		return protocol.Error()
+	case errors.Is(err, errClosedByServer):
+		return err.Error()
+	case errors.As(err, &closed):
+		return fmt.Sprintf("websocket close %d", closed.Code)
+	case errors.As(err, &netErr) && netErr.Timeout(), errors.Is(err, context.DeadlineExceeded):
+		return "timeout"
+	case errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return "connection closed"
+	}
+	return "transport error"
+}
+
+// Opens the sessions of the configuration, runs them for its duration and
+// returns the report. The sessions stop early when ctx is done.
+func Run(ctx context.Context, cfg *Config) *Report {
+	rec := newRecorder()
+	client := &http.Client{Transport: &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        2 * cfg.Sessions,
+		MaxIdleConnsPerHost: 2 * cfg.Sessions,
+		IdleConnTimeout:     90 * time.Second,
+	}}
+	defer client.CloseIdleConnections()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.Sessions; i++ {
+		delay := time.Duration(0)
+		if cfg.Ramp > 0 {
+			delay = cfg.Ramp * time.Duration(i) / time.Duration(cfg.Sessions)
+		}
+		s := newSession(cfg, cfg.transport(i), client, rec)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return
+			}
+			s.run(ctx)
+		}()
+	}
+	wg.Wait()
+	return rec.get(time.Since(start))
+}
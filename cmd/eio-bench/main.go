@@ -0,0 +1,165 @@
+// Command eio-bench opens concurrent Engine.IO sessions against a server and
+// reports the handshake latency, the round-trip time of the messages, the
+// success rate of the upgrades and the errors by code:
+//
+//	eio-bench -url http://localhost:3000/engine.io/ -sessions 1000 -transports polling=1,upgrade=3 -rate 2 -size 256 -duration 1m
+//
+// The round-trip time is measured on the messages the server sends back, such
+// as an echo server does.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The transports of the sessions.
+const (
+	POLLING   = "polling"   // polling only
+	WEBSOCKET = "websocket" // a WebSocket from the handshake
+	UPGRADE   = "upgrade"   // polling upgraded to a WebSocket
+)
+
+// The configuration of a run.
+type Config struct {
+	URL      string        // the Engine.IO URL, such as http://localhost:3000/engine.io/
+	Sessions int           // the number of concurrent sessions
+	Mix      []Share       // the transports of the sessions
+	Protocol int           // the protocol revision, 3 or 4
+	Rate     float64       // the messages sent per second by every session
+	Size     int           // the size of the messages in bytes
+	Binary   bool          // whether the messages are binary
+	Duration time.Duration // how long the sessions run once opened
+	Ramp     time.Duration // how long the opening of the sessions is spread over
+	Timeout  time.Duration // how long a request or an upgrade may take
+}
+
+// The share of the sessions opened with a transport.
+type Share struct {
+	Transport string
+	Weight    int
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	cfg, asJSON, err := parseFlags(args, stderr)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	report := Run(ctx, cfg)
+	if asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(report)
+	} else {
+		report.Print(stdout)
+	}
+	if report.Opened == 0 {
+		return 1
+	}
+	return 0
+}
+
+func parseFlags(args []string, output io.Writer) (*Config, bool, error) {
+	cfg := &Config{}
+	var mix string
+	var asJSON bool
+
+	flags := flag.NewFlagSet("eio-bench", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&cfg.URL, "url", "http://localhost:3000/engine.io/", "the Engine.IO URL of the server")
+	flags.IntVar(&cfg.Sessions, "sessions", 100, "the number of concurrent sessions")
+	flags.StringVar(&mix, "transports", POLLING, `the transports of the sessions, "polling", "websocket" or "upgrade", weighted like "polling=1,upgrade=3"`)
+	flags.IntVar(&cfg.Protocol, "eio", 4, "the protocol revision, 3 or 4")
+	flags.Float64Var(&cfg.Rate, "rate", 1, "the messages sent per second by every session, 0 sends none")
+	flags.IntVar(&cfg.Size, "size", 64, "the size of the messages in bytes")
+	flags.BoolVar(&cfg.Binary, "binary", false, "send binary messages")
+	flags.DurationVar(&cfg.Duration, "duration", 30*time.Second, "how long the sessions run once opened")
+	flags.DurationVar(&cfg.Ramp, "ramp", 0, "how long the opening of the sessions is spread over")
+	flags.DurationVar(&cfg.Timeout, "timeout", 10*time.Second, "how long a request or an upgrade may take")
+	flags.BoolVar(&asJSON, "json", false, "print the report as JSON")
+	if err := flags.Parse(args); err != nil {
+		return nil, false, err
+	}
+
+	var err error
+	if cfg.Mix, err = parseMix(mix); err != nil {
+		return nil, false, err
+	}
+	if cfg.Sessions < 1 {
+		return nil, false, fmt.Errorf("-sessions must be positive, got %d", cfg.Sessions)
+	}
+	if cfg.Protocol != 3 && cfg.Protocol != 4 {
+		return nil, false, fmt.Errorf("-eio must be 3 or 4, got %d", cfg.Protocol)
+	}
+	if cfg.Rate < 0 || cfg.Size < 0 {
+		return nil, false, fmt.Errorf("-rate and -size must not be negative")
+	}
+	return cfg, asJSON, nil
+}
+
+// Parses the transports of the sessions, such as "websocket" or
+// "polling=1,upgrade=3".
+func parseMix(mix string) ([]Share, error) {
+	shares := []Share{}
+	for _, part := range strings.Split(mix, ",") {
+		transport, weight, weighted := strings.Cut(strings.TrimSpace(part), "=")
+		share := Share{Transport: transport, Weight: 1}
+		if weighted {
+			w, err := strconv.Atoi(weight)
+			if err != nil || w < 0 {
+				return nil, fmt.Errorf("invalid weight %q of the transport %q", weight, transport)
+			}
+			share.Weight = w
+		}
+		switch transport {
+		case POLLING, WEBSOCKET, UPGRADE:
+		default:
+			return nil, fmt.Errorf(`unknown transport %q, want "polling", "websocket" or "upgrade"`, transport)
+		}
+		if share.Weight > 0 {
+			shares = append(shares, share)
+		}
+	}
+	if len(shares) == 0 {
+		return nil, fmt.Errorf("no transport in %q", mix)
+	}
+	sort.SliceStable(shares, func(i, j int) bool { return shares[i].Weight > shares[j].Weight })
+	return shares, nil
+}
+
+// Returns the transport of the session i, the sessions are spread by weight.
+func (c *Config) transport(i int) string {
+	total := 0
+	for _, share := range c.Mix {
+		total += share.Weight
+	}
+	n := i % total
+	for _, share := range c.Mix {
+		if n < share.Weight {
+			return share.Transport
+		}
+		n -= share.Weight
+	}
+	return c.Mix[0].Transport
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/conformance"
+	"github.com/zishang520/engine.io/engine"
+)
+
+// Starts a server sending back the messages.
+func listen(t *testing.T, opts *config.ServerOptions) string {
+	server := conformance.EngineServer(opts)
+	srv := httptest.NewServer(server)
+	t.Cleanup(func() {
+		server.(engine.Server).Close()
+		srv.Close()
+	})
+	return srv.URL + "/engine.io/"
+}
+
+func TestRun(t *testing.T) {
+	for _, protocol := range []int{3, 4} {
+		for _, binary := range []bool{false, true} {
+			protocol, binary := protocol, binary
+			name := "v" + string(rune('0'+protocol))
+			if binary {
+				name += "/binary"
+			}
+			t.Run(name, func(t *testing.T) {
+				opts := config.DefaultServerOptions()
+				opts.SetAllowEIO3(true)
+				opts.SetPingInterval(200 * time.Millisecond)
+				opts.SetPingTimeout(200 * time.Millisecond)
+				mix, _ := parseMix("polling,websocket,upgrade")
+				cfg := &Config{
+					URL:      listen(t, opts),
+					Sessions: 6,
+					Mix:      mix,
+					Protocol: protocol,
+					Rate:     50,
+					Size:     32,
+					Binary:   binary,
+					Duration: 500 * time.Millisecond,
+					Timeout:  5 * time.Second,
+				}
+
+				report := Run(context.Background(), cfg)
+				if report.Opened != 6 {
+					t.Fatalf("Run() opened %d sessions, want match for %d", report.Opened, 6)
+				}
+				if want := map[string]int{POLLING: 2, WEBSOCKET: 2, UPGRADE: 2}; !reflect.DeepEqual(report.Sessions, want) {
+					t.Fatalf("Run() started %v, want match for %v", report.Sessions, want)
+				}
+				if report.Upgrades != (Upgrades{Attempted: 2, Succeeded: 2}) {
+					t.Fatalf("Run() upgraded %+v, want match for 2 of 2", report.Upgrades)
+				}
+				if report.Sent == 0 || report.RTT.Count == 0 || report.Received > report.Sent {
+					t.Fatalf("Run() sent %d messages and received %d with %d round trips", report.Sent, report.Received, report.RTT.Count)
+				}
+				if len(report.Errors) != 0 {
+					t.Fatalf("Run() failed with %v, want none", report.Errors)
+				}
+			})
+		}
+	}
+
+	t.Run("Errors", func(t *testing.T) {
+		// v3 is disabled by default
+		mix, _ := parseMix("polling")
+		cfg := &Config{URL: listen(t, config.DefaultServerOptions()), Sessions: 3, Mix: mix, Protocol: 3, Duration: time.Second, Timeout: 5 * time.Second}
+
+		report := Run(context.Background(), cfg)
+		if report.Opened != 0 {
+			t.Fatalf("Run() opened %d sessions, want match for %d", report.Opened, 0)
+		}
+		if want := map[string]int{"5 Unsupported protocol version": 3}; !reflect.DeepEqual(report.Errors, want) {
+			t.Fatalf("Run() failed with %v, want match for %v", report.Errors, want)
+		}
+	})
+}
+
+func TestParseMix(t *testing.T) {
+	mix, err := parseMix("polling=1, upgrade=3,websocket=0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Share{{UPGRADE, 3}, {POLLING, 1}}; !reflect.DeepEqual(mix, want) {
+		t.Fatalf("parseMix() = %v, want match for %v", mix, want)
+	}
+
+	cfg := &Config{Mix: mix}
+	counts := map[string]int{}
+	for i := 0; i < 8; i++ {
+		counts[cfg.transport(i)]++
+	}
+	if want := map[string]int{UPGRADE: 6, POLLING: 2}; !reflect.DeepEqual(counts, want) {
+		t.Fatalf("*Config.transport() spread %v, want match for %v", counts, want)
+	}
+
+	for _, mix := range []string{"tobi", "polling=x", "polling=0", ""} {
+		if _, err := parseMix(mix); err == nil {
+			t.Fatalf("parseMix(%q) = nil, want an error", mix)
+		}
+	}
+}
+
+func TestLatency(t *testing.T) {
+	samples := []time.Duration{}
+	for i := 100; i > 0; i-- {
+		samples = append(samples, time.Duration(i)*time.Millisecond)
+	}
+	want := Latency{Count: 100, Min: time.Millisecond, P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P99: 99 * time.Millisecond, Max: 100 * time.Millisecond}
+	if got := latencyOf(samples); got != want {
+		t.Fatalf("latencyOf() = %+v, want match for %+v", got, want)
+	}
+	if got := latencyOf(nil); got != (Latency{}) {
+		t.Fatalf("latencyOf(nil) = %+v, want match for %+v", got, Latency{})
+	}
+
+	report := &Report{Sessions: map[string]int{POLLING: 1}, Opened: 1, Handshake: want, Errors: map[string]int{"timeout": 2}}
+	out := new(strings.Builder)
+	report.Print(out)
+	for _, line := range []string{"sessions     1 (polling 1), 1 opened", "p99 99ms", "  timeout"} {
+		if !strings.Contains(out.String(), line) {
+			t.Fatalf("*Report.Print() printed\n%s\nwant a line with %q", out, line)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// The outcome of a run.
+type Report struct {
+	Duration  time.Duration  `json:"duration"`
+	Sessions  map[string]int `json:"sessions"` // the sessions started by transport
+	Opened    int            `json:"opened"`   // the sessions whose handshake succeeded
+	Handshake Latency        `json:"handshake"`
+	Sent      int            `json:"sent"`
+	Received  int            `json:"received"`
+	RTT       Latency        `json:"rtt"`
+	Upgrades  Upgrades       `json:"upgrades"`
+	Errors    map[string]int `json:"errors"` // by error code
+}
+
+// The distribution of a latency, in nanoseconds in JSON.
+type Latency struct {
+	Count int           `json:"count"`
+	Min   time.Duration `json:"min"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+type Upgrades struct {
+	Attempted int `json:"attempted"`
+	Succeeded int `json:"succeeded"`
+}
+
+// Collects the measurements of the sessions.
+type recorder struct {
+	report     Report
+	handshakes []time.Duration
+	rtts       []time.Duration
+
+	mu sync.Mutex
+}
+
+func newRecorder() *recorder {
+	return &recorder{report: Report{Sessions: map[string]int{}, Errors: map[string]int{}}}
+}
+
+func (r *recorder) started(transport string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.Sessions[transport]++
+}
+
+func (r *recorder) opened(latency time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.Opened++
+	r.handshakes = append(r.handshakes, latency)
+}
+
+func (r *recorder) sent() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.Sent++
+}
+
+// Records a received message, rtt is zero when the message was not sent by
+// the session.
+func (r *recorder) received(rtt time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.Received++
+	if rtt > 0 {
+		r.rtts = append(r.rtts, rtt)
+	}
+}
+
+func (r *recorder) upgraded(ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.Upgrades.Attempted++
+	if ok {
+		r.report.Upgrades.Succeeded++
+	}
+}
+
+func (r *recorder) error(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.report.Errors[errorCode(err)]++
+}
+
+// Returns the report of the measurements so far.
+func (r *recorder) get(duration time.Duration) *Report {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := r.report
+	report.Duration = duration
+	report.Sessions, report.Errors = copyCounts(r.report.Sessions), copyCounts(r.report.Errors)
+	report.Handshake = latencyOf(r.handshakes)
+	report.RTT = latencyOf(r.rtts)
+	return &report
+}
+
+func copyCounts(counts map[string]int) map[string]int {
+	c := make(map[string]int, len(counts))
+	for k, v := range counts {
+		c[k] = v
+	}
+	return c
+}
+
+// Returns the distribution of the samples, using the nearest rank.
+func latencyOf(samples []time.Duration) Latency {
+	if len(samples) == 0 {
+		return Latency{}
+	}
+	sorted := append([]time.Duration{}, samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	rank := func(p float64) time.Duration {
+		i := int(p*float64(len(sorted))+0.5) - 1
+		if i < 0 {
+			i = 0
+		}
+		if i >= len(sorted) {
+			i = len(sorted) - 1
+		}
+		return sorted[i]
+	}
+	return Latency{
+		Count: len(sorted),
+		Min:   sorted[0],
+		P50:   rank(0.50),
+		P90:   rank(0.90),
+		P99:   rank(0.99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+func (l Latency) String() string {
+	if l.Count == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("min %s\tp50 %s\tp90 %s\tp99 %s\tmax %s", round(l.Min), round(l.P50), round(l.P90), round(l.P99), round(l.Max))
+}
+
+func round(d time.Duration) time.Duration {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+// Prints the report as a table.
+func (r *Report) Print(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	defer tw.Flush()
+
+	total, transports := 0, []string{}
+	for _, transport := range []string{POLLING, WEBSOCKET, UPGRADE} {
+		if n := r.Sessions[transport]; n > 0 {
+			total += n
+			transports = append(transports, fmt.Sprintf("%s %d", transport, n))
+		}
+	}
+	fmt.Fprintf(tw, "duration\t%s\n", round(r.Duration))
+	fmt.Fprintf(tw, "sessions\t%d (%s), %d opened\n", total, strings.Join(transports, ", "), r.Opened)
+	fmt.Fprintf(tw, "handshake\t%s\n", r.Handshake)
+	fmt.Fprintf(tw, "messages\t%d sent, %d received\n", r.Sent, r.Received)
+	fmt.Fprintf(tw, "message rtt\t%s\n", r.RTT)
+	if r.Upgrades.Attempted > 0 {
+		fmt.Fprintf(tw, "upgrades\t%d/%d succeeded (%.1f%%)\n", r.Upgrades.Succeeded, r.Upgrades.Attempted, 100*float64(r.Upgrades.Succeeded)/float64(r.Upgrades.Attempted))
+	}
+	if len(r.Errors) == 0 {
+		fmt.Fprintf(tw, "errors\tnone\n")
+		return
+	}
+	codes := make([]string, 0, len(r.Errors))
+	for code := range r.Errors {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool {
+		if r.Errors[codes[i]] != r.Errors[codes[j]] {
+			return r.Errors[codes[i]] > r.Errors[codes[j]]
+		}
+		return codes[i] < codes[j]
+	})
+	fmt.Fprintf(tw, "errors\n")
+	for _, code := range codes {
+		fmt.Fprintf(tw, "  %s\t%d\n", code, r.Errors[code])
+	}
+}
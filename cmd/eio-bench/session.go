@@ -0,0 +1,361 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zishang520/engine.io/internal/eioclient"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// A session of the run, it answers the heartbeat, sends the messages at the
+// configured rate and measures the round-trip time of the messages sent back.
+// Every packet is written by a single goroutine, as the polling requests of a
+// session must not overlap.
+type session struct {
+	cfg       *Config
+	transport string
+	client    *eioclient.Session
+	rec       *recorder
+
+	out     chan *packet.Packet // the packets to write
+	done    chan struct{}       // closed when the session fails
+	endOnce sync.Once
+
+	seq  uint64
+	sent map[uint64]time.Time // the messages waiting to be sent back
+	mu   sync.Mutex
+}
+
+func newSession(cfg *Config, transport string, client *http.Client, rec *recorder) *session {
+	return &session{
+		cfg:       cfg,
+		transport: transport,
+		client:    eioclient.NewSession(&eioclient.Config{URL: cfg.URL, Protocol: cfg.Protocol, Timeout: cfg.Timeout, HTTP: client}),
+		rec:       rec,
+		out:       make(chan *packet.Packet, 64),
+		done:      make(chan struct{}),
+		sent:      map[uint64]time.Time{},
+	}
+}
+
+func (s *session) run(ctx context.Context) {
+	s.rec.started(s.transport)
+
+	var conn *websocket.Conn
+	var err error
+	start := time.Now()
+	if s.transport == WEBSOCKET {
+		conn, _, err = s.client.Open(ctx, "websocket")
+	} else {
+		err = s.openPolling(ctx)
+	}
+	if err != nil {
+		if ctx.Err() == nil {
+			s.rec.error(err)
+		}
+		return
+	}
+	s.rec.opened(time.Since(start))
+
+	if s.transport == UPGRADE {
+		// a failed upgrade goes on over polling
+		conn, err = s.upgrade(ctx)
+		s.rec.upgraded(err == nil)
+		if err != nil && ctx.Err() == nil {
+			s.rec.error(err)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.Duration)
+	defer cancel()
+
+	var readers, writers sync.WaitGroup
+	readers.Add(1)
+	writers.Add(2)
+	if conn != nil {
+		go func() { defer readers.Done(); s.readWebSocket(ctx, conn) }()
+		go func() { defer writers.Done(); s.writeWebSocket(ctx, conn) }()
+	} else {
+		go func() { defer readers.Done(); s.readPolling(ctx) }()
+		go func() { defer writers.Done(); s.writePolling(ctx) }()
+	}
+	go func() { defer writers.Done(); s.sendMessages(ctx) }()
+	if s.cfg.Protocol == 3 {
+		// the clients ping in v3
+		writers.Add(1)
+		go func() { defer writers.Done(); s.ping(ctx) }()
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-s.done:
+	}
+	cancel()
+	writers.Wait()
+
+	// the writers are gone, the close packet is the last write
+	closePacket := &packet.Packet{Type: packet.CLOSE}
+	if conn != nil {
+		s.client.WriteFrame(conn, closePacket)
+		conn.Close()
+	} else {
+		s.client.Post(context.Background(), []*packet.Packet{closePacket})
+	}
+	readers.Wait()
+}
+
+// Ends the session on an error.
+func (s *session) fail(err error) {
+	s.endOnce.Do(func() {
+		s.rec.error(err)
+		close(s.done)
+	})
+}
+
+// Queues a packet to write.
+func (s *session) send(ctx context.Context, p *packet.Packet) bool {
+	select {
+	case s.out <- p:
+		return true
+	case <-ctx.Done():
+	case <-s.done:
+	}
+	return false
+}
+
+// Handles a packet of the server.
+func (s *session) handle(ctx context.Context, p *packet.Packet) {
+	switch p.Type {
+	case packet.PING:
+		s.send(ctx, &packet.Packet{Type: packet.PONG})
+	case packet.MESSAGE:
+		s.rec.received(s.rtt(p))
+	case packet.CLOSE:
+		if ctx.Err() == nil {
+			s.fail(errClosedByServer)
+		}
+	}
+}
+
+// Sends the messages at the configured rate.
+func (s *session) sendMessages(ctx context.Context) {
+	if s.cfg.Rate <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / s.cfg.Rate))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		}
+		s.mu.Lock()
+		s.seq++
+		seq := s.seq
+		s.sent[seq] = time.Now()
+		s.mu.Unlock()
+		if !s.send(ctx, s.message(seq)) {
+			return
+		}
+		s.rec.sent()
+	}
+}
+
+// Pings the server every ping interval, in v3.
+func (s *session) ping(ctx context.Context) {
+	ticker := time.NewTicker(s.client.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if !s.send(ctx, &packet.Packet{Type: packet.PING}) {
+				return
+			}
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Returns the message of the sequence number, the number leads the data so
+// that the message sent back is matched.
+func (s *session) message(seq uint64) *packet.Packet {
+	if s.cfg.Binary {
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, seq)
+		if s.cfg.Size > len(data) {
+			data = append(data, make([]byte, s.cfg.Size-len(data))...)
+		}
+		return &packet.Packet{Type: packet.MESSAGE, Data: types.NewBytesBuffer(data)}
+	}
+	data := strconv.FormatUint(seq, 10) + " "
+	if s.cfg.Size > len(data) {
+		data += strings.Repeat("x", s.cfg.Size-len(data))
+	}
+	return &packet.Packet{Type: packet.MESSAGE, Data: types.NewStringBufferString(data)}
+}
+
+// Returns the round-trip time of a message sent back, or zero.
+func (s *session) rtt(p *packet.Packet) time.Duration {
+	if p.Data == nil {
+		return 0
+	}
+	data, err := io.ReadAll(p.Data)
+	if err != nil {
+		return 0
+	}
+	var seq uint64
+	if _, ok := p.Data.(*types.BytesBuffer); ok {
+		if len(data) < 8 {
+			return 0
+		}
+		seq = binary.BigEndian.Uint64(data)
+	} else {
+		head, _, _ := strings.Cut(string(data), " ")
+		if seq, err = strconv.ParseUint(head, 10, 64); err != nil {
+			return 0
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sentAt, ok := s.sent[seq]
+	if !ok {
+		return 0
+	}
+	delete(s.sent, seq)
+	return time.Since(sentAt)
+}
+
+// Opens the session over polling.
+func (s *session) openPolling(ctx context.Context) error {
+	_, packets, err := s.client.Open(ctx, "polling")
+	if err != nil {
+		return err
+	}
+	for _, p := range packets {
+		s.handle(ctx, p)
+	}
+	return nil
+}
+
+func (s *session) readPolling(ctx context.Context) {
+	for ctx.Err() == nil {
+		packets, err := s.client.Poll(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.fail(err)
+			}
+			return
+		}
+		for _, p := range packets {
+			s.handle(ctx, p)
+		}
+	}
+}
+
+// Writes the queued packets, those queued during a request are sent together.
+func (s *session) writePolling(ctx context.Context) {
+	for {
+		var packets []*packet.Packet
+		select {
+		case p := <-s.out:
+			packets = append(packets, p)
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		}
+	batch:
+		for {
+			select {
+			case p := <-s.out:
+				packets = append(packets, p)
+			default:
+				break batch
+			}
+		}
+		if err := s.client.Post(ctx, packets); err != nil {
+			if ctx.Err() == nil {
+				s.fail(err)
+			}
+			return
+		}
+	}
+}
+
+// Upgrades the polling session to a WebSocket, the pending poll is released
+// by the server once the probe is answered.
+func (s *session) upgrade(ctx context.Context) (*websocket.Conn, error) {
+	pending := make(chan struct{})
+	go func() {
+		defer close(pending)
+
+		packets, _ := s.client.Poll(ctx)
+		for _, p := range packets {
+			if p.Type != packet.NOOP && p.Type != packet.CLOSE {
+				s.handle(ctx, p)
+			}
+		}
+	}()
+	// the polling requests of the session must not overlap
+	defer func() { <-pending }()
+
+	conn, err := s.client.Probe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.client.WriteFrame(conn, &packet.Packet{Type: packet.UPGRADE}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func (s *session) readWebSocket(ctx context.Context, conn *websocket.Conn) {
+	for {
+		p, err := s.client.ReadFrame(conn)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.fail(err)
+			}
+			return
+		}
+		s.handle(ctx, p)
+	}
+}
+
+func (s *session) writeWebSocket(ctx context.Context, conn *websocket.Conn) {
+	for {
+		select {
+		case p := <-s.out:
+			if err := s.client.WriteFrame(conn, p); err != nil {
+				if ctx.Err() == nil {
+					s.fail(err)
+				}
+				return
+			}
+		case <-ctx.Done():
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zishang520/engine.io/internal/eioclient"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// The configuration of a connection.
+type Config struct {
+	URL       string      // the Engine.IO URL, such as http://localhost:3000/engine.io/
+	Transport string      // the transport of the handshake, "polling" or "websocket"
+	Protocol  int         // the protocol revision, 3 or 4
+	Base64    bool        // whether the binary packets are sent as base64
+	Query     url.Values  // appended to every request
+	Header    http.Header // sent with every request
+	Upgrade   bool        // whether a polling session upgrades once open
+	Miss      int         // the heartbeats to miss, -1 misses them all
+	Timeout   time.Duration
+}
+
+var errClosedByServer = errors.New("session closed by the server")
+
+// Client is an Engine.IO client printing every packet it sends and receives,
+// it answers the heartbeat unless told to miss it. Every packet is written by
+// a single goroutine so that the polling requests of the session do not
+// overlap.
+type Client struct {
+	cfg     *Config
+	session *eioclient.Session
+	out     io.Writer
+	start   time.Time
+	muout   sync.Mutex
+
+	ws        *websocket.Conn // the WebSocket of the session, once open
+	muws      sync.RWMutex
+	muupgrade sync.Mutex // held while upgrading, the polls and writes wait for it
+
+	miss   int64 // the heartbeats to miss
+	writes chan *packet.Packet
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+	endOnce sync.Once
+	err     error
+}
+
+// Creates a client printing to out.
+func NewClient(cfg *Config, out io.Writer) *Client {
+	ctx, cancel := context.WithCancel(context.Background())
+	c := &Client{
+		cfg:    cfg,
+		out:    out,
+		miss:   int64(cfg.Miss),
+		writes: make(chan *packet.Packet, 64),
+		ctx:    ctx,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	c.session = eioclient.NewSession(&eioclient.Config{
+		URL:      cfg.URL,
+		Protocol: cfg.Protocol,
+		Base64:   cfg.Base64,
+		Query:    cfg.Query,
+		Header:   cfg.Header,
+		Timeout:  cfg.Timeout,
+		OnPacket: func(transport, direction string, p *packet.Packet) {
+			c.print(transport, direction, p)
+		},
+		OnResponse: func(method string, res *http.Response, size int, elapsed time.Duration) {
+			c.printf("polling %s %d %s, %d bytes in %s", method, res.StatusCode, res.Header.Get("Content-Type"), size, elapsed.Round(time.Microsecond))
+		},
+	})
+	return c
+}
+
+// Opens the session.
+func (c *Client) Connect() error {
+	c.start = time.Now()
+	c.printf("connecting to %s over %s, EIO=%d", c.cfg.URL, c.cfg.Transport, c.cfg.Protocol)
+
+	conn, packets, err := c.session.Open(c.ctx, c.cfg.Transport)
+	if err != nil {
+		return err
+	}
+	for _, p := range packets {
+		c.received(p)
+	}
+	if conn != nil {
+		c.muws.Lock()
+		c.ws = conn
+		c.muws.Unlock()
+		go c.readWebSocket(conn)
+	} else {
+		go c.poll()
+	}
+	go c.write()
+	if c.cfg.Protocol == 3 {
+		// the clients ping in v3
+		go c.ping()
+	}
+	if c.cfg.Upgrade && c.cfg.Transport != "websocket" {
+		go func() {
+			if err := c.Upgrade(); err != nil {
+				c.printf("upgrade failed: %v", err)
+			}
+		}()
+	}
+	return nil
+}
+
+// Returns a channel closed when the session ends.
+func (c *Client) Done() <-chan struct{} {
+	return c.done
+}
+
+// Returns why the session ended, nil when closed by the client.
+func (c *Client) Err() error {
+	<-c.done
+	return c.err
+}
+
+// Queues a packet to send.
+func (c *Client) Send(p *packet.Packet) {
+	select {
+	case c.writes <- p:
+	case <-c.done:
+	}
+}
+
+// Misses the next n heartbeats: the pongs in v4, the pings in v3. A negative n
+// misses them all, zero answers them again.
+func (c *Client) Miss(n int) {
+	atomic.StoreInt64(&c.miss, int64(n))
+}
+
+// Sends a close packet and ends the session once sent.
+func (c *Client) Close() {
+	c.Send(&packet.Packet{Type: packet.CLOSE})
+	select {
+	case <-c.done:
+	case <-time.After(c.cfg.Timeout):
+		c.end(nil)
+	}
+}
+
+// Upgrades the polling session to a WebSocket, the pending poll is released
+// by the server once the probe is answered.
+func (c *Client) Upgrade() error {
+	c.muupgrade.Lock()
+	defer c.muupgrade.Unlock()
+
+	if c.websocket() != nil {
+		return errors.New("the session already runs over a WebSocket")
+	}
+	conn, err := c.session.Probe(c.ctx)
+	if err != nil {
+		return err
+	}
+	if err := c.session.WriteFrame(conn, &packet.Packet{Type: packet.UPGRADE}); err != nil {
+		conn.Close()
+		return err
+	}
+
+	c.muws.Lock()
+	c.ws = conn
+	c.muws.Unlock()
+	c.printf("upgraded to websocket")
+	go c.readWebSocket(conn)
+	return nil
+}
+
+// Ends the session.
+func (c *Client) end(err error) {
+	c.endOnce.Do(func() {
+		c.err = err
+		close(c.done)
+		c.cancel()
+		if ws := c.websocket(); ws != nil {
+			ws.Close()
+		}
+	})
+}
+
+func (c *Client) websocket() *websocket.Conn {
+	c.muws.RLock()
+	defer c.muws.RUnlock()
+
+	return c.ws
+}
+
+// Returns whether to miss the current heartbeat.
+func (c *Client) missing() bool {
+	for {
+		n := atomic.LoadInt64(&c.miss)
+		if n == 0 {
+			return false
+		}
+		if n < 0 || atomic.CompareAndSwapInt64(&c.miss, n, n-1) {
+			return true
+		}
+	}
+}
+
+// Handles a packet of the server, printed once received.
+func (c *Client) received(p *packet.Packet) {
+	switch p.Type {
+	case packet.PING:
+		if c.missing() {
+			c.printf("pong missed")
+			return
+		}
+		pong := &packet.Packet{Type: packet.PONG}
+		if data, _ := readData(p); len(data) > 0 {
+			pong.Data = types.NewStringBuffer(data)
+		}
+		c.Send(pong)
+	case packet.CLOSE:
+		c.end(errClosedByServer)
+	}
+}
+
+// Pings the server every ping interval, in v3.
+func (c *Client) ping() {
+	ticker := time.NewTicker(c.session.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if c.missing() {
+				c.printf("ping missed")
+				continue
+			}
+			c.Send(&packet.Packet{Type: packet.PING})
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// Writes the queued packets, over the WebSocket once upgraded.
+func (c *Client) write() {
+	for {
+		var packets []*packet.Packet
+		select {
+		case p := <-c.writes:
+			packets = append(packets, p)
+		case <-c.done:
+			return
+		}
+
+		// waits for an upgrade in progress, nothing is posted once the upgrade
+		// packet is written
+		c.muupgrade.Lock()
+		var err error
+		if ws := c.websocket(); ws != nil {
+			for _, p := range packets {
+				if err = c.session.WriteFrame(ws, p); err != nil {
+					break
+				}
+			}
+		} else {
+		batch:
+			for {
+				select {
+				case p := <-c.writes:
+					packets = append(packets, p)
+				default:
+					break batch
+				}
+			}
+			err = c.session.Post(c.ctx, packets)
+		}
+		c.muupgrade.Unlock()
+		if err != nil {
+			c.end(err)
+			return
+		}
+		for _, p := range packets {
+			if p.Type == packet.CLOSE {
+				c.end(nil)
+				return
+			}
+		}
+	}
+}
+
+// Polls the server until the session ends or is upgraded.
+func (c *Client) poll() {
+	for {
+		// waits for an upgrade in progress
+		c.muupgrade.Lock()
+		upgraded := c.websocket() != nil
+		c.muupgrade.Unlock()
+		if upgraded {
+			return
+		}
+
+		packets, err := c.session.Poll(c.ctx)
+		if err != nil {
+			select {
+			case <-c.done:
+			default:
+				c.end(err)
+			}
+			return
+		}
+		for _, p := range packets {
+			if p.Type == packet.CLOSE && c.websocket() != nil {
+				// the server closes the polling transport once upgraded
+				continue
+			}
+			c.received(p)
+		}
+	}
+}
+
+func (c *Client) readWebSocket(conn *websocket.Conn) {
+	for {
+		p, err := c.session.ReadFrame(conn)
+		if err != nil {
+			select {
+			case <-c.done:
+			default:
+				c.end(err)
+			}
+			return
+		}
+		c.received(p)
+	}
+}
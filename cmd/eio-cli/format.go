@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// The bytes of binary data shown in full, the longer data is cut.
+const maxHexBytes = 64
+
+// Prints a line, prefixed by the time elapsed since the connection.
+func (c *Client) printf(format string, args ...any) {
+	c.muout.Lock()
+	defer c.muout.Unlock()
+
+	fmt.Fprintf(c.out, "%10.3fs  %s\n", time.Since(c.start).Seconds(), fmt.Sprintf(format, args...))
+}
+
+// Prints a packet sent (">") or received ("<") over transport and returns its
+// data, the data of the packet is put back for its next reader.
+func (c *Client) print(transport, direction string, p *packet.Packet) []byte {
+	data, binary := readData(p)
+	c.printf("%-9s %s %s", transport, direction, formatPacket(p.Type, data, binary))
+	return data
+}
+
+// Reads the data of a packet and puts it back.
+func readData(p *packet.Packet) ([]byte, bool) {
+	if p.Data == nil {
+		return nil, false
+	}
+	_, binary := p.Data.(*types.BytesBuffer)
+	data, _ := io.ReadAll(p.Data)
+	if binary {
+		p.Data = types.NewBytesBuffer(data)
+	} else {
+		p.Data = types.NewStringBuffer(data)
+	}
+	return data, binary
+}
+
+// Formats a packet as its type followed by its data, quoted when text and in
+// hex when binary.
+func formatPacket(typ packet.Type, data []byte, binary bool) string {
+	switch {
+	case binary:
+		shown := data
+		if len(shown) > maxHexBytes {
+			shown = shown[:maxHexBytes]
+		}
+		s := fmt.Sprintf("%s binary %d bytes %s", typ, len(data), hex.EncodeToString(shown))
+		if len(shown) < len(data) {
+			s += "…"
+		}
+		return s
+	case len(data) > 0:
+		return fmt.Sprintf("%s %s", typ, strconv.Quote(string(data)))
+	}
+	return string(typ)
+}
@@ -0,0 +1,219 @@
+// Command eio-cli connects to an Engine.IO server and prints every packet sent
+// and received with its timing, the lines of stdin are sent as messages:
+//
+//	eio-cli -url http://localhost:3000/engine.io/ -transport polling -eio 4 -header "Authorization: Bearer token"
+//
+// The lines starting with "/" are commands, such as "/upgrade" to upgrade to
+// a WebSocket or "/miss 2" to miss the next two heartbeats, see "/help".
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+const help = `commands:
+  <text>          send a text message, "//text" sends "/text"
+  /bin <hex>      send a binary message, such as "/bin 01 02 ff"
+  /raw <packet>   send an encoded packet, such as "/raw 2probe"
+  /ping [data]    send a ping packet
+  /upgrade        upgrade the polling session to a WebSocket
+  /miss [n|all]   miss the next n heartbeats (1 by default), 0 answers them again
+  /close          close the session and quit
+  /help           print this help`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	cfg, err := parseFlags(args, stderr)
+	if err == flag.ErrHelp {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	client := NewClient(cfg, stdout)
+	if err := client.Connect(); err != nil {
+		fmt.Fprintf(stderr, "connecting failed: %v\n", err)
+		return 1
+	}
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+
+		scanner := bufio.NewScanner(stdin)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-client.Done():
+				return
+			}
+		}
+	}()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+loop:
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				// stdin is closed
+				client.Close()
+				break loop
+			}
+			if err := execute(client, line, stdout); err != nil {
+				client.printf("%v", err)
+			}
+		case <-interrupt:
+			client.Close()
+			break loop
+		case <-client.Done():
+			break loop
+		}
+	}
+
+	if err := client.Err(); err != nil {
+		client.printf("session ended: %v", err)
+		return 1
+	}
+	client.printf("session closed")
+	return 0
+}
+
+// Runs a line of stdin.
+func execute(c *Client, line string, stdout io.Writer) error {
+	if !strings.HasPrefix(line, "/") || strings.HasPrefix(line, "//") {
+		c.Send(&packet.Packet{Type: packet.MESSAGE, Data: types.NewStringBufferString(strings.TrimPrefix(line, "/"))})
+		return nil
+	}
+
+	command, arg, _ := strings.Cut(line[1:], " ")
+	arg = strings.TrimSpace(arg)
+	switch command {
+	case "bin", "binary":
+		data, err := hex.DecodeString(strings.ReplaceAll(arg, " ", ""))
+		if err != nil {
+			return fmt.Errorf("invalid hex data: %v", err)
+		}
+		c.Send(&packet.Packet{Type: packet.MESSAGE, Data: types.NewBytesBuffer(data)})
+	case "raw":
+		p, err := c.session.Parser().DecodePacket(types.NewStringBufferString(arg))
+		if err != nil {
+			return fmt.Errorf("invalid packet: %v", err)
+		}
+		c.Send(p)
+	case "ping":
+		p := &packet.Packet{Type: packet.PING}
+		if arg != "" {
+			p.Data = types.NewStringBufferString(arg)
+		}
+		c.Send(p)
+	case "upgrade":
+		if err := c.Upgrade(); err != nil {
+			return fmt.Errorf("upgrade failed: %v", err)
+		}
+	case "miss":
+		n := 1
+		if arg == "all" {
+			n = -1
+		} else if arg != "" {
+			var err error
+			if n, err = strconv.Atoi(arg); err != nil {
+				return fmt.Errorf("invalid count %q", arg)
+			}
+		}
+		c.Miss(n)
+	case "close":
+		c.Close()
+	case "help":
+		fmt.Fprintln(stdout, help)
+	default:
+		return fmt.Errorf(`unknown command "/%s", see "/help"`, command)
+	}
+	return nil
+}
+
+// The values of a repeated -query flag, as name=value.
+type queryFlag url.Values
+
+func (q queryFlag) String() string {
+	return url.Values(q).Encode()
+}
+
+func (q queryFlag) Set(value string) error {
+	name, v, _ := strings.Cut(value, "=")
+	if name == "" {
+		return errors.New("want name=value")
+	}
+	url.Values(q).Add(name, v)
+	return nil
+}
+
+// The values of a repeated -header flag, as "Name: value".
+type headerFlag http.Header
+
+func (h headerFlag) String() string {
+	return fmt.Sprint(http.Header(h))
+}
+
+func (h headerFlag) Set(value string) error {
+	name, v, ok := strings.Cut(value, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return errors.New(`want "Name: value"`)
+	}
+	http.Header(h).Add(strings.TrimSpace(name), strings.TrimSpace(v))
+	return nil
+}
+
+func parseFlags(args []string, output io.Writer) (*Config, error) {
+	cfg := &Config{Query: url.Values{}, Header: http.Header{}}
+
+	flags := flag.NewFlagSet("eio-cli", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&cfg.URL, "url", "http://localhost:3000/engine.io/", "the Engine.IO URL of the server")
+	flags.StringVar(&cfg.Transport, "transport", "polling", `the transport of the handshake, "polling" or "websocket"`)
+	flags.IntVar(&cfg.Protocol, "eio", 4, "the protocol revision, 3 or 4")
+	flags.BoolVar(&cfg.Base64, "b64", false, "ask for the binary packets as base64")
+	flags.Var(queryFlag(cfg.Query), "query", "a query parameter as name=value, repeatable")
+	flags.Var(headerFlag(cfg.Header), "header", `a request header as "Name: value", repeatable`)
+	flags.BoolVar(&cfg.Upgrade, "upgrade", false, "upgrade the polling session to a WebSocket once open")
+	flags.IntVar(&cfg.Miss, "miss", 0, "the heartbeats to miss: the pongs in v4, the pings in v3; -1 misses them all")
+	flags.DurationVar(&cfg.Timeout, "timeout", 10*time.Second, "how long a request or an upgrade may take")
+	flags.Usage = func() {
+		fmt.Fprintf(output, "usage: eio-cli [flags]\n\nflags:\n")
+		flags.PrintDefaults()
+		fmt.Fprintf(output, "\n%s\n", help)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if cfg.Transport != "polling" && cfg.Transport != "websocket" {
+		return nil, fmt.Errorf(`-transport must be "polling" or "websocket", got %q`, cfg.Transport)
+	}
+	if cfg.Protocol != 3 && cfg.Protocol != 4 {
+		return nil, fmt.Errorf("-eio must be 3 or 4, got %d", cfg.Protocol)
+	}
+	return cfg, nil
+}
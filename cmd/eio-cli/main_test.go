@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/conformance"
+	"github.com/zishang520/engine.io/engine"
+)
+
+// The output of the command, written and read concurrently.
+type output struct {
+	buf bytes.Buffer
+	mu  sync.Mutex
+}
+
+func (o *output) Write(p []byte) (int, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.buf.Write(p)
+}
+
+func (o *output) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.buf.String()
+}
+
+// Waits for a line holding s.
+func (o *output) expect(t *testing.T, s string) {
+	t.Helper()
+
+	for deadline := time.Now().Add(5 * time.Second); !strings.Contains(o.String(), s); time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatalf("output\n%s\nwant a line with %q", o, s)
+		}
+	}
+}
+
+// Starts a server sending back the messages, the headers of its requests are
+// sent to headers.
+func start(t *testing.T, opts *config.ServerOptions, headers chan<- http.Header) string {
+	server := conformance.EngineServer(opts)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case headers <- r.Header.Clone():
+		default:
+		}
+		server.ServeHTTP(w, r)
+	}))
+	t.Cleanup(func() {
+		server.(engine.Server).Close()
+		srv.Close()
+	})
+	return srv.URL + "/engine.io/"
+}
+
+// Runs the command, the lines written to stdin are executed.
+func command(t *testing.T, args ...string) (*io.PipeWriter, *output, chan int) {
+	stdin, writer := io.Pipe()
+	out := &output{}
+	code := make(chan int, 1)
+	go func() {
+		code <- run(args, stdin, out, out)
+	}()
+	t.Cleanup(func() { writer.Close() })
+	return writer, out, code
+}
+
+func exitCode(t *testing.T, out *output, code chan int) int {
+	t.Helper()
+
+	select {
+	case c := <-code:
+		return c
+	case <-time.After(5 * time.Second):
+		t.Fatalf("command did not exit, output\n%s", out)
+	}
+	return 0
+}
+
+func TestRun(t *testing.T) {
+	t.Run("Polling", func(t *testing.T) {
+		headers := make(chan http.Header, 1)
+		url := start(t, config.DefaultServerOptions(), headers)
+		stdin, out, code := command(t, "-url", url, "-header", "X-Token: secret", "-query", "room=lobby")
+
+		out.expect(t, `polling   < open "{`)
+		out.expect(t, `\"sid\":`)
+		if h := <-headers; h.Get("X-Token") != "secret" {
+			t.Fatalf("request headers %v, want match for X-Token: secret", h)
+		}
+		io.WriteString(stdin, "hello\n/bin 01 02 ff\n")
+		out.expect(t, `polling   < message "hello"`)
+		out.expect(t, `polling   < message binary 3 bytes 0102ff`)
+
+		io.WriteString(stdin, "/upgrade\n")
+		out.expect(t, "upgraded to websocket")
+		io.WriteString(stdin, "//after\n")
+		out.expect(t, `websocket > message "/after"`)
+		out.expect(t, `websocket < message "/after"`)
+
+		io.WriteString(stdin, "/close\n")
+		if c := exitCode(t, out, code); c != 0 {
+			t.Fatalf("run() = %d, want match for %d, output\n%s", c, 0, out)
+		}
+	})
+
+	t.Run("WebSocketV3", func(t *testing.T) {
+		opts := config.DefaultServerOptions()
+		opts.SetAllowEIO3(true)
+		stdin, out, code := command(t, "-url", start(t, opts, nil), "-transport", "websocket", "-eio", "3", "-b64")
+
+		out.expect(t, `websocket < open`)
+		io.WriteString(stdin, "/bin 0102\n")
+		out.expect(t, `websocket < message binary 2 bytes 0102`)
+
+		// stdin closed
+		stdin.Close()
+		if c := exitCode(t, out, code); c != 0 {
+			t.Fatalf("run() = %d, want match for %d, output\n%s", c, 0, out)
+		}
+	})
+
+	t.Run("Upgrade", func(t *testing.T) {
+		stdin, out, code := command(t, "-url", start(t, config.DefaultServerOptions(), nil), "-upgrade")
+
+		out.expect(t, "upgraded to websocket")
+		io.WriteString(stdin, "/upgrade\n")
+		out.expect(t, "upgrade failed: the session already runs over a WebSocket")
+		stdin.Close()
+		exitCode(t, out, code)
+	})
+
+	t.Run("MissedPongs", func(t *testing.T) {
+		opts := config.DefaultServerOptions()
+		opts.SetPingInterval(50 * time.Millisecond)
+		opts.SetPingTimeout(50 * time.Millisecond)
+		_, out, code := command(t, "-url", start(t, opts, nil), "-miss", "-1")
+
+		out.expect(t, "pong missed")
+		if c := exitCode(t, out, code); c != 1 {
+			t.Fatalf("run() = %d, want match for %d, output\n%s", c, 1, out)
+		}
+		out.expect(t, "session ended")
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		// v3 is disabled by default
+		_, out, code := command(t, "-url", start(t, config.DefaultServerOptions(), nil), "-eio", "3")
+
+		if c := exitCode(t, out, code); c != 1 {
+			t.Fatalf("run() = %d, want match for %d, output\n%s", c, 1, out)
+		}
+		out.expect(t, `HTTP 400, code 5 "Unsupported protocol version"`)
+	})
+
+	t.Run("Flags", func(t *testing.T) {
+		for _, args := range [][]string{{"-transport", "tobi"}, {"-eio", "5"}, {"-header", "nocolon"}, {"-query", "=x"}} {
+			if c := run(args, strings.NewReader(""), io.Discard, io.Discard); c != 2 {
+				t.Fatalf("run(%q) = %d, want match for %d", args, c, 2)
+			}
+		}
+	})
+}
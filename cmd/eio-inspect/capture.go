@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Exchange is a request of a capture and its response.
+type Exchange struct {
+	Index    int       // in the capture, from 1
+	Start    time.Time // zero when the capture is not timed
+	Duration time.Duration
+	Method   string
+	URL      *url.URL
+
+	RequestType string
+	RequestBody []byte
+
+	Status       int // 0 when no response was captured
+	ResponseType string
+	ResponseBody []byte
+
+	Frames []*Frame // of a WebSocket
+}
+
+// Returns whether the exchange is timed.
+func (e *Exchange) Timed() bool {
+	return !e.Start.IsZero()
+}
+
+// Returns when the exchange ended.
+func (e *Exchange) End() time.Time {
+	return e.Start.Add(e.Duration)
+}
+
+// Frame is a message of a WebSocket.
+type Frame struct {
+	Time   time.Time
+	Sent   bool // by the client
+	Binary bool
+	Data   []byte
+}
+
+// Reads the exchanges of a HAR file, or else of a raw dump.
+func readCapture(data []byte) ([]*Exchange, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return readHAR(trimmed)
+	}
+	return readDump(data)
+}
+
+// The fields of a HAR file used, the WebSocket messages are exported by the
+// browsers as "_webSocketMessages".
+type har struct {
+	Log struct {
+		Entries []struct {
+			StartedDateTime time.Time `json:"startedDateTime"`
+			Time            float64   `json:"time"` // ms
+			Request         struct {
+				Method   string `json:"method"`
+				URL      string `json:"url"`
+				PostData *struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"postData"`
+			} `json:"request"`
+			Response struct {
+				Status  int `json:"status"`
+				Content struct {
+					MimeType string `json:"mimeType"`
+					Text     string `json:"text"`
+					Encoding string `json:"encoding"`
+				} `json:"content"`
+			} `json:"response"`
+			WebSocketMessages []struct {
+				Type   string  `json:"type"` // "send" or "receive"
+				Time   float64 `json:"time"` // s since the epoch
+				Opcode int     `json:"opcode"`
+				Data   string  `json:"data"` // base64 when binary
+			} `json:"_webSocketMessages"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+func readHAR(data []byte) ([]*Exchange, error) {
+	var h har
+	if err := json.Unmarshal(data, &h); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %w", err)
+	}
+
+	exchanges := []*Exchange{}
+	for i, entry := range h.Log.Entries {
+		u, err := url.Parse(entry.Request.URL)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		e := &Exchange{
+			Index:        i + 1,
+			Start:        entry.StartedDateTime,
+			Duration:     time.Duration(entry.Time * float64(time.Millisecond)),
+			Method:       entry.Request.Method,
+			URL:          u,
+			Status:       entry.Response.Status,
+			ResponseType: entry.Response.Content.MimeType,
+		}
+		if e.ResponseBody, err = harText(entry.Response.Content.Text, entry.Response.Content.Encoding); err != nil {
+			return nil, fmt.Errorf("entry %d: %w", i+1, err)
+		}
+		if postData := entry.Request.PostData; postData != nil {
+			e.RequestType = postData.MimeType
+			if e.RequestBody, err = harText(postData.Text, postData.Encoding); err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i+1, err)
+			}
+		}
+		for _, m := range entry.WebSocketMessages {
+			f := &Frame{Sent: m.Type == "send", Binary: m.Opcode == 2, Data: []byte(m.Data)}
+			if m.Time > 0 {
+				sec, frac := math.Modf(m.Time)
+				f.Time = time.Unix(int64(sec), int64(frac*1e9))
+			}
+			if f.Binary {
+				if f.Data, err = base64.StdEncoding.DecodeString(m.Data); err != nil {
+					return nil, fmt.Errorf("entry %d: invalid binary frame: %w", i+1, err)
+				}
+			}
+			e.Frames = append(e.Frames, f)
+		}
+		exchanges = append(exchanges, e)
+	}
+	return exchanges, nil
+}
+
+func harText(text string, encoding string) ([]byte, error) {
+	if encoding == "base64" {
+		return base64.StdEncoding.DecodeString(text)
+	}
+	return []byte(text), nil
+}
+
+// Reads a raw dump, made of requests each followed by its response as sent
+// over HTTP/1.1. A dump is not timed.
+func readDump(data []byte) ([]*Exchange, error) {
+	r := bufio.NewReader(bytes.NewReader(data))
+	exchanges := []*Exchange{}
+	for i := 1; ; i++ {
+		// the blank lines between the messages
+		for {
+			b, err := r.Peek(1)
+			if err != nil || (b[0] != '\r' && b[0] != '\n') {
+				break
+			}
+			r.ReadByte()
+		}
+		if _, err := r.Peek(1); err == io.EOF {
+			return exchanges, nil
+		}
+
+		req, err := http.ReadRequest(r)
+		if err != nil {
+			return nil, fmt.Errorf("request %d: %w", i, err)
+		}
+		e := &Exchange{Index: i, Method: req.Method, URL: req.URL, RequestType: req.Header.Get("Content-Type")}
+		if e.RequestBody, err = io.ReadAll(req.Body); err != nil {
+			return nil, fmt.Errorf("request %d: %w", i, err)
+		}
+		req.Body.Close()
+
+		if _, err := r.Peek(1); err == io.EOF {
+			// no response was captured
+			exchanges = append(exchanges, e)
+			return exchanges, nil
+		}
+		res, err := http.ReadResponse(r, req)
+		if err != nil {
+			return nil, fmt.Errorf("response %d: %w", i, err)
+		}
+		e.Status, e.ResponseType = res.StatusCode, res.Header.Get("Content-Type")
+		if e.ResponseBody, err = io.ReadAll(res.Body); err != nil {
+			return nil, fmt.Errorf("response %d: %w", i, err)
+		}
+		res.Body.Close()
+		exchanges = append(exchanges, e)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"sort"
+
+	"github.com/zishang520/engine.io/packet"
+)
+
+// Flags the protocol violations of a session, the payloads larger than
+// maxPayload are flagged unless the handshake tells the limit.
+func (s *Session) check(maxPayload int64) {
+	s.checkOverlaps()
+	s.checkHeartbeats()
+	s.checkPayloads(s.maxPayload(maxPayload))
+
+	sort.SliceStable(s.Violations, func(i, j int) bool {
+		return s.Violations[i].Time.Before(s.Violations[j].Time)
+	})
+}
+
+// Flags the polling requests sent while another one of the same method was
+// pending, the server answers them with a 400 and closes the session.
+func (s *Session) checkOverlaps() {
+	pending := map[string]*Exchange{}
+	for _, e := range s.Exchanges {
+		if e.URL.Query().Get("transport") != "polling" || !e.Timed() {
+			continue
+		}
+		p := pending[e.Method]
+		if p != nil && e.Start.Before(p.End()) {
+			what := "overlapping polls"
+			if e.Method != "GET" {
+				what = "overlapping " + e.Method + "s"
+			}
+			s.violation(e.Start, e, "%s: sent while #%d was pending", what, p.Index)
+		}
+		if p == nil || e.End().After(p.End()) {
+			pending[e.Method] = e
+		}
+	}
+}
+
+// Flags the heartbeats sent in the wrong direction: the server pings in the
+// protocol v4, the client in v3. The probes of the upgrades go both ways.
+func (s *Session) checkHeartbeats() {
+	for _, event := range s.Events {
+		p := event.Packet
+		if p == nil || (p.Type != packet.PING && p.Type != packet.PONG) || string(readData(p)) == "probe" {
+			continue
+		}
+		client := event.Direction == SENT
+		switch {
+		case s.Protocol == 4 && p.Type == packet.PING && client:
+			s.violation(event.Time, event.Exchange, "ping sent by the client, the server pings in protocol v4")
+		case s.Protocol == 4 && p.Type == packet.PONG && !client:
+			s.violation(event.Time, event.Exchange, "pong sent by the server, the client answers the pings in protocol v4")
+		case s.Protocol == 3 && p.Type == packet.PING && !client:
+			s.violation(event.Time, event.Exchange, "ping sent by the server, the client pings in protocol v3")
+		case s.Protocol == 3 && p.Type == packet.PONG && client:
+			s.violation(event.Time, event.Exchange, "pong sent by the client, the server answers the pings in protocol v3")
+		}
+	}
+}
+
+// Flags the payloads of the client larger than limit, which the server
+// rejects, a zero limit disables the check.
+func (s *Session) checkPayloads(limit int64) {
+	if limit <= 0 {
+		return
+	}
+	for _, e := range s.Exchanges {
+		if e.Method == "POST" && int64(len(e.RequestBody)) > limit {
+			s.violation(e.Start, e, "oversize payload: POST of %d bytes, over the maxPayload of %d", len(e.RequestBody), limit)
+		}
+		for _, f := range e.Frames {
+			if f.Sent && int64(len(f.Data)) > limit {
+				s.violation(f.Time, e, "oversize payload: frame of %d bytes, over the maxPayload of %d", len(f.Data), limit)
+			}
+		}
+	}
+}
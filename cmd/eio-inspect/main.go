@@ -0,0 +1,187 @@
+// Command eio-inspect analyzes the Engine.IO traffic of HTTP captures: it
+// reconstructs the sessions by sid, decodes the polling payloads and WebSocket
+// frames of the protocol v3 and v4 and flags the protocol violations, such as
+// overlapping polls, heartbeats sent in the wrong direction and oversize
+// payloads.
+//
+//	eio-inspect capture.har dump.txt
+//
+// A capture is either a HAR file, as exported by the browsers, or a raw dump of
+// requests each followed by its response as sent over HTTP/1.1. The raw dumps
+// are not timed, the overlapping polls are only flagged in HAR files.
+//
+// The exit status is 1 when a violation was found.
+package main
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+const maxHexBytes = 64
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("eio-inspect", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	maxPayload := flags.Int64("max-payload", 1e5, "the largest payload of a client when the handshake does not tell it, 0 disables the check")
+	violations := flags.Bool("violations", false, "print the violations only")
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: eio-inspect [flags] capture...\n\nflags:\n")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return 0
+		}
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	status := 0
+	for i, name := range flags.Args() {
+		data, err := os.ReadFile(name)
+		if err == nil {
+			var exchanges []*Exchange
+			if exchanges, err = readCapture(data); err == nil {
+				if i > 0 {
+					fmt.Fprintln(stdout)
+				}
+				if c := analyze(exchanges, *maxPayload); c.print(stdout, name, *violations) > 0 && status == 0 {
+					status = 1
+				}
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "%s: %v\n", name, err)
+			status = 2
+		}
+	}
+	return status
+}
+
+// Prints the analysis of a capture and returns the number of violations.
+func (c *Capture) print(w io.Writer, name string, violationsOnly bool) int {
+	count := 0
+	for _, s := range c.Sessions {
+		count += len(s.Violations)
+	}
+	fmt.Fprintf(w, "%s: %d requests, %d sessions, %d violations", name, c.Exchanges, len(c.Sessions), count)
+	if len(c.Rejected) > 0 {
+		fmt.Fprintf(w, ", %d rejected handshakes", len(c.Rejected))
+	}
+	if c.Skipped > 0 {
+		fmt.Fprintf(w, ", %d other requests", c.Skipped)
+	}
+	fmt.Fprintln(w)
+
+	// the offsets are relative to the first timed exchange
+	var origin time.Time
+	for _, s := range c.Sessions {
+		for _, e := range s.Exchanges {
+			if e.Timed() && (origin.IsZero() || e.Start.Before(origin)) {
+				origin = e.Start
+			}
+		}
+	}
+	for _, e := range c.Rejected {
+		if e.Exchange.Timed() && (origin.IsZero() || e.Time.Before(origin)) {
+			origin = e.Time
+		}
+	}
+	line := func(at time.Time, e *Exchange, format string, args ...any) {
+		offset := ""
+		if !at.IsZero() {
+			offset = fmt.Sprintf("+%.3fs", at.Sub(origin).Seconds())
+		}
+		fmt.Fprintf(w, "  %9s  #%-3d "+format+"\n", append([]any{offset, e.Index}, args...)...)
+	}
+
+	if !violationsOnly {
+		for _, e := range c.Rejected {
+			line(e.Time, e.Exchange, "%-9s handshake rejected: %s", e.Transport, e.Note)
+		}
+	}
+	for _, s := range c.Sessions {
+		if violationsOnly && len(s.Violations) == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s\n", s.describe())
+		if !violationsOnly {
+			for _, e := range s.Events {
+				if e.Packet == nil {
+					line(e.Time, e.Exchange, "%-9s %s %s", e.Transport, e.Exchange.Method, e.Note)
+				} else {
+					line(e.Time, e.Exchange, "%-9s %s %s", e.Transport, e.Direction, formatPacket(e.Packet))
+				}
+			}
+			if len(s.Violations) > 0 {
+				fmt.Fprintf(w, "  violations:\n")
+			}
+		}
+		for _, v := range s.Violations {
+			line(v.Time, v.Exchange, "%s", v.Message)
+		}
+	}
+	return count
+}
+
+// Describes a session on a line, followed by its handshake.
+func (s *Session) describe() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "session %s, EIO=%d", s.Sid, s.Protocol)
+	if s.Transport != "" {
+		fmt.Fprintf(&b, " over %s", s.Transport)
+	}
+	if s.Base64 {
+		b.WriteString(", base64")
+	}
+	if s.Upgraded && s.Transport != "websocket" {
+		b.WriteString(", upgraded to websocket")
+	}
+	if s.Handshake == nil {
+		b.WriteString(", handshake not captured")
+	} else {
+		fmt.Fprintf(&b, "\n  pingInterval %dms, pingTimeout %dms", s.Handshake.PingInterval, s.Handshake.PingTimeout)
+		if s.Handshake.MaxPayload > 0 {
+			fmt.Fprintf(&b, ", maxPayload %d", s.Handshake.MaxPayload)
+		}
+	}
+	return b.String()
+}
+
+// Formats a packet as its type followed by its data, quoted when text and in
+// hex when binary.
+func formatPacket(p *packet.Packet) string {
+	data := readData(p)
+	_, binary := p.Data.(*types.BytesBuffer)
+	switch {
+	case binary:
+		shown := data
+		if len(shown) > maxHexBytes {
+			shown = shown[:maxHexBytes]
+		}
+		s := fmt.Sprintf("%s binary %d bytes %s", p.Type, len(data), hex.EncodeToString(shown))
+		if len(shown) < len(data) {
+			s += "…"
+		}
+		return s
+	case len(data) > 0:
+		return fmt.Sprintf("%s %q", p.Type, data)
+	}
+	return string(p.Type)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/parser"
+	"github.com/zishang520/engine.io/types"
+)
+
+var epoch = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// Returns a HAR entry of a request sent at start.
+func harEntry(start, duration time.Duration, method, query, body string, status int, response string) map[string]any {
+	entry := map[string]any{
+		"startedDateTime": epoch.Add(start).Format(time.RFC3339Nano),
+		"time":            float64(duration) / float64(time.Millisecond),
+		"request":         map[string]any{"method": method, "url": "http://localhost:3000/engine.io/?" + query},
+		"response":        map[string]any{"status": status, "content": map[string]any{"mimeType": "text/plain; charset=UTF-8", "text": response}},
+	}
+	if method == "POST" {
+		entry["request"].(map[string]any)["postData"] = map[string]any{"mimeType": "text/plain;charset=UTF-8", "text": body}
+	}
+	return entry
+}
+
+// Returns a HAR WebSocket message sent at offset.
+func harFrame(offset time.Duration, sent bool, data any) map[string]any {
+	frame := map[string]any{"type": "receive", "time": float64(epoch.Add(offset).UnixNano()) / 1e9, "opcode": 1, "data": data}
+	if sent {
+		frame["type"] = "send"
+	}
+	if b, ok := data.([]byte); ok {
+		frame["opcode"], frame["data"] = 2, base64.StdEncoding.EncodeToString(b)
+	}
+	return frame
+}
+
+func writeFile(t *testing.T, name string, data string) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func inspect(t *testing.T, args ...string) (string, int) {
+	out := new(strings.Builder)
+	code := run(args, out, out)
+	return out.String(), code
+}
+
+func TestHAR(t *testing.T) {
+	const ms = time.Millisecond
+	websocket := harEntry(1400*ms, 0, "GET", "EIO=4&transport=websocket&sid=s1", "", 101, "")
+	websocket["_webSocketMessages"] = []any{
+		harFrame(1401*ms, true, "2probe"),
+		harFrame(1402*ms, false, "3probe"),
+		harFrame(1403*ms, true, "5"),
+		harFrame(1404*ms, false, "4hello"),
+		harFrame(1405*ms, false, "3"),
+		harFrame(1406*ms, true, []byte{1, 2, 3}),
+	}
+	har := map[string]any{"log": map[string]any{"entries": []any{
+		harEntry(0, 10*ms, "GET", "EIO=4&transport=polling", "", 200, `0{"sid":"s1","upgrades":["websocket"],"pingInterval":25000,"pingTimeout":20000,"maxPayload":100}`),
+		harEntry(100*ms, 1000*ms, "GET", "EIO=4&transport=polling&sid=s1", "", 200, "2"),
+		harEntry(200*ms, 10*ms, "POST", "EIO=4&transport=polling&sid=s1", "3", 200, "ok"),
+		harEntry(500*ms, 10*ms, "GET", "EIO=4&transport=polling&sid=s1", "", 400, `{"code":3,"message":"Bad request"}`),
+		harEntry(1200*ms, 10*ms, "POST", "EIO=4&transport=polling&sid=s1", "2\x1e4hello", 200, "ok"),
+		harEntry(1300*ms, 10*ms, "POST", "EIO=4&transport=polling&sid=s1", "4"+strings.Repeat("x", 150), 413, ""),
+		websocket,
+		harEntry(0, 10*ms, "GET", "", "", 200, "<html>"),
+		harEntry(50*ms, 10*ms, "GET", "EIO=3&transport=polling", "", 400, `{"code":5,"message":"Unsupported protocol version"}`),
+		harEntry(1500*ms, 10*ms, "POST", "EIO=4&transport=polling&sid=s2", "4ok\x1e9x", 200, "ok"),
+	}}}
+	data, _ := json.Marshal(har)
+
+	out, code := inspect(t, writeFile(t, "capture.har", string(data)))
+	if code != 1 {
+		t.Fatalf("run() = %d, want match for %d, output\n%s", code, 1, out)
+	}
+	for _, line := range []string{
+		"10 requests, 2 sessions, 5 violations, 1 rejected handshakes, 1 other requests",
+		`+0.050s  #9   polling   handshake rejected: HTTP 400, code 5 "Unsupported protocol version"`,
+		"session s1, EIO=4 over polling, upgraded to websocket",
+		"pingInterval 25000ms, pingTimeout 20000ms, maxPayload 100",
+		`+0.010s  #1   polling   < open "{\"sid\":\"s1\"`,
+		`+1.100s  #2   polling   < ping`,
+		`+0.500s  #4   polling   GET HTTP 400, code 3 "Bad request"`,
+		`+1.404s  #7   websocket < message "hello"`,
+		`+1.406s  #7   websocket > message binary 3 bytes 010203`,
+		`+0.500s  #4   overlapping polls: sent while #2 was pending`,
+		`+1.200s  #5   ping sent by the client, the server pings in protocol v4`,
+		`+1.300s  #6   oversize payload: POST of 151 bytes, over the maxPayload of 100`,
+		`+1.405s  #7   pong sent by the server, the client answers the pings in protocol v4`,
+		"session s2, EIO=4, handshake not captured",
+		`+1.500s  #10  polling   > message "ok"`,
+		`+1.500s  #10  POST: undecodable packet 2 "9x"`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("run() printed\n%s\nwant a line with %q", out, line)
+		}
+	}
+	if n := strings.Count(out, "ping sent by the client"); n != 1 {
+		t.Fatalf("run() flagged %d client pings, want match for 1 as the probes go both ways\n%s", n, out)
+	}
+
+	t.Run("Violations", func(t *testing.T) {
+		out, _ := inspect(t, "-violations", writeFile(t, "capture.har", string(data)))
+		if strings.Contains(out, "< open") || !strings.Contains(out, "overlapping polls") {
+			t.Fatalf("run(-violations) printed\n%s\nwant the violations only", out)
+		}
+	})
+}
+
+// Returns a request of a dump followed by its response.
+func dumpExchange(method, query, contentType string, body []byte, status int, responseType string, response []byte) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s /engine.io/?%s HTTP/1.1\r\nHost: localhost:3000\r\n", method, query)
+	if method == "POST" {
+		fmt.Fprintf(&b, "Content-Type: %s\r\nContent-Length: %d\r\n", contentType, len(body))
+	}
+	fmt.Fprintf(&b, "\r\n%s", body)
+	fmt.Fprintf(&b, "HTTP/1.1 %d OK\r\nContent-Type: %s\r\nContent-Length: %d\r\n\r\n%s\r\n", status, responseType, len(response), response)
+	return b.String()
+}
+
+func TestDump(t *testing.T) {
+	v3 := parser.Parserv3()
+	payload := func(supportsBinary bool, packets ...*packet.Packet) []byte {
+		data, err := v3.EncodePayload(packets, supportsBinary)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data.Bytes()
+	}
+	message := func(data string) *packet.Packet {
+		return &packet.Packet{Type: packet.MESSAGE, Data: types.NewStringBufferString(data)}
+	}
+	binary := func() *packet.Packet {
+		return &packet.Packet{Type: packet.MESSAGE, Data: types.NewBytesBuffer([]byte{1, 2})}
+	}
+	text := "text/plain; charset=UTF-8"
+
+	dump := dumpExchange("GET", "EIO=3&transport=polling&b64=1", "", nil, 200, text,
+		payload(false, &packet.Packet{Type: packet.OPEN, Data: types.NewStringBufferString(`{"sid":"d1","upgrades":[],"pingInterval":25000,"pingTimeout":5000}`)}, message("hi"))) +
+		dumpExchange("POST", "EIO=3&transport=polling&b64=1&sid=d1", text, payload(false, &packet.Packet{Type: packet.PING}, binary()), 200, "text/html", []byte("ok")) +
+		"\r\n" +
+		dumpExchange("GET", "EIO=3&transport=polling&sid=d1", "", nil, 200, "application/octet-stream", payload(true, &packet.Packet{Type: packet.PONG}, binary())) +
+		dumpExchange("GET", "EIO=3&transport=polling&j=0&sid=d1", "", nil, 200, "text/javascript; charset=UTF-8", []byte(`___eio[0]("1:6");`)) +
+		dumpExchange("POST", "EIO=3&transport=polling&j=0&sid=d1", "application/x-www-form-urlencoded", []byte(`d=4%3A4a%5Cnb`), 200, "text/html", []byte("ok"))
+
+	out, code := inspect(t, writeFile(t, "dump.txt", dump))
+	if code != 0 {
+		t.Fatalf("run() = %d, want match for %d, output\n%s", code, 0, out)
+	}
+	for _, line := range []string{
+		"5 requests, 1 sessions, 0 violations",
+		"session d1, EIO=3 over polling, base64",
+		`#1   polling   < message "hi"`,
+		`#2   polling   > ping`,
+		`#2   polling   > message binary 2 bytes 0102`,
+		`#3   polling   < pong`,
+		`#3   polling   < message binary 2 bytes 0102`,
+		`#4   polling   < noop`,
+		`#5   polling   > message "a\nb"`,
+	} {
+		if !strings.Contains(out, line) {
+			t.Fatalf("run() printed\n%s\nwant a line with %q", out, line)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	if _, code := inspect(t); code != 2 {
+		t.Fatalf("run() = %d, want match for %d", code, 2)
+	}
+	if out, code := inspect(t, filepath.Join(t.TempDir(), "missing.har")); code != 2 || !strings.Contains(out, "missing.har") {
+		t.Fatalf("run() = %d %q, want match for 2 and the name of the file", code, out)
+	}
+	if out, code := inspect(t, writeFile(t, "invalid.har", `{"log":`)); code != 2 || !strings.Contains(out, "invalid HAR file") {
+		t.Fatalf("run() = %d %q, want match for 2 and an invalid HAR file", code, out)
+	}
+}
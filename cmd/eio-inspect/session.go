@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/parser"
+	"github.com/zishang520/engine.io/transports"
+	"github.com/zishang520/engine.io/types"
+)
+
+// Directions of a packet.
+const (
+	SENT     = ">" // by the client
+	RECEIVED = "<" // by the client
+)
+
+// Handshake holds the fields of an open packet.
+type Handshake struct {
+	Sid          string   `json:"sid"`
+	Upgrades     []string `json:"upgrades"`
+	PingInterval int64    `json:"pingInterval"`
+	PingTimeout  int64    `json:"pingTimeout"`
+	MaxPayload   int64    `json:"maxPayload"`
+}
+
+// Event is a packet of a session, or a note about an exchange.
+type Event struct {
+	Time      time.Time // zero when the capture is not timed
+	Exchange  *Exchange
+	Transport string
+	Direction string
+	Packet    *packet.Packet // nil for a note
+	Note      string
+}
+
+// Violation is a breach of the protocol.
+type Violation struct {
+	Time     time.Time
+	Exchange *Exchange
+	Message  string
+}
+
+// Session is a session of a capture, reconstructed by sid.
+type Session struct {
+	Sid        string
+	Protocol   int
+	Transport  string     // of the handshake, empty when not captured
+	Base64     bool       // the binary packets are sent as base64
+	Handshake  *Handshake // nil when the handshake was not captured
+	Upgraded   bool
+	Exchanges  []*Exchange
+	Events     []*Event
+	Violations []*Violation
+}
+
+// Returns the largest payload the server accepts, limit when the handshake
+// does not tell it.
+func (s *Session) maxPayload(limit int64) int64 {
+	if s.Handshake != nil && s.Handshake.MaxPayload > 0 {
+		return s.Handshake.MaxPayload
+	}
+	return limit
+}
+
+func (s *Session) parser() parser.Parser {
+	if s.Protocol == 3 {
+		return parser.Parserv3()
+	}
+	return parser.Parserv4()
+}
+
+func (s *Session) violation(at time.Time, e *Exchange, format string, args ...any) {
+	s.Violations = append(s.Violations, &Violation{Time: at, Exchange: e, Message: fmt.Sprintf(format, args...)})
+}
+
+// Capture is the outcome of the analysis of a capture.
+type Capture struct {
+	Exchanges int
+	Skipped   int      // the requests which are not Engine.IO ones
+	Rejected  []*Event // the handshakes which did not open a session
+	Sessions  []*Session
+}
+
+// Reconstructs the sessions of the exchanges, the payloads of a session larger
+// than maxPayload are flagged when its handshake does not tell the limit.
+func analyze(exchanges []*Exchange, maxPayload int64) *Capture {
+	c := &Capture{Exchanges: len(exchanges)}
+	sorted := append([]*Exchange{}, exchanges...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	bySid := map[string]*Session{}
+	for _, e := range sorted {
+		q := e.URL.Query()
+		transport, sid := q.Get("transport"), q.Get("sid")
+		protocol, err := strconv.Atoi(q.Get("EIO"))
+		if (transport != "polling" && transport != "websocket") || (protocol != 3 && protocol != 4) {
+			c.Skipped++
+			continue
+		}
+
+		s := bySid[sid]
+		if sid == "" {
+			s = &Session{Protocol: protocol, Base64: q.Has("b64") || q.Has("j")}
+			events := s.decode(e, transport)
+			if len(events) == 0 || events[0].Packet == nil || events[0].Packet.Type != packet.OPEN {
+				event := &Event{Time: e.Start, Exchange: e, Transport: transport, Note: "no open packet"}
+				if len(events) > 0 && events[0].Note != "" {
+					event.Note = events[0].Note
+				}
+				c.Rejected = append(c.Rejected, event)
+				continue
+			}
+			handshake := &Handshake{}
+			if err = json.Unmarshal(readData(events[0].Packet), handshake); err != nil || handshake.Sid == "" {
+				c.Rejected = append(c.Rejected, &Event{Time: e.Start, Exchange: e, Transport: transport, Note: "the open packet holds no session"})
+				continue
+			}
+			s.Sid, s.Transport, s.Handshake = handshake.Sid, transport, handshake
+			s.Events = events
+			s.Exchanges = append(s.Exchanges, e)
+			bySid[s.Sid] = s
+			c.Sessions = append(c.Sessions, s)
+			continue
+		}
+		if s == nil {
+			// opened before the capture started
+			s = &Session{Sid: sid, Protocol: protocol, Base64: q.Has("b64") || q.Has("j")}
+			bySid[sid] = s
+			c.Sessions = append(c.Sessions, s)
+		}
+		if transport == "websocket" {
+			s.Upgraded = true
+		}
+		s.Exchanges = append(s.Exchanges, e)
+		s.Events = append(s.Events, s.decode(e, transport)...)
+	}
+
+	for _, s := range c.Sessions {
+		s.check(maxPayload)
+	}
+	return c
+}
+
+// Decodes the packets of an exchange, the violations found are added to s.
+func (s *Session) decode(e *Exchange, transport string) (events []*Event) {
+	add := func(at time.Time, direction string, p *packet.Packet) {
+		events = append(events, &Event{Time: at, Exchange: e, Transport: transport, Direction: direction, Packet: p})
+	}
+	note := func(format string, args ...any) {
+		events = append(events, &Event{Time: e.Start, Exchange: e, Transport: transport, Note: fmt.Sprintf(format, args...)})
+	}
+
+	if transport == "websocket" {
+		if e.Status != 0 && e.Status != 101 {
+			note("%s", rejection(e))
+		}
+		for _, f := range e.Frames {
+			direction := RECEIVED
+			if f.Sent {
+				direction = SENT
+			}
+			var data types.BufferInterface = types.NewStringBuffer(f.Data)
+			if f.Binary {
+				data = types.NewBytesBuffer(f.Data)
+			}
+			p, err := s.parser().DecodePacket(data)
+			if err != nil {
+				s.violation(f.Time, e, "undecodable %s frame: %v", direction, err)
+				continue
+			}
+			add(f.Time, direction, p)
+		}
+		return events
+	}
+
+	jsonp := e.URL.Query().Has("j")
+	if e.Method == "POST" {
+		packets, err := s.decodePayload(e.RequestBody, e.RequestType, jsonp, true)
+		if err != nil {
+			s.violation(e.Start, e, "POST: %v", err)
+		}
+		for _, p := range packets {
+			add(e.Start, SENT, p)
+		}
+		if e.Status != 200 {
+			note("%s", rejection(e))
+		}
+		return events
+	}
+
+	if e.Status != 200 {
+		note("%s", rejection(e))
+		return events
+	}
+	packets, err := s.decodePayload(e.ResponseBody, e.ResponseType, jsonp, false)
+	if err != nil {
+		s.violation(e.End(), e, "GET: %v", err)
+	}
+	for _, p := range packets {
+		add(e.End(), RECEIVED, p)
+	}
+	return events
+}
+
+// Describes the response of a rejected request.
+func rejection(e *Exchange) string {
+	if e.Status == 0 {
+		return "no response"
+	}
+	var message types.CodeMessage
+	if json.Unmarshal(e.ResponseBody, &message) == nil && message.Message != "" {
+		return fmt.Sprintf("HTTP %d, code %d %q", e.Status, message.Code, message.Message)
+	}
+	return fmt.Sprintf("HTTP %d", e.Status)
+}
+
+var rJSONP = regexp.MustCompile(`^___eio\[\d*\]\(([\s\S]*)\);$`)
+
+// Decodes a polling payload, request tells whether the client sent it.
+func (s *Session) decodePayload(body []byte, contentType string, jsonp bool, request bool) ([]*packet.Packet, error) {
+	if jsonp {
+		var err error
+		if body, err = unwrapJSONP(body, request); err != nil {
+			return nil, err
+		}
+	}
+	if len(body) == 0 {
+		return nil, nil
+	}
+
+	binary := strings.HasPrefix(contentType, "application/octet-stream")
+	if s.Protocol == 4 {
+		if binary {
+			return nil, errors.New("binary payload, the protocol v4 polling payloads are text")
+		}
+		// decoded packet by packet to tell which one is invalid
+		packets := []*packet.Packet{}
+		for i, encoded := range bytes.Split(body, []byte{parser.SEPARATOR}) {
+			p, err := parser.Parserv4().DecodePacket(types.NewStringBuffer(encoded))
+			if err != nil {
+				return packets, fmt.Errorf("undecodable packet %d %q: %v", i+1, shorten(encoded), err)
+			}
+			packets = append(packets, p)
+		}
+		return packets, nil
+	}
+
+	var packets []*packet.Packet
+	if binary {
+		packets = parser.Parserv3().DecodePayload(types.NewBytesBuffer(body))
+	} else {
+		packets = parser.Parserv3().DecodePayload(types.NewStringBuffer(body))
+	}
+	if len(packets) == 0 {
+		return nil, fmt.Errorf("undecodable payload %q", shorten(body))
+	}
+	return packets, nil
+}
+
+// Returns the payload of a JSONP request or response.
+func unwrapJSONP(body []byte, request bool) ([]byte, error) {
+	if request {
+		form, err := url.ParseQuery(string(body))
+		if err != nil || !form.Has("d") {
+			return nil, errors.New(`JSONP request without a "d" field`)
+		}
+		return []byte(transports.UnescapeJSONP(form.Get("d"))), nil
+	}
+	m := rJSONP.FindSubmatch(bytes.TrimSpace(body))
+	var payload string
+	if m == nil || json.Unmarshal(m[1], &payload) != nil {
+		return nil, fmt.Errorf("JSONP response %q does not call ___eio with a string", shorten(body))
+	}
+	return []byte(payload), nil
+}
+
+// Returns the data of a decoded packet.
+func readData(p *packet.Packet) []byte {
+	b, ok := p.Data.(types.BufferInterface)
+	if !ok {
+		return nil
+	}
+	return b.Bytes()
+}
+
+func shorten(data []byte) []byte {
+	if len(data) > 32 {
+		return append(data[:32:32], "…"...)
+	}
+	return data
+}
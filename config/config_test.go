@@ -2,11 +2,16 @@ package config
 
 import (
 	"bytes"
+	"io"
 	"net/http"
 	"testing"
 	"time"
 
+	"github.com/zishang520/engine.io/log"
+	"github.com/zishang520/engine.io/metrics"
+	"github.com/zishang520/engine.io/tracing"
 	"github.com/zishang520/engine.io/types"
+	"github.com/zishang520/engine.io/utils"
 )
 
 func TestAttachOptionsDefauleValue(t *testing.T) {
@@ -81,6 +86,18 @@ func TestServerOptionsDefauleValue(t *testing.T) {
 		}
 	})
 
+	t.Run("maxPacketSize", func(t *testing.T) {
+		if maxPacketSize := opts.MaxPacketSize(); opts.GetRawMaxPacketSize() == nil && maxPacketSize != 0 {
+			t.Fatalf(`*ServerOptions.MaxPacketSize() = %d, want match for %d`, maxPacketSize, 0)
+		}
+	})
+
+	t.Run("maxPacketsPerPayload", func(t *testing.T) {
+		if maxPacketsPerPayload := opts.MaxPacketsPerPayload(); opts.GetRawMaxPacketsPerPayload() == nil && maxPacketsPerPayload != 0 {
+			t.Fatalf(`*ServerOptions.MaxPacketsPerPayload() = %d, want match for %d`, maxPacketsPerPayload, 0)
+		}
+	})
+
 	t.Run("allowRequest", func(t *testing.T) {
 		if allowRequest := opts.AllowRequest(); opts.GetRawAllowRequest() == nil && allowRequest != nil {
 			t.Fatalf(`*ServerOptions.AllowRequest() = %v, want match for nil`, allowRequest)
@@ -129,11 +146,47 @@ func TestServerOptionsDefauleValue(t *testing.T) {
 		}
 	})
 
+	t.Run("allowedOrigins", func(t *testing.T) {
+		if allowedOrigins := opts.AllowedOrigins(); opts.GetRawAllowedOrigins() == nil && allowedOrigins != nil {
+			t.Fatalf(`*ServerOptions.AllowedOrigins() = %v, want match for nil`, allowedOrigins)
+		}
+	})
+
 	t.Run("allowEIO3", func(t *testing.T) {
 		if allowEIO3 := opts.AllowEIO3(); opts.GetRawAllowEIO3() == nil && allowEIO3 != false {
 			t.Fatalf(`*ServerOptions.AllowEIO3() = %t, want match for %t`, allowEIO3, false)
 		}
 	})
+
+	t.Run("adaptiveHeartbeat", func(t *testing.T) {
+		if adaptiveHeartbeat := opts.AdaptiveHeartbeat(); opts.GetRawAdaptiveHeartbeat() == nil && adaptiveHeartbeat != false {
+			t.Fatalf(`*ServerOptions.AdaptiveHeartbeat() = %t, want match for %t`, adaptiveHeartbeat, false)
+		}
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		if recorder := opts.Metrics(); opts.GetRawMetrics() == nil && recorder != metrics.Discard {
+			t.Fatalf(`*ServerOptions.Metrics() = %v, want match for %v`, recorder, metrics.Discard)
+		}
+	})
+
+	t.Run("tracer", func(t *testing.T) {
+		if tracer := opts.Tracer(); opts.GetRawTracer() == nil && tracer != tracing.Noop {
+			t.Fatalf(`*ServerOptions.Tracer() = %v, want match for %v`, tracer, tracing.Noop)
+		}
+	})
+
+	t.Run("logger", func(t *testing.T) {
+		if logger := opts.Logger(); opts.GetRawLogger() == nil && logger != nil {
+			t.Fatalf(`*ServerOptions.Logger() = %v, want match for %v`, logger, nil)
+		}
+	})
+
+	t.Run("clock", func(t *testing.T) {
+		if clock := opts.Clock(); opts.GetRawClock() == nil && clock != utils.SystemClock() {
+			t.Fatalf(`*ServerOptions.Clock() = %v, want match for %v`, clock, utils.SystemClock())
+		}
+	})
 }
 
 func TestServerOptionsSetValue(t *testing.T) {
@@ -167,6 +220,20 @@ func TestServerOptionsSetValue(t *testing.T) {
 		}
 	})
 
+	t.Run("maxPacketSize", func(t *testing.T) {
+		opts.SetMaxPacketSize(512)
+		if maxPacketSize := opts.MaxPacketSize(); maxPacketSize != 512 {
+			t.Fatalf(`*ServerOptions.MaxPacketSize() = %d, want match for %d`, maxPacketSize, 512)
+		}
+	})
+
+	t.Run("maxPacketsPerPayload", func(t *testing.T) {
+		opts.SetMaxPacketsPerPayload(16)
+		if maxPacketsPerPayload := opts.MaxPacketsPerPayload(); maxPacketsPerPayload != 16 {
+			t.Fatalf(`*ServerOptions.MaxPacketsPerPayload() = %d, want match for %d`, maxPacketsPerPayload, 16)
+		}
+	})
+
 	t.Run("allowRequest", func(t *testing.T) {
 		opts.SetAllowRequest(nil)
 		if allowRequest := opts.AllowRequest(); allowRequest != nil {
@@ -233,10 +300,55 @@ func TestServerOptionsSetValue(t *testing.T) {
 		}
 	})
 
+	t.Run("allowedOrigins", func(t *testing.T) {
+		input := types.NewAllowedOrigins("http://localhost")
+		opts.SetAllowedOrigins(input)
+		if allowedOrigins := opts.AllowedOrigins(); allowedOrigins != input {
+			t.Fatalf(`*ServerOptions.AllowedOrigins() = %v, want match for %v`, allowedOrigins, input)
+		}
+	})
+
 	t.Run("allowEIO3", func(t *testing.T) {
 		opts.SetAllowEIO3(true)
 		if allowEIO3 := opts.AllowEIO3(); allowEIO3 != true {
 			t.Fatalf(`*ServerOptions.AllowEIO3() = %t, want match for %t`, allowEIO3, true)
 		}
 	})
+
+	t.Run("adaptiveHeartbeat", func(t *testing.T) {
+		opts.SetAdaptiveHeartbeat(true)
+		if adaptiveHeartbeat := opts.AdaptiveHeartbeat(); adaptiveHeartbeat != true {
+			t.Fatalf(`*ServerOptions.AdaptiveHeartbeat() = %t, want match for %t`, adaptiveHeartbeat, true)
+		}
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		input := metrics.NewRegistry()
+		opts.SetMetrics(input)
+		if recorder := opts.Metrics(); recorder != input {
+			t.Fatalf(`*ServerOptions.Metrics() = %v, want match for %v`, recorder, input)
+		}
+	})
+
+	t.Run("tracer", func(t *testing.T) {
+		opts.SetTracer(nil)
+		if tracer := opts.Tracer(); tracer != tracing.Noop {
+			t.Fatalf(`*ServerOptions.Tracer() = %v, want match for %v`, tracer, tracing.Noop)
+		}
+	})
+	t.Run("logger", func(t *testing.T) {
+		input := log.NewJSONLogger(io.Discard)
+		opts.SetLogger(input)
+		if logger := opts.Logger(); logger != input {
+			t.Fatalf(`*ServerOptions.Logger() = %v, want match for %v`, logger, input)
+		}
+	})
+
+	t.Run("clock", func(t *testing.T) {
+		input := utils.NewWheelClock(utils.NewTimingWheel(time.Millisecond, 8))
+		opts.SetClock(input)
+		if clock := opts.Clock(); clock != input {
+			t.Fatalf(`*ServerOptions.Clock() = %v, want match for %v`, clock, input)
+		}
+	})
 }
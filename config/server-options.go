@@ -5,7 +5,11 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/zishang520/engine.io/log"
+	"github.com/zishang520/engine.io/metrics"
+	"github.com/zishang520/engine.io/tracing"
 	"github.com/zishang520/engine.io/types"
+	"github.com/zishang520/engine.io/utils"
 )
 
 type AllowRequest func(*types.HttpContext) error
@@ -27,6 +31,14 @@ type ServerOptionsInterface interface {
 	GetRawMaxHttpBufferSize() *int64
 	MaxHttpBufferSize() int64
 
+	SetMaxPacketSize(int64)
+	GetRawMaxPacketSize() *int64
+	MaxPacketSize() int64
+
+	SetMaxPacketsPerPayload(int)
+	GetRawMaxPacketsPerPayload() *int
+	MaxPacketsPerPayload() int
+
 	SetAllowRequest(AllowRequest)
 	GetRawAllowRequest() AllowRequest
 	AllowRequest() AllowRequest
@@ -59,9 +71,33 @@ type ServerOptionsInterface interface {
 	GetRawCors() *types.Cors
 	Cors() *types.Cors
 
+	SetAllowedOrigins(*types.AllowedOrigins)
+	GetRawAllowedOrigins() *types.AllowedOrigins
+	AllowedOrigins() *types.AllowedOrigins
+
 	SetAllowEIO3(bool)
 	GetRawAllowEIO3() *bool
 	AllowEIO3() bool
+
+	SetAdaptiveHeartbeat(bool)
+	GetRawAdaptiveHeartbeat() *bool
+	AdaptiveHeartbeat() bool
+
+	SetMetrics(metrics.Recorder)
+	GetRawMetrics() metrics.Recorder
+	Metrics() metrics.Recorder
+
+	SetTracer(tracing.Tracer)
+	GetRawTracer() tracing.Tracer
+	Tracer() tracing.Tracer
+
+	SetLogger(log.Logger)
+	GetRawLogger() log.Logger
+	Logger() log.Logger
+
+	SetClock(utils.Clock)
+	GetRawClock() utils.Clock
+	Clock() utils.Clock
 }
 
 type ServerOptions struct {
@@ -77,6 +113,12 @@ type ServerOptions struct {
 	// how many bytes or characters a message can be, before closing the session (to avoid DoS).
 	maxHttpBufferSize *int64
 
+	// how many bytes an encoded packet can be, zero to only bound it by maxHttpBufferSize.
+	maxPacketSize *int64
+
+	// how many packets a polling payload can hold, zero for no limit.
+	maxPacketsPerPayload *int
+
 	// A function that receives a given handshake or upgrade request as its first parameter,
 	// and can decide whether to continue or not. The second argument is a function that needs
 	// to be called with the decided information: fn(err, success), where success is a boolean
@@ -108,8 +150,26 @@ type ServerOptions struct {
 	// the options that will be forwarded to the cors module
 	cors *types.Cors
 
+	// the origins allowed to open a websocket, shared with the cors module when its origin is not set
+	allowedOrigins *types.AllowedOrigins
+
 	// whether to enable compatibility with Socket.IO v2 clients
 	allowEIO3 *bool
+
+	// whether to extend the ping timeout of a socket by its observed round-trip time
+	adaptiveHeartbeat *bool
+
+	// the recorder receiving the measurements of the server and its sockets
+	metrics metrics.Recorder
+
+	// the tracer starting the spans of requests, handshakes, upgrades and packets
+	tracer tracing.Tracer
+
+	// the logger receiving the records of the server, its transports and sockets
+	logger log.Logger
+
+	// the clock of the heartbeats and timeouts of the sockets and transports
+	clock utils.Clock
 }
 
 func DefaultServerOptions() *ServerOptions {
@@ -134,6 +194,12 @@ func (s *ServerOptions) Assign(data ServerOptionsInterface) ServerOptionsInterfa
 	if s.GetRawMaxHttpBufferSize() == nil {
 		s.SetMaxHttpBufferSize(data.MaxHttpBufferSize())
 	}
+	if s.GetRawMaxPacketSize() == nil {
+		s.SetMaxPacketSize(data.MaxPacketSize())
+	}
+	if s.GetRawMaxPacketsPerPayload() == nil {
+		s.SetMaxPacketsPerPayload(data.MaxPacketsPerPayload())
+	}
 	if s.GetRawAllowRequest() == nil {
 		s.SetAllowRequest(data.AllowRequest())
 	}
@@ -158,9 +224,27 @@ func (s *ServerOptions) Assign(data ServerOptionsInterface) ServerOptionsInterfa
 	if s.GetRawCors() == nil {
 		s.SetCors(data.Cors())
 	}
+	if s.GetRawAllowedOrigins() == nil {
+		s.SetAllowedOrigins(data.AllowedOrigins())
+	}
 	if s.GetRawAllowEIO3() == nil {
 		s.SetAllowEIO3(data.AllowEIO3())
 	}
+	if s.GetRawAdaptiveHeartbeat() == nil {
+		s.SetAdaptiveHeartbeat(data.AdaptiveHeartbeat())
+	}
+	if s.GetRawMetrics() == nil {
+		s.SetMetrics(data.Metrics())
+	}
+	if s.GetRawTracer() == nil {
+		s.SetTracer(data.Tracer())
+	}
+	if s.GetRawLogger() == nil {
+		s.SetLogger(data.Logger())
+	}
+	if s.GetRawClock() == nil {
+		s.SetClock(data.Clock())
+	}
 
 	return s
 }
@@ -226,6 +310,36 @@ func (s *ServerOptions) MaxHttpBufferSize() int64 {
 	return *s.maxHttpBufferSize
 }
 
+// how many bytes an encoded packet can be, zero to only bound it by maxHttpBufferSize.
+// @default 0
+func (s *ServerOptions) SetMaxPacketSize(maxPacketSize int64) {
+	s.maxPacketSize = &maxPacketSize
+}
+func (s *ServerOptions) GetRawMaxPacketSize() *int64 {
+	return s.maxPacketSize
+}
+func (s *ServerOptions) MaxPacketSize() int64 {
+	if s.maxPacketSize == nil {
+		return 0
+	}
+	return *s.maxPacketSize
+}
+
+// how many packets a polling payload can hold, zero for no limit.
+// @default 0
+func (s *ServerOptions) SetMaxPacketsPerPayload(maxPacketsPerPayload int) {
+	s.maxPacketsPerPayload = &maxPacketsPerPayload
+}
+func (s *ServerOptions) GetRawMaxPacketsPerPayload() *int {
+	return s.maxPacketsPerPayload
+}
+func (s *ServerOptions) MaxPacketsPerPayload() int {
+	if s.maxPacketsPerPayload == nil {
+		return 0
+	}
+	return *s.maxPacketsPerPayload
+}
+
 // A function that receives a given handshake or upgrade request as its first parameter,
 // and can decide whether to continue or not. The second argument is a function that needs
 // to be called with the decided information: fn(err, success), where success is a boolean
@@ -337,6 +451,20 @@ func (s *ServerOptions) Cors() *types.Cors {
 	return s.cors
 }
 
+// the origins allowed to open a websocket, shared with the cors module when its origin is not set.
+// Without them, the origin of the cors module applies, or else only the same origin is allowed
+// when the cookie is enabled.
+// @default nil
+func (s *ServerOptions) SetAllowedOrigins(allowedOrigins *types.AllowedOrigins) {
+	s.allowedOrigins = allowedOrigins
+}
+func (s *ServerOptions) GetRawAllowedOrigins() *types.AllowedOrigins {
+	return s.allowedOrigins
+}
+func (s *ServerOptions) AllowedOrigins() *types.AllowedOrigins {
+	return s.allowedOrigins
+}
+
 // whether to enable compatibility with Socket.IO v2 clients
 // @default false
 func (s *ServerOptions) SetAllowEIO3(allowEIO3 bool) {
@@ -352,3 +480,79 @@ func (s *ServerOptions) AllowEIO3() bool {
 
 	return *s.allowEIO3
 }
+
+// whether to extend the ping timeout of a socket by twice the 99th percentile of its
+// observed round-trip time, so that high-latency links are not closed prematurely
+// @default false
+func (s *ServerOptions) SetAdaptiveHeartbeat(adaptiveHeartbeat bool) {
+	s.adaptiveHeartbeat = &adaptiveHeartbeat
+}
+func (s *ServerOptions) GetRawAdaptiveHeartbeat() *bool {
+	return s.adaptiveHeartbeat
+}
+func (s *ServerOptions) AdaptiveHeartbeat() bool {
+	if s.adaptiveHeartbeat == nil {
+		return false
+	}
+
+	return *s.adaptiveHeartbeat
+}
+
+// the recorder receiving the measurements of the server and its sockets
+// @default metrics.Discard
+func (s *ServerOptions) SetMetrics(recorder metrics.Recorder) {
+	s.metrics = recorder
+}
+func (s *ServerOptions) GetRawMetrics() metrics.Recorder {
+	return s.metrics
+}
+func (s *ServerOptions) Metrics() metrics.Recorder {
+	if s.metrics == nil {
+		return metrics.Discard
+	}
+	return s.metrics
+}
+
+// the tracer starting the spans of requests, handshakes, upgrades and packets
+// @default tracing.Noop
+func (s *ServerOptions) SetTracer(tracer tracing.Tracer) {
+	s.tracer = tracer
+}
+func (s *ServerOptions) GetRawTracer() tracing.Tracer {
+	return s.tracer
+}
+func (s *ServerOptions) Tracer() tracing.Tracer {
+	if s.tracer == nil {
+		return tracing.Noop
+	}
+	return s.tracer
+}
+
+// the logger receiving the records of the server, its transports and sockets,
+// they carry the "sid", "transport" and "remote" fields when known
+// @default nil, the logger set by log.SetDefault
+func (s *ServerOptions) SetLogger(logger log.Logger) {
+	s.logger = logger
+}
+func (s *ServerOptions) GetRawLogger() log.Logger {
+	return s.logger
+}
+func (s *ServerOptions) Logger() log.Logger {
+	return s.logger
+}
+
+// the clock of the heartbeats and timeouts of the sockets and transports, the
+// tests set a manual one to advance the time at will
+// @default utils.SystemClock()
+func (s *ServerOptions) SetClock(clock utils.Clock) {
+	s.clock = clock
+}
+func (s *ServerOptions) GetRawClock() utils.Clock {
+	return s.clock
+}
+func (s *ServerOptions) Clock() utils.Clock {
+	if s.clock == nil {
+		return utils.SystemClock()
+	}
+	return s.clock
+}
@@ -0,0 +1,307 @@
+package conformance
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/parser"
+	"github.com/zishang520/engine.io/types"
+)
+
+// The fields of the open packet.
+type Handshake struct {
+	Sid          string   `json:"sid"`
+	Upgrades     []string `json:"upgrades"`
+	PingInterval int64    `json:"pingInterval"`
+	PingTimeout  int64    `json:"pingTimeout"`
+	MaxPayload   int64    `json:"maxPayload,omitempty"`
+}
+
+// A response of the server under test.
+type Response struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// Decodes the body as the JSON error of a rejected request.
+func (r *Response) Error(tb testing.TB) *types.CodeMessage {
+	tb.Helper()
+
+	var message types.CodeMessage
+	if err := json.Unmarshal(r.Body, &message); err != nil {
+		tb.Fatalf("error body %q is not JSON: %v", r.Body, err)
+	}
+	return &message
+}
+
+// Client is a raw Engine.IO client, it speaks the protocol through plain HTTP
+// requests and WebSocket frames so that every byte sent by the server is
+// checked. The requests failing at the HTTP level fail the test.
+type Client struct {
+	tb       testing.TB
+	url      string
+	protocol int
+	query    url.Values
+	http     *http.Client
+
+	// The session of the client, set by Open.
+	Sid string
+}
+
+// Creates a client of the server listening at rawURL, such as
+// "http://127.0.0.1:3000/engine.io/". The query is appended to every request,
+// such as b64 or j.
+func NewClient(tb testing.TB, rawURL string, protocol int, query url.Values) *Client {
+	if query == nil {
+		query = url.Values{}
+	}
+	return &Client{
+		tb:       tb,
+		url:      rawURL,
+		protocol: protocol,
+		query:    query,
+		http:     &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+func (c *Client) Protocol() int {
+	return c.protocol
+}
+
+func (c *Client) Parser() parser.Parser {
+	if c.protocol == 3 {
+		return parser.Parserv3()
+	}
+	return parser.Parserv4()
+}
+
+// Returns the URL of a request of transport, the session is added once open.
+func (c *Client) URL(transport string, query url.Values) string {
+	q := url.Values{}
+	for k, v := range c.query {
+		q[k] = v
+	}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("EIO", strconv.Itoa(c.protocol))
+	q.Set("transport", transport)
+	if c.Sid != "" && !q.Has("sid") {
+		q.Set("sid", c.Sid)
+	}
+	return c.url + "?" + q.Encode()
+}
+
+// Sends a request to the server.
+func (c *Client) Do(method, rawURL string, contentType string, body []byte) *Response {
+	c.tb.Helper()
+
+	req, err := http.NewRequest(method, rawURL, bytes.NewReader(body))
+	if err != nil {
+		c.tb.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		c.tb.Fatalf("%s %s failed: %v", method, rawURL, err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.tb.Fatalf("reading the response of %s %s failed: %v", method, rawURL, err)
+	}
+	return &Response{StatusCode: res.StatusCode, ContentType: res.Header.Get("Content-Type"), Body: data}
+}
+
+// Opens a session over polling and returns the fields of its open packet.
+func (c *Client) Open() *Handshake {
+	c.tb.Helper()
+
+	c.Sid = ""
+	packets := c.Packets(c.expectOK(c.Do(http.MethodGet, c.URL("polling", nil), "", nil)))
+	if len(packets) == 0 || packets[0].Type != packet.OPEN {
+		c.tb.Fatalf("handshake sent %v, want an open packet", packets)
+	}
+	handshake := ParseHandshake(c.tb, packets[0])
+	c.Sid = handshake.Sid
+	return handshake
+}
+
+// Sends a GET of the session and returns its response.
+func (c *Client) Poll() *Response {
+	c.tb.Helper()
+
+	return c.Do(http.MethodGet, c.URL("polling", nil), "", nil)
+}
+
+// Polls the packets of the session, the response must be successful.
+func (c *Client) PollPackets() []*packet.Packet {
+	c.tb.Helper()
+
+	return c.Packets(c.expectOK(c.Poll()))
+}
+
+// Sends a payload to the session, a []byte is sent as binary and a string as
+// text.
+func (c *Client) Post(payload any) *Response {
+	c.tb.Helper()
+
+	contentType, body := c.body(payload)
+	return c.Do(http.MethodPost, c.URL("polling", nil), contentType, body)
+}
+
+func (c *Client) body(payload any) (string, []byte) {
+	contentType := "text/plain;charset=UTF-8"
+	var body []byte
+	switch v := payload.(type) {
+	case []byte:
+		contentType, body = "application/octet-stream", v
+	case string:
+		body = []byte(v)
+	}
+	if c.query.Has("j") {
+		contentType, body = "application/x-www-form-urlencoded", []byte(url.Values{"d": {string(body)}}.Encode())
+	}
+	return contentType, body
+}
+
+// Sends the packets to the session, the server must answer "ok".
+func (c *Client) Send(packets ...*packet.Packet) {
+	c.tb.Helper()
+
+	if res := c.Post(c.encode(packets)); res.StatusCode != http.StatusOK || string(res.Body) != "ok" {
+		c.tb.Fatalf(`POST answered %d %q, want match for 200 "ok"`, res.StatusCode, res.Body)
+	}
+}
+
+// Sends packets closing the session, the server may drop the connection
+// instead of answering.
+func (c *Client) SendAndDrop(packets ...*packet.Packet) {
+	c.tb.Helper()
+
+	contentType, body := c.body(c.encode(packets))
+	if res, err := c.http.Post(c.URL("polling", nil), contentType, bytes.NewReader(body)); err == nil {
+		res.Body.Close()
+	}
+}
+
+// Encodes a payload, JSONP is text only like base64.
+func (c *Client) encode(packets []*packet.Packet) any {
+	c.tb.Helper()
+
+	payload, err := c.Parser().EncodePayload(packets, !c.query.Has("b64") && !c.query.Has("j"))
+	if err != nil {
+		c.tb.Fatal(err)
+	}
+	if _, ok := payload.(*types.BytesBuffer); ok {
+		return payload.Bytes()
+	}
+	return payload.String()
+}
+
+// Decodes the payload of a response, unwrapping the JSONP callback.
+func (c *Client) Packets(res *Response) []*packet.Packet {
+	c.tb.Helper()
+
+	body := res.Body
+	if c.query.Has("j") {
+		body = []byte(UnwrapJSONP(c.tb, c.query.Get("j"), body))
+	}
+	var payload types.BufferInterface = types.NewStringBuffer(body)
+	if strings.HasPrefix(res.ContentType, "application/octet-stream") {
+		payload = types.NewBytesBuffer(body)
+	}
+	return c.Parser().DecodePayload(payload)
+}
+
+// Polls until n packets are received, at most n polls are sent.
+func (c *Client) Receive(n int) (packets []*packet.Packet) {
+	c.tb.Helper()
+
+	for i := 0; i < n && len(packets) < n; i++ {
+		packets = append(packets, c.PollPackets()...)
+	}
+	if len(packets) < n {
+		c.tb.Fatalf("received %d packets, want match for %d", len(packets), n)
+	}
+	return packets
+}
+
+// Opens a WebSocket of the session, or a new session without one.
+func (c *Client) Dial(query url.Values) *websocket.Conn {
+	c.tb.Helper()
+
+	rawURL := "ws" + strings.TrimPrefix(c.URL("websocket", query), "http")
+	conn, res, err := websocket.DefaultDialer.Dial(rawURL, nil)
+	if err != nil {
+		status := 0
+		if res != nil {
+			status = res.StatusCode
+		}
+		c.tb.Fatalf("websocket %s failed with %d: %v", rawURL, status, err)
+	}
+	c.tb.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func (c *Client) expectOK(res *Response) *Response {
+	c.tb.Helper()
+
+	if res.StatusCode != http.StatusOK {
+		c.tb.Fatalf("server answered %d %q, want match for %d", res.StatusCode, res.Body, http.StatusOK)
+	}
+	return res
+}
+
+// Decodes the fields of an open packet.
+func ParseHandshake(tb testing.TB, p *packet.Packet) *Handshake {
+	tb.Helper()
+
+	var handshake Handshake
+	if err := json.NewDecoder(p.Data).Decode(&handshake); err != nil {
+		tb.Fatalf("open packet is not JSON: %v", err)
+	}
+	if handshake.Sid == "" {
+		tb.Fatal("open packet has no sid")
+	}
+	return &handshake
+}
+
+// Returns the payload passed to the JSONP callback of index j.
+func UnwrapJSONP(tb testing.TB, j string, body []byte) string {
+	tb.Helper()
+
+	head, foot := "___eio["+j+"](", ");"
+	if !bytes.HasPrefix(body, []byte(head)) || !bytes.HasSuffix(body, []byte(foot)) {
+		tb.Fatalf("JSONP body %q does not call %s", body, head)
+	}
+	var payload string
+	if err := json.Unmarshal(body[len(head):len(body)-len(foot)], &payload); err != nil {
+		tb.Fatalf("JSONP body %q does not hold a string: %v", body, err)
+	}
+	return payload
+}
+
+// Reads a frame of conn, within timeout.
+func ReadFrame(tb testing.TB, conn *websocket.Conn, timeout time.Duration) (int, []byte) {
+	tb.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		tb.Fatalf("reading a frame failed: %v", err)
+	}
+	return messageType, data
+}
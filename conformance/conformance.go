@@ -0,0 +1,271 @@
+// Package conformance checks a server against the Engine.IO protocol, revisions
+// 3 and 4, end to end over HTTP and WebSocket:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, conformance.EngineServer)
+//	}
+//
+// Any http.Handler speaking Engine.IO can be checked, such as a server relying
+// on third-party transports, and the Client lets them write their own checks.
+package conformance
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/engine"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// NewServer creates the server under test with the options of a check. The
+// handler serves Engine.IO on any path, and its sockets send back the messages
+// they receive.
+type NewServer func(opts *config.ServerOptions) http.Handler
+
+// EngineServer runs an engine.Server sending back the messages.
+func EngineServer(opts *config.ServerOptions) http.Handler {
+	server := engine.NewServer(opts)
+	engine.OnConnection(server, func(socket engine.Socket) {
+		engine.OnMessage(socket, func(data io.Reader, _ context.Context) {
+			// the data is only valid during the event
+			buf := new(bytes.Buffer)
+			buf.ReadFrom(data)
+			if _, ok := data.(*types.StringBuffer); ok {
+				socket.Send(types.NewStringBuffer(buf.Bytes()), nil, nil)
+			} else {
+				socket.Send(types.NewBytesBuffer(buf.Bytes()), nil, nil)
+			}
+		})
+	})
+	return server
+}
+
+// The error codes of the rejected requests.
+const (
+	UNKNOWN_TRANSPORT            = 0
+	UNKNOWN_SID                  = 1
+	BAD_HANDSHAKE_METHOD         = 2
+	BAD_REQUEST                  = 3
+	FORBIDDEN                    = 4
+	UNSUPPORTED_PROTOCOL_VERSION = 5
+)
+
+// Runs the checks of both revisions.
+func Run(t *testing.T, newServer NewServer) {
+	t.Run("v3", func(t *testing.T) {
+		RunV3(t, newServer)
+	})
+	t.Run("v4", func(t *testing.T) {
+		RunV4(t, newServer)
+	})
+}
+
+// The options of a check, the heartbeat must not interfere with the checks
+// not about it.
+func options(protocol int) *config.ServerOptions {
+	opts := config.DefaultServerOptions()
+	opts.SetPingInterval(10 * time.Second)
+	opts.SetPingTimeout(5 * time.Second)
+	opts.SetMaxHttpBufferSize(1e6)
+	opts.SetAllowEIO3(protocol == 3)
+	return opts
+}
+
+// Starts the server and returns the URL of Engine.IO.
+func start(t *testing.T, newServer NewServer, opts *config.ServerOptions) string {
+	handler := newServer(opts)
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	if server, ok := handler.(engine.Server); ok {
+		// answers the pending polls before the server waits for them
+		t.Cleanup(func() { server.Close() })
+	}
+	return srv.URL + "/engine.io/"
+}
+
+func message(data string) *packet.Packet {
+	return &packet.Packet{Type: packet.MESSAGE, Data: types.NewStringBufferString(data)}
+}
+
+func binary(data ...byte) *packet.Packet {
+	return &packet.Packet{Type: packet.MESSAGE, Data: types.NewBytesBuffer(data)}
+}
+
+// Compares the type, the data and the kind of data of the packets.
+func expectPackets(t *testing.T, got []*packet.Packet, want ...*packet.Packet) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("received %d packets, want match for %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].Type != want[i].Type {
+			t.Fatalf("packet %d is of type %q, want match for %q", i, got[i].Type, want[i].Type)
+		}
+		_, gotBinary := got[i].Data.(*types.BytesBuffer)
+		_, wantBinary := want[i].Data.(*types.BytesBuffer)
+		if gotBinary != wantBinary {
+			t.Fatalf("packet %d holds binary %t, want match for %t", i, gotBinary, wantBinary)
+		}
+		if g, w := readAll(got[i].Data), readAll(want[i].Data); !bytes.Equal(g, w) {
+			t.Fatalf("packet %d holds %q, want match for %q", i, g, w)
+		}
+	}
+}
+
+func readAll(r io.Reader) []byte {
+	if r == nil {
+		return nil
+	}
+	data, _ := io.ReadAll(r)
+	return data
+}
+
+// Checks that the response rejects the request with the code.
+func expectError(t *testing.T, res *Response, status int, code int) {
+	t.Helper()
+
+	if res.StatusCode != status {
+		t.Fatalf("server answered %d %q, want match for %d", res.StatusCode, res.Body, status)
+	}
+	if message := res.Error(t); message.Code != code {
+		t.Fatalf("server answered the error %d %q, want match for %d", message.Code, message.Message, code)
+	}
+}
+
+// Checks the rejected requests shared by both revisions.
+func checkErrors(t *testing.T, newServer NewServer, protocol int) {
+	c := NewClient(t, start(t, newServer, options(protocol)), protocol, nil)
+
+	t.Run("UnknownTransport", func(t *testing.T) {
+		expectError(t, c.Do(http.MethodGet, c.URL("tobi", nil), "", nil), http.StatusBadRequest, UNKNOWN_TRANSPORT)
+	})
+
+	t.Run("UnknownSid", func(t *testing.T) {
+		res := c.Do(http.MethodGet, c.URL("polling", map[string][]string{"sid": {"unknown"}}), "", nil)
+		expectError(t, res, http.StatusBadRequest, UNKNOWN_SID)
+	})
+
+	t.Run("BadHandshakeMethod", func(t *testing.T) {
+		expectError(t, c.Do(http.MethodPost, c.URL("polling", nil), "text/plain", nil), http.StatusBadRequest, BAD_HANDSHAKE_METHOD)
+	})
+
+	t.Run("WebSocketWithoutUpgrade", func(t *testing.T) {
+		expectError(t, c.Do(http.MethodGet, c.URL("websocket", nil), "", nil), http.StatusBadRequest, BAD_REQUEST)
+	})
+
+	t.Run("TransportMismatch", func(t *testing.T) {
+		c := NewClient(t, c.url, protocol, nil)
+		c.Open()
+		expectError(t, c.Do(http.MethodGet, c.URL("websocket", nil), "", nil), http.StatusBadRequest, BAD_REQUEST)
+	})
+}
+
+// Checks the upgrade of a polling session to WebSocket: the probe is answered,
+// the pending poll is released with a noop packet and the messages flow over
+// the WebSocket once upgraded.
+func checkUpgrade(t *testing.T, c *Client) {
+	handshake := c.Open()
+	if len(handshake.Upgrades) != 1 || handshake.Upgrades[0] != "websocket" {
+		t.Fatalf("handshake offers the upgrades %v, want match for [websocket]", handshake.Upgrades)
+	}
+
+	// the server releases the poll with a noop while upgrading, whether it
+	// reaches the server before or after the probe, so the upgrade packet is
+	// only sent once the poll is released
+	pending := make(chan []byte, 1)
+	go func() {
+		res, err := c.http.Get(c.URL("polling", nil))
+		if err != nil {
+			pending <- nil
+			return
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		pending <- body
+	}()
+
+	conn := c.Dial(nil)
+	conn.WriteMessage(websocket.TextMessage, []byte("2probe"))
+	if _, frame := ReadFrame(t, conn, 5*time.Second); string(frame) != "3probe" {
+		t.Fatalf("probe answered %q, want match for %q", frame, "3probe")
+	}
+
+	select {
+	case body := <-pending:
+		noop := "6"
+		if c.protocol == 3 {
+			noop = "1:6"
+		}
+		if string(body) != noop {
+			t.Fatalf("pending poll answered %q, want match for %q", body, noop)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending poll not released by the upgrade")
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte("5"))
+	conn.WriteMessage(websocket.TextMessage, []byte("4hello"))
+	if _, frame := ReadFrame(t, conn, 5*time.Second); string(frame) != "4hello" {
+		t.Fatalf("message sent back as %q, want match for %q", frame, "4hello")
+	}
+
+	// the binary frames hold the packet type in v3 only
+	data := []byte{1, 2, 3, 4}
+	if c.protocol == 3 {
+		data = []byte{4, 1, 2, 3, 4}
+	}
+	conn.WriteMessage(websocket.BinaryMessage, data)
+	if messageType, frame := ReadFrame(t, conn, 5*time.Second); messageType != websocket.BinaryMessage || !bytes.Equal(frame, data) {
+		t.Fatalf("binary message sent back as %d %v, want match for %d %v", messageType, frame, websocket.BinaryMessage, data)
+	}
+
+	// the session is gone from polling
+	expectError(t, c.Poll(), http.StatusBadRequest, BAD_REQUEST)
+
+	// the client closes the WebSocket after the close packet, which ends the
+	// session
+	conn.WriteMessage(websocket.TextMessage, []byte("1"))
+	conn.Close()
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		res := c.Poll()
+		if res.StatusCode == http.StatusBadRequest && res.Error(t).Code == UNKNOWN_SID {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("closed session answered %d %q, want match for %d", res.StatusCode, res.Body, UNKNOWN_SID)
+		}
+	}
+}
+
+// Checks a session opened over WebSocket.
+func checkWebSocket(t *testing.T, c *Client, maxPayload int64) {
+	conn := c.Dial(nil)
+	_, frame := ReadFrame(t, conn, 5*time.Second)
+	if len(frame) == 0 || frame[0] != '0' {
+		t.Fatalf("WebSocket opened with %q, want an open packet", frame)
+	}
+	handshake := ParseHandshake(t, &packet.Packet{Type: packet.OPEN, Data: bytes.NewReader(frame[1:])})
+	if len(handshake.Upgrades) != 0 {
+		t.Fatalf("WebSocket handshake offers the upgrades %v, want none", handshake.Upgrades)
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte("4€"))
+	if _, frame := ReadFrame(t, conn, 5*time.Second); string(frame) != "4€" {
+		t.Fatalf("message sent back as %q, want match for %q", frame, "4€")
+	}
+
+	conn.WriteMessage(websocket.TextMessage, append([]byte("4"), bytes.Repeat([]byte{'a'}, int(maxPayload))...))
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := conn.ReadMessage(); err == nil {
+		t.Fatal("frame above maxPayload did not close the WebSocket")
+	}
+}
@@ -0,0 +1,9 @@
+package conformance
+
+import (
+	"testing"
+)
+
+func TestEngineServer(t *testing.T) {
+	Run(t, EngineServer)
+}
@@ -0,0 +1,148 @@
+package conformance
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/packet"
+)
+
+// Runs the checks of the protocol revision 3, the server must allow it.
+func RunV3(t *testing.T, newServer NewServer) {
+	t.Run("Handshake", func(t *testing.T) {
+		opts := options(3)
+		opts.SetPingInterval(300 * time.Millisecond)
+		opts.SetPingTimeout(200 * time.Millisecond)
+		c := NewClient(t, start(t, newServer, opts), 3, nil)
+
+		res := c.expectOK(c.Poll())
+		// the open packet is framed by its length
+		if i := bytes.IndexByte(res.Body, ':'); i < 1 || len(res.Body) <= i+1 || res.Body[i+1] != '0' {
+			t.Fatalf("handshake answered %q, want a framed open packet", res.Body)
+		}
+		handshake := ParseHandshake(t, c.Packets(res)[0])
+		if handshake.PingInterval != 300 || handshake.PingTimeout != 200 {
+			t.Fatalf("handshake = %+v, want match for pingInterval 300 and pingTimeout 200", handshake)
+		}
+		if len(handshake.Upgrades) != 1 || handshake.Upgrades[0] != "websocket" {
+			t.Fatalf("handshake offers the upgrades %v, want match for [websocket]", handshake.Upgrades)
+		}
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		opts := options(3)
+		opts.SetPingInterval(100 * time.Millisecond)
+		opts.SetPingTimeout(200 * time.Millisecond)
+		c := NewClient(t, start(t, newServer, opts), 3, nil)
+		c.Open()
+
+		// the client pings, the server answers
+		for i := 0; i < 2; i++ {
+			c.Send(&packet.Packet{Type: packet.PING})
+			expectPackets(t, c.PollPackets(), &packet.Packet{Type: packet.PONG})
+		}
+
+		// a missing ping closes the session
+		time.Sleep(500 * time.Millisecond)
+		expectError(t, c.Poll(), http.StatusBadRequest, UNKNOWN_SID)
+	})
+
+	t.Run("HeartbeatDirection", func(t *testing.T) {
+		c := NewClient(t, start(t, newServer, options(3)), 3, nil)
+		c.Open()
+
+		// the servers do not ping in v3
+		c.SendAndDrop(&packet.Packet{Type: packet.PONG})
+		expectError(t, c.Poll(), http.StatusBadRequest, UNKNOWN_SID)
+	})
+
+	t.Run("Payload", func(t *testing.T) {
+		c := NewClient(t, start(t, newServer, options(3)), 3, nil)
+		c.Open()
+
+		// the packets are prefixed by their length in UTF-16 code units
+		if res := c.Post("6:4hello3:4😀"); res.StatusCode != http.StatusOK {
+			t.Fatalf("POST answered %d %q, want match for %d", res.StatusCode, res.Body, http.StatusOK)
+		}
+		res := c.expectOK(c.Poll())
+		if string(res.Body) != "6:4hello3:4😀" {
+			t.Fatalf("poll answered %q, want match for %q", res.Body, "6:4hello3:4😀")
+		}
+		expectPackets(t, c.Packets(res), message("hello"), message("😀"))
+	})
+
+	t.Run("BinaryPayload", func(t *testing.T) {
+		c := NewClient(t, start(t, newServer, options(3)), 3, nil)
+		c.Open()
+
+		// <0 = string, 1 = binary><length digits>255<packet>
+		payload := []byte{0, 4, 255, '4', 0xe2, 0x82, 0xac, 1, 5, 255, 4, 1, 2, 3, 4}
+		if res := c.Post(payload); res.StatusCode != http.StatusOK {
+			t.Fatalf("POST answered %d %q, want match for %d", res.StatusCode, res.Body, http.StatusOK)
+		}
+		res := c.expectOK(c.Poll())
+		if res.ContentType != "application/octet-stream" || !bytes.Equal(res.Body, payload) {
+			t.Fatalf("poll answered %q %v, want match for %q %v", res.ContentType, res.Body, "application/octet-stream", payload)
+		}
+		expectPackets(t, c.Packets(res), message("€"), binary(1, 2, 3, 4))
+	})
+
+	t.Run("Base64", func(t *testing.T) {
+		c := NewClient(t, start(t, newServer, options(3)), 3, url.Values{"b64": {"1"}})
+		c.Open()
+
+		if res := c.Post("10:b4AQIDBA=="); res.StatusCode != http.StatusOK {
+			t.Fatalf("POST answered %d %q, want match for %d", res.StatusCode, res.Body, http.StatusOK)
+		}
+		res := c.expectOK(c.Poll())
+		if string(res.Body) != "10:b4AQIDBA==" {
+			t.Fatalf("poll answered %q, want match for %q", res.Body, "10:b4AQIDBA==")
+		}
+		expectPackets(t, c.Packets(res), binary(1, 2, 3, 4))
+	})
+
+	t.Run("JSONP", func(t *testing.T) {
+		// the JSONP clients ask for base64 as the payload is a JS string
+		c := NewClient(t, start(t, newServer, options(3)), 3, url.Values{"j": {"1"}, "b64": {"1"}})
+		c.Open()
+
+		c.Send(message("hello\nworld"), binary(1, 2, 3, 4))
+		expectPackets(t, c.Receive(2), message("hello\nworld"), binary(1, 2, 3, 4))
+	})
+
+	t.Run("Upgrade", func(t *testing.T) {
+		checkUpgrade(t, NewClient(t, start(t, newServer, options(3)), 3, nil))
+	})
+
+	t.Run("WebSocket", func(t *testing.T) {
+		opts := options(3)
+		opts.SetMaxHttpBufferSize(1000)
+		checkWebSocket(t, NewClient(t, start(t, newServer, opts), 3, nil), 1000)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		c := NewClient(t, start(t, newServer, options(3)), 3, nil)
+		c.Open()
+
+		c.Send(&packet.Packet{Type: packet.CLOSE})
+		expectError(t, c.Poll(), http.StatusBadRequest, UNKNOWN_SID)
+	})
+
+	t.Run("MaxPayload", func(t *testing.T) {
+		opts := options(3)
+		opts.SetMaxHttpBufferSize(100)
+		c := NewClient(t, start(t, newServer, opts), 3, nil)
+		c.Open()
+
+		if res := c.Post("201:4" + string(make([]byte, 200))); res.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Fatalf("POST above maxHttpBufferSize answered %d, want match for %d", res.StatusCode, http.StatusRequestEntityTooLarge)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		checkErrors(t, newServer, 3)
+	})
+}
@@ -0,0 +1,141 @@
+package conformance
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/packet"
+)
+
+// Runs the checks of the protocol revision 4.
+func RunV4(t *testing.T, newServer NewServer) {
+	t.Run("Handshake", func(t *testing.T) {
+		opts := options(4)
+		opts.SetPingInterval(300 * time.Millisecond)
+		opts.SetPingTimeout(200 * time.Millisecond)
+		opts.SetMaxHttpBufferSize(1000)
+		c := NewClient(t, start(t, newServer, opts), 4, nil)
+
+		res := c.expectOK(c.Poll())
+		if res.ContentType != "text/plain; charset=UTF-8" {
+			t.Fatalf("handshake Content-Type = %q, want match for %q", res.ContentType, "text/plain; charset=UTF-8")
+		}
+		if len(res.Body) == 0 || res.Body[0] != '0' {
+			t.Fatalf("handshake answered %q, want an unframed open packet", res.Body)
+		}
+		handshake := ParseHandshake(t, c.Packets(res)[0])
+		if handshake.PingInterval != 300 || handshake.PingTimeout != 200 || handshake.MaxPayload != 1000 {
+			t.Fatalf("handshake = %+v, want match for pingInterval 300, pingTimeout 200 and maxPayload 1000", handshake)
+		}
+		if len(handshake.Upgrades) != 1 || handshake.Upgrades[0] != "websocket" {
+			t.Fatalf("handshake offers the upgrades %v, want match for [websocket]", handshake.Upgrades)
+		}
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		opts := options(4)
+		opts.SetPingInterval(100 * time.Millisecond)
+		opts.SetPingTimeout(200 * time.Millisecond)
+		c := NewClient(t, start(t, newServer, opts), 4, nil)
+		c.Open()
+
+		// the server pings, the client answers
+		for i := 0; i < 2; i++ {
+			expectPackets(t, c.PollPackets(), &packet.Packet{Type: packet.PING})
+			c.Send(&packet.Packet{Type: packet.PONG})
+		}
+
+		// a missing pong closes the session
+		time.Sleep(500 * time.Millisecond)
+		expectError(t, c.Poll(), http.StatusBadRequest, UNKNOWN_SID)
+	})
+
+	t.Run("HeartbeatDirection", func(t *testing.T) {
+		c := NewClient(t, start(t, newServer, options(4)), 4, nil)
+		c.Open()
+
+		// the clients do not ping in v4
+		c.SendAndDrop(&packet.Packet{Type: packet.PING})
+		expectError(t, c.Poll(), http.StatusBadRequest, UNKNOWN_SID)
+	})
+
+	t.Run("Payload", func(t *testing.T) {
+		c := NewClient(t, start(t, newServer, options(4)), 4, nil)
+		c.Open()
+
+		// the packets are separated by \x1e, the binary ones are in base64
+		if res := c.Post("4hello\x1e4€\x1ebAQIDBA=="); res.StatusCode != http.StatusOK {
+			t.Fatalf("POST answered %d %q, want match for %d", res.StatusCode, res.Body, http.StatusOK)
+		}
+		res := c.expectOK(c.Poll())
+		if string(res.Body) != "4hello\x1e4€\x1ebAQIDBA==" {
+			t.Fatalf("poll answered %q, want match for %q", res.Body, "4hello\x1e4€\x1ebAQIDBA==")
+		}
+		expectPackets(t, c.Packets(res), message("hello"), message("€"), binary(1, 2, 3, 4))
+
+		// binary payloads are not part of v4
+		if res := c.Post([]byte{4, 1, 2, 3, 4}); res.StatusCode == http.StatusOK {
+			t.Fatalf("binary POST answered %d, want an error", res.StatusCode)
+		}
+	})
+
+	t.Run("Base64", func(t *testing.T) {
+		c := NewClient(t, start(t, newServer, options(4)), 4, url.Values{"b64": {"1"}})
+		c.Open()
+
+		c.Send(binary(1, 2, 3, 4))
+		expectPackets(t, c.Receive(1), binary(1, 2, 3, 4))
+	})
+
+	t.Run("JSONP", func(t *testing.T) {
+		c := NewClient(t, start(t, newServer, options(4)), 4, url.Values{"j": {"0"}})
+		c.Open()
+
+		c.Send(message("hello\nworld"), binary(1, 2, 3, 4))
+		expectPackets(t, c.Receive(2), message("hello\nworld"), binary(1, 2, 3, 4))
+	})
+
+	t.Run("Upgrade", func(t *testing.T) {
+		checkUpgrade(t, NewClient(t, start(t, newServer, options(4)), 4, nil))
+	})
+
+	t.Run("WebSocket", func(t *testing.T) {
+		opts := options(4)
+		opts.SetMaxHttpBufferSize(1000)
+		checkWebSocket(t, NewClient(t, start(t, newServer, opts), 4, nil), 1000)
+	})
+
+	t.Run("Close", func(t *testing.T) {
+		c := NewClient(t, start(t, newServer, options(4)), 4, nil)
+		c.Open()
+
+		c.Send(&packet.Packet{Type: packet.CLOSE})
+		expectError(t, c.Poll(), http.StatusBadRequest, UNKNOWN_SID)
+	})
+
+	t.Run("MaxPayload", func(t *testing.T) {
+		opts := options(4)
+		opts.SetMaxHttpBufferSize(100)
+		c := NewClient(t, start(t, newServer, opts), 4, nil)
+		if handshake := c.Open(); handshake.MaxPayload != 100 {
+			t.Fatalf("handshake advertises maxPayload %d, want match for %d", handshake.MaxPayload, 100)
+		}
+
+		payload := "4" + string(make([]byte, 200))
+		if res := c.Post(payload); res.StatusCode != http.StatusRequestEntityTooLarge {
+			t.Fatalf("POST above maxPayload answered %d, want match for %d", res.StatusCode, http.StatusRequestEntityTooLarge)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		checkErrors(t, newServer, 4)
+
+		t.Run("UnsupportedProtocolVersion", func(t *testing.T) {
+			// v3 is disabled by default
+			c := NewClient(t, start(t, newServer, options(4)), 3, nil)
+			expectError(t, c.Poll(), http.StatusBadRequest, UNSUPPORTED_PROTOCOL_VERSION)
+		})
+	})
+}
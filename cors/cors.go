@@ -0,0 +1,201 @@
+// Package cors builds a validated types.Cors:
+//
+//	options, err := cors.AllowOrigins("https://example.com").
+//		AllowMethods(http.MethodGet, http.MethodPost).
+//		AllowCredentials().
+//		MaxAge(10 * time.Minute).
+//		Build()
+package cors
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zishang520/engine.io/errors"
+	"github.com/zishang520/engine.io/types"
+)
+
+// Builder collects the cors options, the invalid ones are reported by Build.
+type Builder struct {
+	cors      types.Cors
+	origins   []any
+	anyOrigin bool
+	err       error
+}
+
+// Returns an empty Builder, which allows any origin.
+func New() *Builder {
+	return &Builder{}
+}
+
+func AllowOrigins(origins ...string) *Builder {
+	return New().AllowOrigins(origins...)
+}
+
+func AllowOriginRegex(patterns ...string) *Builder {
+	return New().AllowOriginRegex(patterns...)
+}
+
+func AllowOriginFunc(fn types.OriginFunc) *Builder {
+	return New().AllowOriginFunc(fn)
+}
+
+// Allows the given origins, "*" allows any origin. An origin is a scheme and a
+// host with an optional port, such as "https://example.com:8443".
+func (b *Builder) AllowOrigins(origins ...string) *Builder {
+	for _, origin := range origins {
+		if origin == "*" {
+			b.anyOrigin = true
+			continue
+		}
+		if err := validateOrigin(origin); err != nil {
+			b.fail(err)
+			continue
+		}
+		b.origins = append(b.origins, origin)
+	}
+	return b
+}
+
+// Allows the origins matching one of the regular expressions.
+func (b *Builder) AllowOriginRegex(patterns ...string) *Builder {
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			b.fail(invalid(`origin regex "%s": %v`, pattern, err))
+			continue
+		}
+		b.origins = append(b.origins, re)
+	}
+	return b
+}
+
+// Allows the origins accepted by fn, see types.CachedOrigin to cache its
+// decisions.
+func (b *Builder) AllowOriginFunc(fn types.OriginFunc) *Builder {
+	if fn == nil {
+		b.fail(invalid("origin func is nil"))
+	} else {
+		b.origins = append(b.origins, fn)
+	}
+	return b
+}
+
+// Sets the methods allowed by the preflights, GET,HEAD,PUT,PATCH,POST,DELETE
+// by default.
+func (b *Builder) AllowMethods(methods ...string) *Builder {
+	b.cors.Methods = appendTokens(b.cors.Methods, methods)
+	return b
+}
+
+// Sets the headers allowed by the preflights, the requested headers are
+// reflected by default.
+func (b *Builder) AllowHeaders(headers ...string) *Builder {
+	b.cors.AllowedHeaders = appendTokens(b.cors.AllowedHeaders, headers)
+	return b
+}
+
+// Sets the response headers exposed to the scripts.
+func (b *Builder) ExposeHeaders(headers ...string) *Builder {
+	b.cors.ExposedHeaders = appendTokens(b.cors.ExposedHeaders, headers)
+	return b
+}
+
+// Sets how long the preflights are cached, truncated to the second.
+func (b *Builder) MaxAge(maxAge time.Duration) *Builder {
+	if maxAge < 0 {
+		b.fail(invalid("max age %s is negative", maxAge))
+	} else {
+		b.cors.MaxAge = strconv.FormatInt(int64(maxAge/time.Second), 10)
+	}
+	return b
+}
+
+// Allows the requests with credentials, which cannot be combined with "*".
+func (b *Builder) AllowCredentials() *Builder {
+	b.cors.Credentials = true
+	return b
+}
+
+// Answers the preflights of the Private Network Access.
+func (b *Builder) AllowPrivateNetwork() *Builder {
+	b.cors.AllowPrivateNetwork = true
+	return b
+}
+
+// Passes the preflights on to the next handler instead of answering them.
+func (b *Builder) PreflightContinue() *Builder {
+	b.cors.PreflightContinue = true
+	return b
+}
+
+// Sets the status of the answered preflights, 204 by default.
+func (b *Builder) OptionsSuccessStatus(status int) *Builder {
+	b.cors.OptionsSuccessStatus = status
+	return b
+}
+
+// Returns the options, or the first invalid option met.
+func (b *Builder) Build() (*types.Cors, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+
+	options := b.cors
+	switch {
+	case b.anyOrigin && len(b.origins) > 0:
+		return nil, invalid(`origin "*" cannot be combined with other origins`)
+	case b.anyOrigin || len(b.origins) == 0:
+		if options.Credentials {
+			// browsers reject the credentialed responses allowing any origin
+			return nil, invalid(`origin "*" cannot be combined with credentials`)
+		}
+		options.Origin = "*"
+	case len(b.origins) == 1:
+		if origin, ok := b.origins[0].(string); ok {
+			// reflected anyway, so that the other origins are refused
+			options.Origin = []any{origin}
+		} else {
+			options.Origin = b.origins[0]
+		}
+	default:
+		options.Origin = append([]any(nil), b.origins...)
+	}
+
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	return &options, nil
+}
+
+func (b *Builder) fail(err error) {
+	if b.err == nil {
+		b.err = err
+	}
+}
+
+// The tokens are validated by types.Cors.Validate.
+func appendTokens(current any, tokens []string) []string {
+	values, _ := current.([]string)
+	return append(values, tokens...)
+}
+
+func invalid(format string, args ...any) error {
+	return errors.New("cors: " + fmt.Sprintf(format, args...)).WithCode(types.ErrInvalidCors.Code).Err()
+}
+
+func validateOrigin(origin string) error {
+	u, err := url.Parse(origin)
+	if err != nil || u.Scheme == "" || u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return invalid(`origin "%s" is not a scheme and a host`, origin)
+	}
+	if strings.HasSuffix(origin, "/") {
+		// browsers send the origins without a trailing slash
+		return invalid(`origin "%s" ends with a slash`, origin)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package cors
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/errors"
+	"github.com/zishang520/engine.io/types"
+)
+
+func TestBuilder(t *testing.T) {
+	t.Run("Build", func(t *testing.T) {
+		options, err := AllowOrigins("https://example.com").
+			AllowOriginRegex(`^https://(.+\.)?example\.org$`).
+			AllowMethods(http.MethodGet, http.MethodPost).
+			AllowHeaders("X-Token").
+			ExposeHeaders("X-Request-Id").
+			MaxAge(10 * time.Minute).
+			AllowCredentials().
+			AllowPrivateNetwork().
+			Build()
+		if err != nil {
+			t.Fatalf(`*Builder.Build() = %v, want match for nil`, err)
+		}
+
+		origins, ok := options.Origin.([]any)
+		if !ok || len(origins) != 2 || origins[0] != "https://example.com" {
+			t.Fatalf(`Origin = %v, want match for the two origins`, options.Origin)
+		}
+		if _, ok := origins[1].(*regexp.Regexp); !ok {
+			t.Fatalf(`Origin[1] = %T, want match for %T`, origins[1], &regexp.Regexp{})
+		}
+		if options.MaxAge != "600" {
+			t.Fatalf(`MaxAge = %q, want match for %q`, options.MaxAge, "600")
+		}
+		if !options.Credentials || !options.AllowPrivateNetwork {
+			t.Fatal("*Builder.Build() should keep the credentials and the private network")
+		}
+		for origin, want := range map[string]bool{
+			"https://example.com":     true,
+			"https://app.example.org": true,
+			"https://evil.com":        false,
+		} {
+			if allowed, err := options.IsOriginAllowed(origin, nil); err != nil || allowed != want {
+				t.Fatalf(`*Cors.IsOriginAllowed(%q) = %t, %v, want match for %t`, origin, allowed, err, want)
+			}
+		}
+	})
+
+	t.Run("AnyOrigin", func(t *testing.T) {
+		options, err := New().Build()
+		if err != nil || options.Origin != "*" {
+			t.Fatalf(`*Builder.Build() = %v, %v, want match for "*"`, options, err)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		for name, builder := range map[string]*Builder{
+			"origin with path":     AllowOrigins("https://example.com/app"),
+			"origin without host":  AllowOrigins("example.com"),
+			"origin regex":         AllowOriginRegex(`(`),
+			"nil origin func":      AllowOriginFunc(nil),
+			"any with origins":     AllowOrigins("*", "https://example.com"),
+			"any with credentials": AllowOrigins("*").AllowCredentials(),
+			"method":               New().AllowMethods("GET POST"),
+			"header":               New().AllowHeaders("X:Token"),
+			"negative max age":     New().MaxAge(-time.Second),
+			"success status":       New().OptionsSuccessStatus(302),
+		} {
+			if _, err := builder.Build(); !errors.Is(err, types.ErrInvalidCors) {
+				t.Fatalf(`*Builder.Build() with %s = %v, want match for %v`, name, err, types.ErrInvalidCors)
+			}
+		}
+	})
+}
@@ -1,12 +1,18 @@
 package engine
 
 import (
+	"context"
 	"net/http"
+	"strconv"
 	"sync"
 	"sync/atomic"
 
 	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/errors"
 	"github.com/zishang520/engine.io/events"
+	"github.com/zishang520/engine.io/log"
+	"github.com/zishang520/engine.io/metrics"
+	"github.com/zishang520/engine.io/tracing"
 	"github.com/zishang520/engine.io/transports"
 	"github.com/zishang520/engine.io/types"
 	"github.com/zishang520/engine.io/utils"
@@ -40,6 +46,7 @@ type server struct {
 	clientsCount   uint64
 	corsMiddleware func(*types.HttpContext, types.Callable)
 	opts           config.ServerOptionsInterface
+	log            *log.Log
 
 	httpServer *types.HttpServer
 }
@@ -61,6 +68,7 @@ func (s *server) New(opt any) *server {
 	atomic.StoreUint64(&s.clientsCount, 0)
 
 	s.opts = config.DefaultServerOptions().Assign(opts)
+	s.log = server_log.WithLogger(s.opts.Logger())
 
 	if opts != nil {
 		if cookie := opts.Cookie(); cookie != nil {
@@ -80,6 +88,15 @@ func (s *server) New(opt any) *server {
 		}
 
 		if cors := s.opts.Cors(); cors != nil {
+			if err := cors.Validate(); err != nil {
+				panic(err)
+			}
+			if allowedOrigins := s.opts.AllowedOrigins(); allowedOrigins != nil && cors.Origin == nil {
+				// the options of the caller are left untouched
+				withOrigin := *cors
+				withOrigin.Origin = allowedOrigins
+				cors = &withOrigin
+			}
 			s.corsMiddleware = types.MiddlewareWrapper(cors)
 		}
 	}
@@ -115,19 +132,43 @@ func (s *server) Upgrades(transport string) *types.Set[string] {
 	return transports.Transports()[transport].UpgradesTo
 }
 
+// Records a rejected handshake, request or upgrade.
+func (s *server) recordError(errorCode int) {
+	s.opts.Metrics().Count(metrics.CONNECTION_ERRORS, 1, metrics.Labels{"code": strconv.Itoa(errorCode)})
+}
+
+// Returns the context of the request carrying the span context of its `traceparent` header.
+func remoteTraceContext(ctx *types.HttpContext) context.Context {
+	if sc, ok := tracing.ParseTraceparent(ctx.Headers().Peek("Traceparent"), ctx.Headers().Peek("Tracestate")); ok {
+		return tracing.ContextWithSpanContext(ctx.Context(), sc)
+	}
+	return ctx.Context()
+}
+
+// Ends the span of a request with its response status.
+func endRequestSpan(ctx *types.HttpContext, span tracing.Span) {
+	statusCode := ctx.GetStatusCode()
+	span.SetAttributes(tracing.Attributes{"http.status_code": statusCode})
+	if statusCode >= http.StatusBadRequest {
+		span.End(errors.New(http.StatusText(statusCode)).Err())
+		return
+	}
+	span.End(nil)
+}
+
 // Verifies a request.
 func (s *server) Verify(ctx *types.HttpContext, upgrade bool) (int, map[string]any) {
 	// transport check
 	transport := ctx.Query().Peek("transport")
 	if !s.opts.Transports().Has(transport) {
-		server_log.Debug(`unknown transport "%s"`, transport)
+		s.log.Debug(`unknown transport "%s"`, transport)
 		return UNKNOWN_TRANSPORT, map[string]any{"transport": transport}
 	}
 
 	// 'Origin' header check
 	if origin := ctx.Headers().Peek("Origin"); utils.CheckInvalidHeaderChar(origin) {
 		ctx.Headers().Remove("Origin")
-		server_log.Debug("origin header invalid")
+		s.log.Debug("origin header invalid")
 		return BAD_REQUEST, map[string]any{"name": "INVALID_ORIGIN", "origin": origin}
 	}
 
@@ -136,11 +177,11 @@ func (s *server) Verify(ctx *types.HttpContext, upgrade bool) (int, map[string]a
 	if len(sid) > 0 {
 		scoket, ok := s.clients.Load(sid)
 		if !ok {
-			server_log.Debug(`unknown sid "%s"`, sid)
+			s.log.Debug(`unknown sid "%s"`, sid)
 			return UNKNOWN_SID, map[string]any{"sid": sid}
 		}
 		if previousTransport := scoket.(Socket).Transport().Name(); !upgrade && previousTransport != transport {
-			server_log.Debug("bad request: unexpected transport without upgrade")
+			s.log.Debug("bad request: unexpected transport without upgrade")
 			return BAD_REQUEST, map[string]any{"name": "TRANSPORT_MISMATCH", "transport": transport, "previousTransport": previousTransport}
 		}
 	} else {
@@ -150,7 +191,7 @@ func (s *server) Verify(ctx *types.HttpContext, upgrade bool) (int, map[string]a
 		}
 
 		if transport == "websocket" && !upgrade {
-			server_log.Debug("invalid transport upgrade")
+			s.log.Debug("invalid transport upgrade")
 			return BAD_REQUEST, map[string]any{"name": "TRANSPORT_HANDSHAKE_ERROR"}
 		}
 
@@ -166,7 +207,7 @@ func (s *server) Verify(ctx *types.HttpContext, upgrade bool) (int, map[string]a
 
 // Closes all clients.
 func (s *server) Close() Server {
-	server_log.Debug("closing all open clients")
+	s.log.Debug("closing all open clients")
 	s.clients.Range(func(_, client any) bool {
 		client.(Socket).Close(true)
 		return true
@@ -188,9 +229,16 @@ func (s *server) Handshake(transportName string, ctx *types.HttpContext) (int, m
 		protocol = 4
 	}
 
+	traceCtx, span := tracing.Start(s.opts.Tracer(), ctx.Context(), "engine.handshake", tracing.Attributes{
+		"transport": transportName,
+		"protocol":  protocol,
+	})
+
 	if protocol == 3 && !s.opts.AllowEIO3() {
-		server_log.Debug("unsupported protocol version")
-		s.Emit("connection_error", &types.ErrorMessage{
+		s.log.Debug("unsupported protocol version")
+		s.recordError(UNSUPPORTED_PROTOCOL_VERSION)
+		span.End(errors.New(errorMessages[UNSUPPORTED_PROTOCOL_VERSION]).Err())
+		s.Emit(EVENT_CONNECTION_ERROR, &types.ErrorMessage{
 			CodeMessage: &types.CodeMessage{
 				Code:    UNSUPPORTED_PROTOCOL_VERSION,
 				Message: errorMessages[UNSUPPORTED_PROTOCOL_VERSION],
@@ -205,8 +253,10 @@ func (s *server) Handshake(transportName string, ctx *types.HttpContext) (int, m
 
 	id, err := s.GenerateId(ctx)
 	if err != nil {
-		server_log.Debug("error while generating an id")
-		s.Emit("connection_error", &types.ErrorMessage{
+		s.log.Debug("error while generating an id")
+		s.recordError(BAD_REQUEST)
+		span.End(err)
+		s.Emit(EVENT_CONNECTION_ERROR, &types.ErrorMessage{
 			CodeMessage: &types.CodeMessage{
 				Code:    BAD_REQUEST,
 				Message: errorMessages[BAD_REQUEST],
@@ -220,12 +270,14 @@ func (s *server) Handshake(transportName string, ctx *types.HttpContext) (int, m
 		return BAD_REQUEST, map[string]any{"name": "ID_GENERATION_ERROR", "error": err}, nil
 	}
 
-	server_log.Debug(`handshaking client "%s"`, id)
+	s.log.With("sid", id, "remote", ctx.Request().RemoteAddr, "transport", transportName).Debug("handshaking client")
 
 	transport, err := s.CreateTransport(transportName, ctx)
 	if err != nil {
-		server_log.Debug(`error handshaking to transport "%s"`, transportName)
-		s.Emit("connection_error", &types.ErrorMessage{
+		s.log.Debug(`error handshaking to transport "%s"`, transportName)
+		s.recordError(BAD_REQUEST)
+		span.End(err)
+		s.Emit(EVENT_CONNECTION_ERROR, &types.ErrorMessage{
 			CodeMessage: &types.CodeMessage{
 				Code:    BAD_REQUEST,
 				Message: errorMessages[BAD_REQUEST],
@@ -240,6 +292,8 @@ func (s *server) Handshake(transportName string, ctx *types.HttpContext) (int, m
 	}
 	if "polling" == transportName {
 		transport.SetMaxHttpBufferSize(s.opts.MaxHttpBufferSize())
+		transport.SetMaxPacketSize(s.opts.MaxPacketSize())
+		transport.SetMaxPacketsPerPayload(s.opts.MaxPacketsPerPayload())
 		transport.SetGttpCompression(s.opts.HttpCompression())
 	} else if "websocket" == transportName {
 		transport.SetPerMessageDeflate(s.opts.PerMessageDeflate())
@@ -251,17 +305,22 @@ func (s *server) Handshake(transportName string, ctx *types.HttpContext) (int, m
 		transport.SetSupportsBinary(true)
 	}
 
+	// the socket continues the trace of its handshake
+	span.SetAttributes(tracing.Attributes{"sid": id})
+	ctx.SetContext(traceCtx)
+	defer span.End(nil)
+
 	socket := NewSocket(id, s, transport, ctx, protocol)
 
-	transport.On("headers", func(args ...any) {
+	transport.On(transports.EVENT_HEADERS, func(args ...any) {
 		headers, req := args[0].(*utils.ParameterBag), args[1].(*types.HttpContext)
 		if !ctx.Query().Has("sid") {
 			if cookie := s.opts.Cookie(); cookie != nil {
 				headers.Set("Set-Cookie", cookie.String())
 			}
-			s.Emit("initial_headers", headers, req)
+			s.Emit(EVENT_INITIAL_HEADERS, headers, req)
 		}
-		s.Emit("headers", headers, req)
+		s.Emit(EVENT_HEADERS, headers, req)
 	})
 
 	transport.OnRequest(ctx)
@@ -269,12 +328,16 @@ func (s *server) Handshake(transportName string, ctx *types.HttpContext) (int, m
 	s.clients.Store(id, socket)
 	atomic.AddUint64(&s.clientsCount, 1)
 
-	socket.Once("close", func(...any) {
+	s.opts.Metrics().Count(metrics.HANDSHAKES, 1, metrics.Labels{"transport": transportName, "protocol": strconv.Itoa(protocol)})
+	s.opts.Metrics().Gauge(metrics.CLIENTS, 1, nil)
+
+	socket.Once(EVENT_CLOSE, func(...any) {
 		s.clients.Delete(id)
 		atomic.AddUint64(&s.clientsCount, ^uint64(0))
+		s.opts.Metrics().Gauge(metrics.CLIENTS, -1, nil)
 	})
 
-	s.Emit("connection", socket)
+	s.Emit(EVENT_CONNECTION, socket)
 
 	return OK_REQUEST, nil, transport
 }
@@ -3,6 +3,7 @@ package engine
 import (
 	"net/http"
 
+	"github.com/zishang520/engine.io/config"
 	"github.com/zishang520/engine.io/types"
 )
 
@@ -18,6 +19,26 @@ func New(server any, args ...any) Server {
 	return NewServer(nil)
 }
 
+// Creates a server like New, but returns the error of invalid cors options
+// where New panics.
+func Create(server any, args ...any) (Server, error) {
+	opts := server
+	if _, ok := server.(*types.HttpServer); ok {
+		opts = nil
+		if len(args) > 0 {
+			opts = args[0]
+		}
+	}
+	if opts, ok := opts.(config.ServerOptionsInterface); ok {
+		if cors := opts.Cors(); cors != nil {
+			if err := cors.Validate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return New(server, args...), nil
+}
+
 // Creates an http.Server exclusively used for WS upgrades.
 func Listen(addr string, options any, fn types.Callable) Server {
 	server := types.CreateServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
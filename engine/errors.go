@@ -0,0 +1,22 @@
+package engine
+
+import (
+	"github.com/zishang520/engine.io/errors"
+)
+
+// Failures reported by the server and its sockets, compare with errors.Is.
+var (
+	ErrUnknownTransport = errors.New("unsupported transportName").WithCode("UNKNOWN_TRANSPORT")
+	ErrParse            = errors.New("parse error").WithCode("PARSE_ERROR")
+	ErrPingTimeout      = errors.New("ping timeout").WithCode("PING_TIMEOUT")
+	ErrUpgradeTimeout   = errors.New("client did not complete upgrade").WithCode("UPGRADE_TIMEOUT")
+	ErrUpgradeFailed    = errors.New("upgrade failed").WithCode("UPGRADE_FAILED")
+	ErrTransportError   = errors.New("transport error").WithCode("TRANSPORT_ERROR")
+	ErrInvalidHeartbeat = errors.New("invalid heartbeat direction").WithCode("INVALID_HEARTBEAT")
+	ErrTransportClosed  = errors.New("transport closed").WithCode("TRANSPORT_CLOSED") // during an upgrade
+	ErrSocketClosed     = errors.New("socket closed").WithCode("SOCKET_CLOSED")       // during an upgrade
+	ErrTransportClose   = errors.New("transport close").WithCode("TRANSPORT_CLOSE")
+	ErrForcedClose      = errors.New("forced close").WithCode("FORCED_CLOSE")
+	ErrDeliveryAborted  = errors.New("socket closed before delivery").WithCode("DELIVERY_ABORTED")
+	ErrSuperseded       = errors.New("message superseded by a newer one").WithCode("SUPERSEDED")
+)
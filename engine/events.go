@@ -0,0 +1,119 @@
+package engine
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/zishang520/engine.io/events"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/transports"
+	"github.com/zishang520/engine.io/types"
+	"github.com/zishang520/engine.io/utils"
+)
+
+// Events emitted by a Server.
+const (
+	EVENT_CONNECTION       events.EventName = "connection"       // (Socket)
+	EVENT_CONNECTION_ERROR events.EventName = "connection_error" // (*types.ErrorMessage)
+	EVENT_INITIAL_HEADERS  events.EventName = "initial_headers"  // (*utils.ParameterBag, *types.HttpContext)
+	EVENT_HEADERS          events.EventName = "headers"          // (*utils.ParameterBag, *types.HttpContext)
+)
+
+// Events emitted by a Socket, "flush" and "drain" are emitted by its Server as
+// well with the socket as first argument.
+const (
+	EVENT_OPEN          events.EventName = "open"          // ()
+	EVENT_PACKET        events.EventName = "packet"        // (*packet.Packet)
+	EVENT_PACKET_CREATE events.EventName = "packetCreate"  // (*packet.Packet)
+	EVENT_HEARTBEAT     events.EventName = "heartbeat"     // ()
+	EVENT_DATA          events.EventName = "data"          // (io.Reader, context.Context)
+	EVENT_MESSAGE       events.EventName = "message"       // (io.Reader, context.Context)
+	EVENT_LATENCY       events.EventName = "latency"       // (time.Duration, Latency)
+	EVENT_UPGRADING     events.EventName = "upgrading"     // (transports.Transport)
+	EVENT_UPGRADE       events.EventName = "upgrade"       // (transports.Transport)
+	EVENT_UPGRADE_ERROR events.EventName = "upgrade_error" // (transports.Transport, error)
+	EVENT_FLUSH         events.EventName = "flush"         // ([]*packet.Packet)
+	EVENT_DRAIN         events.EventName = "drain"         // ()
+	EVENT_CLOSE         events.EventName = "close"         // (CloseReason, error)
+)
+
+// The helpers below register typed listeners, the returned events.Listener can
+// be passed to RemoveListener. A missing or mistyped argument is passed as the
+// zero value instead of panicking.
+
+func OnConnection(server Server, fn func(Socket)) events.Listener {
+	return events.On1(server, EVENT_CONNECTION, fn)
+}
+
+func OnConnectionError(server Server, fn func(*types.ErrorMessage)) events.Listener {
+	return events.On1(server, EVENT_CONNECTION_ERROR, fn)
+}
+
+func OnInitialHeaders(server Server, fn func(*utils.ParameterBag, *types.HttpContext)) events.Listener {
+	return events.On2(server, EVENT_INITIAL_HEADERS, fn)
+}
+
+func OnHeaders(server Server, fn func(*utils.ParameterBag, *types.HttpContext)) events.Listener {
+	return events.On2(server, EVENT_HEADERS, fn)
+}
+
+func OnServerFlush(server Server, fn func(Socket, []*packet.Packet)) events.Listener {
+	return events.On2(server, EVENT_FLUSH, fn)
+}
+
+func OnServerDrain(server Server, fn func(Socket)) events.Listener {
+	return events.On1(server, EVENT_DRAIN, fn)
+}
+
+func OnOpen(socket Socket, fn func()) events.Listener {
+	return events.On0(socket, EVENT_OPEN, fn)
+}
+
+func OnPacket(socket Socket, fn func(*packet.Packet)) events.Listener {
+	return events.On1(socket, EVENT_PACKET, fn)
+}
+
+func OnPacketCreate(socket Socket, fn func(*packet.Packet)) events.Listener {
+	return events.On1(socket, EVENT_PACKET_CREATE, fn)
+}
+
+func OnHeartbeat(socket Socket, fn func()) events.Listener {
+	return events.On0(socket, EVENT_HEARTBEAT, fn)
+}
+
+func OnData(socket Socket, fn func(io.Reader, context.Context)) events.Listener {
+	return events.On2(socket, EVENT_DATA, fn)
+}
+
+func OnMessage(socket Socket, fn func(io.Reader, context.Context)) events.Listener {
+	return events.On2(socket, EVENT_MESSAGE, fn)
+}
+
+func OnLatency(socket Socket, fn func(time.Duration, Latency)) events.Listener {
+	return events.On2(socket, EVENT_LATENCY, fn)
+}
+
+func OnUpgrading(socket Socket, fn func(transports.Transport)) events.Listener {
+	return events.On1(socket, EVENT_UPGRADING, fn)
+}
+
+func OnUpgrade(socket Socket, fn func(transports.Transport)) events.Listener {
+	return events.On1(socket, EVENT_UPGRADE, fn)
+}
+
+func OnUpgradeError(socket Socket, fn func(transports.Transport, error)) events.Listener {
+	return events.On2(socket, EVENT_UPGRADE_ERROR, fn)
+}
+
+func OnFlush(socket Socket, fn func([]*packet.Packet)) events.Listener {
+	return events.On1(socket, EVENT_FLUSH, fn)
+}
+
+func OnDrain(socket Socket, fn func()) events.Listener {
+	return events.On0(socket, EVENT_DRAIN, fn)
+}
+
+func OnClose(socket Socket, fn func(CloseReason, error)) events.Listener {
+	return events.On2(socket, EVENT_CLOSE, fn)
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Number of recent samples the average and the 99th percentile are computed on.
+const latencyWindow = 128
+
+// Latency statistics of a socket.
+type Latency struct {
+	Last    time.Duration `json:"last"`
+	Min     time.Duration `json:"min"`
+	Avg     time.Duration `json:"avg"`
+	P99     time.Duration `json:"p99"`
+	Samples uint64        `json:"samples"`
+}
+
+// Keeps the recent round-trip time samples of a socket.
+type latencyTracker struct {
+	samples []time.Duration // ring buffer of the last samples
+	next    int
+	latency Latency
+
+	mu sync.RWMutex
+}
+
+func (l *latencyTracker) add(rtt time.Duration) Latency {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if len(l.samples) < latencyWindow {
+		l.samples = append(l.samples, rtt)
+	} else {
+		l.samples[l.next] = rtt
+	}
+	l.next = (l.next + 1) % latencyWindow
+
+	if l.latency.Samples == 0 || rtt < l.latency.Min {
+		l.latency.Min = rtt
+	}
+	l.latency.Last = rtt
+	l.latency.Samples++
+
+	sorted := append([]time.Duration{}, l.samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var sum time.Duration
+	for _, sample := range sorted {
+		sum += sample
+	}
+	l.latency.Avg = sum / time.Duration(len(sorted))
+	l.latency.P99 = sorted[(len(sorted)*99-1)/100]
+
+	return l.latency
+}
+
+func (l *latencyTracker) get() Latency {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	return l.latency
+}
@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	t.Run("Samples", func(t *testing.T) {
+		l := &latencyTracker{}
+		if latency := l.get(); latency != (Latency{}) {
+			t.Fatalf("get() = %+v, want match for the zero value", latency)
+		}
+		for _, rtt := range []time.Duration{4, 1, 10, 2, 3, 5, 6, 7, 8, 9} {
+			l.add(rtt * time.Millisecond)
+		}
+		want := Latency{Last: 9 * time.Millisecond, Min: time.Millisecond, Avg: 5500 * time.Microsecond, P99: 10 * time.Millisecond, Samples: 10}
+		if latency := l.get(); latency != want {
+			t.Fatalf("get() = %+v, want match for %+v", latency, want)
+		}
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		l := &latencyTracker{}
+		for i := 0; i < latencyWindow; i++ {
+			l.add(time.Second)
+		}
+		// the older samples leave the window, the minimum is kept
+		for i := 0; i < latencyWindow; i++ {
+			l.add(time.Millisecond)
+		}
+		want := Latency{Last: time.Millisecond, Min: time.Millisecond, Avg: time.Millisecond, P99: time.Millisecond, Samples: 2 * latencyWindow}
+		if latency := l.get(); latency != want {
+			t.Fatalf("get() = %+v, want match for %+v", latency, want)
+		}
+
+		// one outlier in the window is above the 99th percentile, two are not
+		if latency := l.add(time.Second); latency.P99 != time.Millisecond {
+			t.Fatalf("add() = %+v, want match for a p99 of %s", latency, time.Millisecond)
+		}
+		if latency := l.add(time.Second); latency.P99 != time.Second {
+			t.Fatalf("add() = %+v, want match for a p99 of %s", latency, time.Second)
+		}
+	})
+}
@@ -8,9 +8,9 @@ import (
 
 	"github.com/gorilla/websocket"
 	"github.com/zishang520/engine.io/config"
-	"github.com/zishang520/engine.io/errors"
 	"github.com/zishang520/engine.io/events"
 	"github.com/zishang520/engine.io/log"
+	"github.com/zishang520/engine.io/tracing"
 	"github.com/zishang520/engine.io/transports"
 	"github.com/zishang520/engine.io/types"
 )
@@ -21,16 +21,24 @@ func (s *server) CreateTransport(transportName string, ctx *types.HttpContext) (
 	if transport, ok := transports.Transports()[transportName]; ok {
 		return transport.New(ctx), nil
 	}
-	return nil, errors.New("unsupported transportName").Err()
+	return nil, ErrUnknownTransport
 }
 
 // Handles an Engine.IO HTTP request.
 func (s *server) HandleRequest(ctx *types.HttpContext) {
-	server_log.Debug(`handling "%s" http request "%s"`, ctx.Method(), ctx.Request().RequestURI)
+	s.log.Debug(`handling "%s" http request "%s"`, ctx.Method(), ctx.Request().RequestURI)
+
+	traceCtx, span := tracing.Start(s.opts.Tracer(), remoteTraceContext(ctx), "engine.request", tracing.Attributes{
+		"http.method": ctx.Method(),
+		"transport":   ctx.Query().Peek("transport"),
+		"sid":         ctx.Query().Peek("sid"),
+	})
+	ctx.SetContext(traceCtx)
 
 	callback := func(errorCode int, errorContext map[string]any) {
 		if errorContext != nil {
-			s.Emit("connection_error", &types.ErrorMessage{
+			s.recordError(errorCode)
+			s.Emit(EVENT_CONNECTION_ERROR, &types.ErrorMessage{
 				CodeMessage: &types.CodeMessage{
 					Code:    errorCode,
 					Message: errorMessages[errorCode],
@@ -38,20 +46,20 @@ func (s *server) HandleRequest(ctx *types.HttpContext) {
 				Req:     ctx,
 				Context: errorContext,
 			})
-			abortRequest(ctx, errorCode, errorContext)
+			s.abortRequest(ctx, errorCode, errorContext)
 			return
 		}
 
 		if sid := ctx.Query().Peek("sid"); sid != "" {
-			server_log.Debug("setting new request for existing client")
+			s.log.Debug("setting new request for existing client")
 			if socket, ok := s.clients.Load(sid); ok {
 				socket.(Socket).Transport().OnRequest(ctx)
 			} else {
-				abortRequest(ctx, UNKNOWN_SID, map[string]any{"sid": sid})
+				s.abortRequest(ctx, UNKNOWN_SID, map[string]any{"sid": sid})
 			}
 		} else {
 			if errorCode, errorContext, t := s.Handshake(ctx.Query().Peek("transport"), ctx); t == nil {
-				abortRequest(ctx, errorCode, errorContext)
+				s.abortRequest(ctx, errorCode, errorContext)
 			}
 		}
 	}
@@ -65,13 +73,25 @@ func (s *server) HandleRequest(ctx *types.HttpContext) {
 	}
 
 	<-ctx.Done()
+	endRequestSpan(ctx, span)
 }
 
 // Handles an Engine.IO HTTP Upgrade.
 func (s *server) HandleUpgrade(ctx *types.HttpContext) {
+	traceCtx, span := tracing.Start(s.opts.Tracer(), remoteTraceContext(ctx), "engine.upgrade_request", tracing.Attributes{
+		"transport": ctx.Query().Peek("transport"),
+		"sid":       ctx.Query().Peek("sid"),
+	})
+	ctx.SetContext(traceCtx)
+	defer endRequestSpan(ctx, span)
+
 	errorCode, errorContext := s.Verify(ctx, true)
+	if errorContext == nil {
+		errorCode, errorContext = s.verifyOrigin(ctx)
+	}
 	if errorContext != nil {
-		s.Emit("connection_error", &types.ErrorMessage{
+		s.recordError(errorCode)
+		s.Emit(EVENT_CONNECTION_ERROR, &types.ErrorMessage{
 			CodeMessage: &types.CodeMessage{
 				Code:    errorCode,
 				Message: errorMessages[errorCode],
@@ -79,7 +99,7 @@ func (s *server) HandleUpgrade(ctx *types.HttpContext) {
 			Req:     ctx,
 			Context: errorContext,
 		})
-		abortUpgrade(ctx, errorCode, errorContext)
+		s.abortUpgrade(ctx, errorCode, errorContext)
 		return
 	}
 
@@ -89,12 +109,15 @@ func (s *server) HandleUpgrade(ctx *types.HttpContext) {
 		ReadBufferSize:    1024,
 		WriteBufferSize:   1024,
 		EnableCompression: s.opts.PerMessageDeflate() != nil,
-		Error: func(_ http.ResponseWriter, _ *http.Request, _ int, reason error) {
+		Error: func(_ http.ResponseWriter, _ *http.Request, status int, reason error) {
 			if websocket.IsUnexpectedCloseError(reason) {
 				wsc.Emit("close")
 			} else {
 				wsc.Emit("error", reason)
 			}
+			// the connection is not hijacked yet
+			ctx.SetStatusCode(status)
+			ctx.Write([]byte(http.StatusText(status)))
 		},
 		CheckOrigin: func(*http.Request) bool {
 			if allowRequest := s.opts.AllowRequest(); allowRequest != nil {
@@ -102,24 +125,30 @@ func (s *server) HandleUpgrade(ctx *types.HttpContext) {
 					return false
 				}
 			}
+			// the origin is verified beforehand
 			return true
 		},
 	}
 
 	// delegate to ws
 	if conn, err := ws.Upgrade(ctx.Response(), ctx.Request(), ctx.ResponseHeaders.All()); err == nil {
-		conn.SetReadLimit(s.opts.MaxHttpBufferSize())
+		// a websocket frame holds a single packet
+		readLimit := s.opts.MaxHttpBufferSize()
+		if maxPacketSize := s.opts.MaxPacketSize(); maxPacketSize > 0 && maxPacketSize < readLimit {
+			readLimit = maxPacketSize
+		}
+		conn.SetReadLimit(readLimit)
 		wsc.Conn = conn
 		s.onWebSocket(ctx, wsc)
 	} else {
-		server_log.Debug("websocket error before upgrade: %s", err)
+		s.log.Debug("websocket error before upgrade: %s", err)
 	}
 }
 
 // Called upon a ws.io connection.
 func (s *server) onWebSocket(ctx *types.HttpContext, wsc *types.WebSocketConn) {
 	onUpgradeError := func(...any) {
-		server_log.Debug("websocket error before upgrade")
+		s.log.Debug("websocket error before upgrade")
 		// wsc.close() not needed
 	}
 
@@ -127,7 +156,7 @@ func (s *server) onWebSocket(ctx *types.HttpContext, wsc *types.WebSocketConn) {
 
 	transportName := ctx.Query().Peek("transport")
 	if transport, ok := transports.Transports()[transportName]; ok && !transport.HandlesUpgrades {
-		server_log.Debug("transport doesnt handle upgraded requests")
+		s.log.Debug("transport doesnt handle upgraded requests")
 		wsc.Close()
 		return
 	}
@@ -142,23 +171,23 @@ func (s *server) onWebSocket(ctx *types.HttpContext, wsc *types.WebSocketConn) {
 		client, ok := s.clients.Load(id)
 
 		if !ok {
-			server_log.Debug("upgrade attempt for closed client")
+			s.log.Debug("upgrade attempt for closed client")
 			wsc.Close()
 		} else if client.(Socket).Upgrading() {
-			server_log.Debug("transport has already been trying to upgrade")
+			s.log.Debug("transport has already been trying to upgrade")
 			wsc.Close()
 		} else if client.(Socket).Upgraded() {
-			server_log.Debug("transport had already been upgraded")
+			s.log.Debug("transport had already been upgraded")
 			wsc.Close()
 		} else {
-			server_log.Debug("upgrading existing transport")
+			s.log.Debug("upgrading existing transport")
 
 			// transport error handling takes over
 			wsc.RemoveListener("error", onUpgradeError)
 
 			transport, err := s.CreateTransport(transportName, ctx)
 			if err != nil {
-				server_log.Debug("upgrading not existing transport")
+				s.log.Debug("upgrading not existing transport")
 				wsc.Close()
 			} else {
 				if ctx.Query().Has("b64") {
@@ -172,7 +201,7 @@ func (s *server) onWebSocket(ctx *types.HttpContext, wsc *types.WebSocketConn) {
 		}
 	} else {
 		if errorCode, errorContext, t := s.Handshake(transportName, ctx); t == nil {
-			abortUpgrade(ctx, errorCode, errorContext)
+			s.abortUpgrade(ctx, errorCode, errorContext)
 		}
 	}
 }
@@ -194,7 +223,7 @@ func (s *server) Attach(server *types.HttpServer, opts any) {
 
 	server.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
 		if !websocket.IsWebSocketUpgrade(r) {
-			server_log.Debug(`intercepting request for path "%s"`, path)
+			s.log.Debug(`intercepting request for path "%s"`, path)
 			s.HandleRequest(types.NewHttpContext(w, r))
 		} else if s.opts.Transports().Has("websocket") {
 			s.HandleUpgrade(types.NewHttpContext(w, r))
@@ -207,7 +236,7 @@ func (s *server) Attach(server *types.HttpServer, opts any) {
 // Captures upgrade requests for a http.Handler, Need to handle server shutdown disconnecting client connections.
 func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if !websocket.IsWebSocketUpgrade(r) {
-		server_log.Debug(`intercepting request for path "%s"`, r.URL.Path)
+		s.log.Debug(`intercepting request for path "%s"`, r.URL.Path)
 		s.HandleRequest(types.NewHttpContext(w, r))
 	} else if s.opts.Transports().Has("websocket") {
 		s.HandleUpgrade(types.NewHttpContext(w, r))
@@ -217,8 +246,8 @@ func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 }
 
 // Close the HTTP long-polling request
-func abortRequest(ctx *types.HttpContext, errorCode int, errorContext map[string]any) {
-	server_log.Debug("abortRequest %d", errorCode)
+func (s *server) abortRequest(ctx *types.HttpContext, errorCode int, errorContext map[string]any) {
+	s.log.Debug("abortRequest %d", errorCode)
 	statusCode := http.StatusBadRequest
 	if errorCode == FORBIDDEN {
 		statusCode = http.StatusForbidden
@@ -236,13 +265,50 @@ func abortRequest(ctx *types.HttpContext, errorCode int, errorContext map[string
 	}
 }
 
+// Verifies the origin of a websocket upgrade against the allowed origins, or
+// else the origin of the cors module, or else the host of the request when the
+// cookie is enabled, so that a cross-site page cannot ride the session cookie.
+// The requests without origin do not come from a browser and are allowed.
+func (s *server) verifyOrigin(ctx *types.HttpContext) (int, map[string]any) {
+	origin := ctx.Headers().Peek("Origin")
+	if origin == "" {
+		return OK_REQUEST, nil
+	}
+
+	var allowed bool
+	var err error
+	if allowedOrigins := s.opts.AllowedOrigins(); allowedOrigins != nil {
+		allowed, err = allowedOrigins.Allowed(origin, ctx)
+	} else if cors := s.opts.Cors(); cors != nil && cors.Origin != nil {
+		allowed, err = cors.IsOriginAllowed(origin, ctx)
+	} else if s.opts.Cookie() != nil {
+		allowed = types.SameOrigin(origin, ctx)
+	} else {
+		return OK_REQUEST, nil
+	}
+
+	if err != nil {
+		s.log.Debug("unable to resolve origin %s: %v", origin, err)
+		return FORBIDDEN, map[string]any{"name": "ORIGIN_NOT_ALLOWED", "origin": origin, "error": err}
+	}
+	if !allowed {
+		s.log.Debug("origin %s not allowed", origin)
+		return FORBIDDEN, map[string]any{"name": "ORIGIN_NOT_ALLOWED", "origin": origin}
+	}
+	return OK_REQUEST, nil
+}
+
 // Close the WebSocket connection
-func abortUpgrade(ctx *types.HttpContext, errorCode int, errorContext map[string]any) {
-	server_log.Debug("abortUpgrade %d", errorCode)
+func (s *server) abortUpgrade(ctx *types.HttpContext, errorCode int, errorContext map[string]any) {
+	s.log.Debug("abortUpgrade %d", errorCode)
+	statusCode := http.StatusBadRequest
+	if errorCode == FORBIDDEN {
+		statusCode = http.StatusForbidden
+	}
 	message := errorMessages[errorCode]
 	if m, ok := errorContext["message"]; ok {
 		message = m.(string)
 	}
-	ctx.SetStatusCode(http.StatusBadRequest)
+	ctx.SetStatusCode(statusCode)
 	io.WriteString(ctx, message)
 }
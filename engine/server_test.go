@@ -0,0 +1,114 @@
+package engine_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/engine"
+	"github.com/zishang520/engine.io/enginetest"
+	"github.com/zishang520/engine.io/types"
+)
+
+// Opens a WebSocket session from origin and returns the status of the upgrade.
+func dialFrom(t *testing.T, server *enginetest.Server, origin string) int {
+	t.Helper()
+
+	rawURL := "ws" + strings.TrimPrefix(server.URL, "http") + "?EIO=4&transport=websocket"
+	conn, res, err := websocket.DefaultDialer.Dial(rawURL, http.Header{"Origin": {origin}})
+	if err == nil {
+		conn.Close()
+	}
+	if res == nil {
+		t.Fatalf("websocket %s failed: %v", rawURL, err)
+	}
+	return res.StatusCode
+}
+
+func TestVerifyOrigin(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		options func(*config.ServerOptions)
+		origins map[string]bool // by origin, "self" for the origin of the server
+	}{
+		{"AllowedOrigins", func(opts *config.ServerOptions) {
+			opts.SetAllowedOrigins(types.NewAllowedOrigins("https://example.com"))
+		}, map[string]bool{"https://example.com": true, "https://evil.com": false, "self": false}},
+		{"Cors", func(opts *config.ServerOptions) {
+			opts.SetCors(&types.Cors{Origin: []any{"https://example.com"}})
+		}, map[string]bool{"https://example.com": true, "https://evil.com": false}},
+		// the cookie of the session must not be sent by another site
+		{"SameOriginWithCookie", func(opts *config.ServerOptions) {
+			opts.SetCookie(&http.Cookie{})
+		}, map[string]bool{"self": true, "https://evil.com": false}},
+		{"AnyOriginByDefault", func(*config.ServerOptions) {}, map[string]bool{"self": true, "https://evil.com": true}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts := config.DefaultServerOptions()
+			test.options(opts)
+			server := enginetest.NewServer(t, opts)
+			events := enginetest.Record(t, server, engine.EVENT_CONNECTION_ERROR)
+
+			for origin, allowed := range test.origins {
+				if origin == "self" {
+					origin = server.URL[:strings.Index(server.URL, "/engine.io/")]
+				}
+				status := dialFrom(t, server, origin)
+				if allowed {
+					if status != http.StatusSwitchingProtocols {
+						t.Fatalf("upgrade from %s answered %d, want match for %d", origin, status, http.StatusSwitchingProtocols)
+					}
+					continue
+				}
+				if status != http.StatusForbidden {
+					t.Fatalf("upgrade from %s answered %d, want match for %d", origin, status, http.StatusForbidden)
+				}
+				err, _ := events.Expect(engine.EVENT_CONNECTION_ERROR).Args[0].(*types.ErrorMessage)
+				if err == nil || err.Context["name"] != "ORIGIN_NOT_ALLOWED" || err.Context["origin"] != origin {
+					t.Fatalf("connection_error = %+v, want match for ORIGIN_NOT_ALLOWED from %s", err, origin)
+				}
+			}
+		})
+	}
+
+	t.Run("CorsLeftUntouched", func(t *testing.T) {
+		cors := &types.Cors{Credentials: true}
+		opts := config.DefaultServerOptions()
+		opts.SetCors(cors)
+		opts.SetAllowedOrigins(types.NewAllowedOrigins("https://example.com"))
+		enginetest.NewServer(t, opts)
+		if cors.Origin != nil {
+			t.Fatalf("the server set the cors origin of the options to %v, want match for nil", cors.Origin)
+		}
+	})
+}
+
+func TestCreate(t *testing.T) {
+	invalid := config.DefaultServerOptions()
+	invalid.SetCors(&types.Cors{MaxAge: "ten minutes"})
+	for _, server := range []any{invalid, types.CreateServer(nil)} {
+		if s, err := engine.Create(server, invalid); s != nil || !errors.Is(err, types.ErrInvalidCors) {
+			t.Fatalf("Create(%T) = %v, %v, want match for nil, %v", server, s, err, types.ErrInvalidCors)
+		}
+	}
+
+	valid := config.DefaultServerOptions()
+	valid.SetCors(&types.Cors{Origin: "https://example.com", MaxAge: "600"})
+	s, err := engine.Create(valid)
+	if s == nil || err != nil {
+		t.Fatalf("Create() = %v, %v, want match for a server", s, err)
+	}
+	s.Close()
+
+	t.Run("New", func(t *testing.T) {
+		defer func() {
+			if err, _ := recover().(error); !errors.Is(err, types.ErrInvalidCors) {
+				t.Fatalf("New() panicked with %v, want match for %v", err, types.ErrInvalidCors)
+			}
+		}()
+		engine.New(invalid).Close()
+	})
+}
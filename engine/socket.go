@@ -1,15 +1,19 @@
 package engine
 
 import (
+	"context"
 	"encoding/json"
 	"io"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/zishang520/engine.io/events"
 	"github.com/zishang520/engine.io/log"
+	"github.com/zishang520/engine.io/metrics"
 	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/tracing"
 	"github.com/zishang520/engine.io/transports"
 	"github.com/zishang520/engine.io/types"
 	"github.com/zishang520/engine.io/utils"
@@ -23,6 +27,9 @@ type socket struct {
 	protocol      int
 	request       *types.HttpContext
 	remoteAddress string
+	ctx           context.Context
+	createdAt     time.Time
+	clock         utils.Clock // the clock of the heartbeats and timeouts
 
 	readyState  string
 	transport   transports.Transport
@@ -32,25 +39,35 @@ type socket struct {
 	server                Server
 	upgrading             bool
 	upgraded              bool
+	bulkPolicy            BulkPolicy
 	writeBuffer           []*packet.Packet
 	packetsFn             []func(transports.Transport)
 	sentCallbackFn        []any
+	deliveries            map[*packet.Packet]chan error
 	cleanupFn             []types.Callable
-	checkIntervalTimer    *utils.Timer
+	checkIntervalTimer    utils.ClockTimer
 	mucheckIntervalTimer  sync.Mutex
-	upgradeTimeoutTimer   *utils.Timer
+	upgradeTimeoutTimer   utils.ClockTimer
 	muupgradeTimeoutTimer sync.RWMutex
-	pingTimeoutTimer      *utils.Timer
+	pingTimeoutTimer      utils.ClockTimer
 	mupingTimeoutTimer    sync.RWMutex
-	pingIntervalTimer     *utils.Timer
+	pingIntervalTimer     utils.ClockTimer
 	mupingIntervalTimer   sync.RWMutex
+	pingSentAt            time.Time
+	lastPingAt            time.Time
+	muheartbeat           sync.Mutex
+	latency               *latencyTracker
+	logger                *log.Log
+	mulogger              sync.RWMutex
 
 	mureadyState     sync.RWMutex
 	muupgrading      sync.RWMutex
 	muupgraded       sync.RWMutex
+	mubulkPolicy     sync.RWMutex
 	muwriteBuffer    sync.RWMutex
 	mupacketsFn      sync.RWMutex
 	musentCallbackFn sync.RWMutex
+	mudeliveries     sync.Mutex
 	mucleanupFn      sync.RWMutex
 }
 
@@ -84,6 +101,27 @@ func (s *socket) Request() *types.HttpContext {
 	return s.request
 }
 
+func (s *socket) CreatedAt() time.Time {
+	return s.createdAt
+}
+
+// Returns the number of packets waiting to be flushed.
+func (s *socket) WriteBufferLen() int {
+	s.muwriteBuffer.RLock()
+	defer s.muwriteBuffer.RUnlock()
+
+	return len(s.writeBuffer)
+}
+
+// Returns the round-trip time statistics measured from the heartbeat.
+func (s *socket) Latency() Latency {
+	return s.latency.get()
+}
+
+func (s *socket) Context() context.Context {
+	return s.ctx
+}
+
 func (s *socket) Transport() transports.Transport {
 	s.mutransport.RLock()
 	defer s.mutransport.RUnlock()
@@ -91,6 +129,20 @@ func (s *socket) Transport() transports.Transport {
 	return s.transport
 }
 
+func (s *socket) BulkPolicy() BulkPolicy {
+	s.mubulkPolicy.RLock()
+	defer s.mubulkPolicy.RUnlock()
+
+	return s.bulkPolicy
+}
+
+func (s *socket) SetBulkPolicy(policy BulkPolicy) {
+	s.mubulkPolicy.Lock()
+	defer s.mubulkPolicy.Unlock()
+
+	s.bulkPolicy = policy
+}
+
 func (s *socket) Server() Server {
 	return s.server
 }
@@ -102,10 +154,21 @@ func (s *socket) ReadyState() string {
 	return s.readyState
 }
 
+// Returns the log of the socket, its records carry the sid, transport and remote address.
+func (s *socket) log() *log.Log {
+	s.mulogger.RLock()
+	defer s.mulogger.RUnlock()
+
+	if s.logger == nil {
+		return socket_log
+	}
+	return s.logger
+}
+
 func (s *socket) SetReadyState(state string) {
 	s.mureadyState.Lock()
 	defer s.mureadyState.Unlock()
-	socket_log.Debug("readyState updated from %s to %s", s.readyState, state)
+	s.log().Debug("readyState updated from %s to %s", s.readyState, state)
 
 	s.readyState = state
 }
@@ -121,8 +184,9 @@ func NewSocket(id string, server Server, transport transports.Transport, ctx *ty
 // Client class.
 func (s *socket) New(id string, server Server, transport transports.Transport, ctx *types.HttpContext, protocol int) Socket {
 	s.id = id
-
 	s.server = server
+	s.clock = server.Opts().Clock()
+	s.createdAt = s.clock.Now()
 
 	s.muupgrading.Lock()
 	s.upgrading = false
@@ -137,9 +201,12 @@ func (s *socket) New(id string, server Server, transport transports.Transport, c
 	s.writeBuffer = []*packet.Packet{}
 	s.packetsFn = []func(transports.Transport){}
 	s.sentCallbackFn = []any{}
+	s.deliveries = map[*packet.Packet]chan error{}
+	s.latency = &latencyTracker{}
 	s.cleanupFn = []types.Callable{}
 	s.request = ctx
 	s.protocol = protocol
+	s.ctx = tracing.Detach(ctx.Context())
 
 	// Cache IP since it might not be in the req later
 	if ctx.Websocket != nil && ctx.Websocket.Conn != nil {
@@ -175,23 +242,23 @@ func (s *socket) onOpen() {
 	})
 
 	if err != nil {
-		socket_log.Debug("json.Marshal err")
+		s.log().Debug("json.Marshal err")
 	}
 	s.sendPacket(
 		packet.OPEN,
 		types.NewStringBuffer(data),
-		nil, nil,
+		nil, nil, nil,
 	)
 
 	if i := s.server.Opts().InitialPacket(); i != nil {
-		s.sendPacket(packet.MESSAGE, i, nil, nil)
+		s.sendPacket(packet.MESSAGE, i, nil, nil, nil)
 	}
 
-	s.Emit("open")
+	s.Emit(EVENT_OPEN)
 
 	if s.protocol == 3 {
 		// in protocol v3, the client sends a ping, and the server answers with a pong
-		s.resetPingTimeout(s.server.Opts().PingInterval() + s.server.Opts().PingTimeout())
+		s.resetPingTimeout(s.server.Opts().PingInterval() + s.pingTimeout())
 	} else {
 		// in protocol v4, the server sends a ping, and the client answers with a pong
 		s.schedulePing()
@@ -201,56 +268,103 @@ func (s *socket) onOpen() {
 // Called upon transport packet.
 func (s *socket) onPacket(data *packet.Packet) {
 	if "open" != s.ReadyState() {
-		socket_log.Debug("packet received with closed socket")
+		s.log().Debug("packet received with closed socket")
 		return
 	}
 
 	// export packet event
-	socket_log.Debug(`received packet %s`, data.Type)
-	s.Emit("packet", data)
+	s.log().Debug(`received packet %s`, data.Type)
+	s.server.Opts().Metrics().Count(metrics.PACKETS_RECEIVED, 1, metrics.Labels{"type": string(data.Type)})
+	if l, ok := data.Data.(interface{ Len() int }); ok {
+		s.server.Opts().Metrics().Count(metrics.BYTES_RECEIVED, float64(l.Len()), metrics.Labels{"transport": s.Transport().Name()})
+	}
+	s.Emit(EVENT_PACKET, data)
 
 	// Reset ping timeout on any packet, incoming data is a good sign of
 	// other side's liveness
-	s.resetPingTimeout(s.server.Opts().PingInterval() + s.server.Opts().PingTimeout())
+	s.resetPingTimeout(s.server.Opts().PingInterval() + s.pingTimeout())
 
 	switch data.Type {
 	case packet.PING:
 		if s.Transport().Protocol() != 3 {
-			s.onError("invalid heartbeat direction")
+			s.onError(ErrInvalidHeartbeat)
 			return
 		}
-		socket_log.Debug("got ping")
-		s.sendPacket(packet.PONG, nil, nil, nil)
-		s.Emit("heartbeat")
+		s.log().Debug("got ping")
+		s.sendPacket(packet.PONG, nil, nil, nil, nil)
+		// the client sends its next ping a ping interval after receiving our pong,
+		// so pings arrive a round-trip time later than the ping interval
+		now := s.clock.Now()
+		s.muheartbeat.Lock()
+		lastPingAt := s.lastPingAt
+		s.lastPingAt = now
+		s.muheartbeat.Unlock()
+		if !lastPingAt.IsZero() {
+			rtt := now.Sub(lastPingAt) - s.server.Opts().PingInterval()
+			if rtt < 0 {
+				rtt = 0
+			}
+			s.onLatency(rtt)
+		}
+		s.Emit(EVENT_HEARTBEAT)
 		break
 
 	case packet.PONG:
 		if s.Transport().Protocol() == 3 {
-			s.onError("invalid heartbeat direction")
+			s.onError(ErrInvalidHeartbeat)
 			return
 		}
-		socket_log.Debug("got pong")
+		s.log().Debug("got pong")
+		s.muheartbeat.Lock()
+		pingSentAt := s.pingSentAt
+		s.pingSentAt = time.Time{}
+		s.muheartbeat.Unlock()
+		if !pingSentAt.IsZero() {
+			s.onLatency(s.clock.Now().Sub(pingSentAt))
+		}
 		s.mupingIntervalTimer.RLock()
-		s.pingIntervalTimer.Refresh()
+		refreshTimer(s.pingIntervalTimer)
 		s.mupingIntervalTimer.RUnlock()
-		s.Emit("heartbeat")
+		s.Emit(EVENT_HEARTBEAT)
 		break
 
 	case packet.ERROR:
-		s.OnClose("parse error")
+		s.OnClose(CLOSE_PARSE_ERROR)
 		break
 
 	case packet.MESSAGE:
-		s.Emit("data", data.Data)
-		s.Emit("message", data.Data)
+		msgCtx, span := tracing.Start(s.server.Opts().Tracer(), s.ctx, "engine.message", tracing.Attributes{
+			"sid":       s.id,
+			"transport": s.Transport().Name(),
+		})
+		s.Emit(EVENT_DATA, data.Data, msgCtx)
+		s.Emit(EVENT_MESSAGE, data.Data, msgCtx)
+		span.End(nil)
 		break
 	}
 }
 
+// Called with a round-trip time measured from the heartbeat.
+func (s *socket) onLatency(rtt time.Duration) {
+	latency := s.latency.add(rtt)
+	s.log().Debug("round-trip time %s (avg %s, p99 %s)", rtt, latency.Avg, latency.P99)
+	s.server.Opts().Metrics().Observe(metrics.PING_RTT, rtt.Seconds(), nil)
+	s.Emit(EVENT_LATENCY, rtt, latency)
+}
+
+// Returns how long to wait for a pong, extended by the observed round-trip time
+// when the heartbeat is adaptive.
+func (s *socket) pingTimeout() time.Duration {
+	if !s.server.Opts().AdaptiveHeartbeat() {
+		return s.server.Opts().PingTimeout()
+	}
+	return s.server.Opts().PingTimeout() + 2*s.latency.get().P99
+}
+
 // Called upon transport error.
 func (s *socket) onError(err any) {
-	socket_log.Debug("transport error %v", err)
-	s.OnClose("transport error", err)
+	s.log().Debug("transport error %v", err)
+	s.OnClose(CLOSE_TRANSPORT_ERROR, err)
 }
 
 // Pings client every `this.pingInterval` and expects response
@@ -259,10 +373,14 @@ func (s *socket) schedulePing() {
 	s.mupingIntervalTimer.Lock()
 	defer s.mupingIntervalTimer.Unlock()
 
-	s.pingIntervalTimer = utils.SetTimeOut(func() {
-		socket_log.Debug("writing ping packet - expecting pong within %dms", int64(s.server.Opts().PingTimeout()/time.Millisecond))
-		s.sendPacket(packet.PING, nil, nil, nil)
-		s.resetPingTimeout(s.server.Opts().PingTimeout())
+	s.pingIntervalTimer = s.clock.SetTimeout(func() {
+		timeout := s.pingTimeout()
+		s.log().Debug("writing ping packet - expecting pong within %dms", int64(timeout/time.Millisecond))
+		s.muheartbeat.Lock()
+		s.pingSentAt = s.clock.Now()
+		s.muheartbeat.Unlock()
+		s.sendPacket(packet.PING, nil, nil, nil, nil)
+		s.resetPingTimeout(timeout)
 	}, s.server.Opts().PingInterval())
 }
 
@@ -271,12 +389,12 @@ func (s *socket) resetPingTimeout(timeout time.Duration) {
 	s.mupingTimeoutTimer.Lock()
 	defer s.mupingTimeoutTimer.Unlock()
 
-	utils.ClearTimeout(s.pingTimeoutTimer)
-	s.pingTimeoutTimer = utils.SetTimeOut(func() {
+	stopTimer(s.pingTimeoutTimer)
+	s.pingTimeoutTimer = s.clock.SetTimeout(func() {
 		if s.ReadyState() == "closed" {
 			return
 		}
-		s.OnClose("ping timeout")
+		s.OnClose(CLOSE_PING_TIMEOUT)
 	}, timeout)
 }
 
@@ -292,17 +410,30 @@ func (s *socket) setTransport(transport transports.Transport) {
 		}
 	}
 	flush := func(...any) { s.flush() }
-	onClose := func(...any) { s.OnClose("transport close") }
+	onWritten := func(args ...any) {
+		packets, _ := args[0].([]*packet.Packet)
+		err, _ := args[1].(error)
+		s.onWritten(packets, err)
+	}
+	onClose := func(...any) { s.OnClose(CLOSE_TRANSPORT_CLOSE) }
 
 	s.mutransport.Lock()
 	s.transport = transport
 	s.mutransport.Unlock()
 
+	logger := s.server.Opts().Logger()
+	transport.SetLogger(logger, "sid", s.id, "remote", s.remoteAddress)
+	transport.SetClock(s.clock)
+	s.mulogger.Lock()
+	s.logger = socket_log.WithLogger(logger).With("sid", s.id, "remote", s.remoteAddress, "transport", transport.Name())
+	s.mulogger.Unlock()
+
 	s.mutransport.RLock()
-	s.transport.Once("error", onError)
-	s.transport.On("packet", onPacket)
-	s.transport.On("drain", flush)
-	s.transport.Once("close", onClose)
+	s.transport.Once(transports.EVENT_ERROR, onError)
+	s.transport.On(transports.EVENT_PACKET, onPacket)
+	s.transport.On(transports.EVENT_DRAIN, flush)
+	s.transport.On(transports.EVENT_WRITTEN, onWritten)
+	s.transport.Once(transports.EVENT_CLOSE, onClose)
 	s.mutransport.RUnlock()
 
 	// s function will manage packet events (also message callbacks)
@@ -310,17 +441,21 @@ func (s *socket) setTransport(transport transports.Transport) {
 
 	s.mucleanupFn.Lock()
 	s.cleanupFn = append(s.cleanupFn, func() {
-		transport.RemoveListener("error", onError)
-		transport.RemoveListener("packet", onPacket)
-		transport.RemoveListener("drain", flush)
-		transport.RemoveListener("close", onClose)
+		transport.RemoveListener(transports.EVENT_ERROR, onError)
+		transport.RemoveListener(transports.EVENT_PACKET, onPacket)
+		transport.RemoveListener(transports.EVENT_DRAIN, flush)
+		transport.RemoveListener(transports.EVENT_WRITTEN, onWritten)
+		transport.RemoveListener(transports.EVENT_CLOSE, onClose)
 	})
 	s.mucleanupFn.Unlock()
 }
 
 // Upgrades socket to the given transport
 func (s *socket) MaybeUpgrade(transport transports.Transport) {
-	socket_log.Debug(`might upgrade socket transport from "%s" to "%s"`, s.Transport().Name(), transport.Name())
+	s.log().Debug(`might upgrade socket transport from "%s" to "%s"`, s.Transport().Name(), transport.Name())
+
+	transport.SetLogger(s.server.Opts().Logger(), "sid", s.id, "remote", s.remoteAddress)
+	transport.SetClock(s.clock)
 
 	s.muupgrading.Lock()
 	s.upgrading = true
@@ -334,18 +469,19 @@ func (s *socket) MaybeUpgrade(transport transports.Transport) {
 		sb := new(strings.Builder)
 		io.Copy(sb, data.Data)
 		if packet.PING == data.Type && "probe" == sb.String() {
-			socket_log.Debug("got probe ping packet, sending pong")
+			s.log().Debug("got probe ping packet, sending pong")
 			transport.Send([]*packet.Packet{&packet.Packet{Type: packet.PONG, Data: strings.NewReader("probe")}})
-			s.Emit("upgrading", transport)
+			s.Emit(EVENT_UPGRADING, transport)
 
 			s.mucheckIntervalTimer.Lock()
-			utils.ClearInterval(s.checkIntervalTimer)
-			s.checkIntervalTimer = utils.SetInterval(check, 100*time.Millisecond)
+			stopTimer(s.checkIntervalTimer)
+			s.checkIntervalTimer = s.clock.SetInterval(check, 100*time.Millisecond)
 			s.mucheckIntervalTimer.Unlock()
 
 		} else if packet.UPGRADE == data.Type && s.ReadyState() != "closed" {
-			socket_log.Debug("got upgrade packet - upgrading")
+			s.log().Debug("got upgrade packet - upgrading")
 			cleanup()
+			s.recordUpgrade(transport, "success", nil)
 			s.Transport().Discard()
 
 			s.muupgraded.Lock()
@@ -354,15 +490,16 @@ func (s *socket) MaybeUpgrade(transport transports.Transport) {
 
 			s.clearTransport()
 			s.setTransport(transport)
-			s.Emit("upgrade", transport)
+			s.Emit(EVENT_UPGRADE, transport)
 			s.flush()
 			if s.ReadyState() == "closing" {
 				transport.Close(func() {
-					s.OnClose("forced close")
+					s.OnClose(CLOSE_FORCED_CLOSE)
 				})
 			}
 		} else {
 			cleanup()
+			s.recordUpgrade(transport, "failure", nil)
 			transport.Close()
 		}
 	}
@@ -370,7 +507,7 @@ func (s *socket) MaybeUpgrade(transport transports.Transport) {
 	// we force a polling cycle to ensure a fast upgrade
 	check = func() {
 		if "polling" == s.Transport().Name() && s.Transport().Writable() {
-			socket_log.Debug("writing a noop packet to polling for fast upgrade")
+			s.log().Debug("writing a noop packet to polling for fast upgrade")
 			s.Transport().Send([]*packet.Packet{&packet.Packet{Type: packet.NOOP}})
 		}
 	}
@@ -381,46 +518,49 @@ func (s *socket) MaybeUpgrade(transport transports.Transport) {
 		s.muupgrading.Unlock()
 
 		s.mucheckIntervalTimer.Lock()
-		utils.ClearInterval(s.checkIntervalTimer)
+		stopTimer(s.checkIntervalTimer)
 		s.checkIntervalTimer = nil
 		s.mucheckIntervalTimer.Unlock()
 
 		s.muupgradeTimeoutTimer.Lock()
-		utils.ClearTimeout(s.upgradeTimeoutTimer)
+		stopTimer(s.upgradeTimeoutTimer)
 		s.upgradeTimeoutTimer = nil
 		s.muupgradeTimeoutTimer.Unlock()
 
 		if transport != nil {
-			transport.RemoveListener("packet", onPacket)
-			transport.RemoveListener("close", onTransportClose)
-			transport.RemoveListener("error", onError)
+			transport.RemoveListener(transports.EVENT_PACKET, onPacket)
+			transport.RemoveListener(transports.EVENT_CLOSE, onTransportClose)
+			transport.RemoveListener(transports.EVENT_ERROR, onError)
 		}
-		s.RemoveListener("close", onClose)
+		s.RemoveListener(EVENT_CLOSE, onClose)
 	}
 
 	onError = func(err ...any) {
-		socket_log.Debug("client did not complete upgrade - %v", err[0])
+		s.log().Debug("client did not complete upgrade - %v", err[0])
 		if transport != nil {
+			cause, _ := err[0].(error)
 			cleanup()
+			s.recordUpgrade(transport, "failure", cause)
 			transport.Close()
 			transport = nil
 		}
 	}
 
 	onTransportClose = func(...any) {
-		onError("transport closed")
+		onError(ErrTransportClosed)
 	}
 
 	onClose = func(...any) {
-		onError("socket closed")
+		onError(ErrSocketClosed)
 	}
 
 	// set transport upgrade timer
 	s.muupgradeTimeoutTimer.Lock()
-	s.upgradeTimeoutTimer = utils.SetTimeOut(func() {
-		socket_log.Debug("client did not complete upgrade - closing transport")
+	s.upgradeTimeoutTimer = s.clock.SetTimeout(func() {
+		s.log().Debug("client did not complete upgrade - closing transport")
 		cleanup()
 		if transport != nil {
+			s.recordUpgrade(transport, "timeout", nil)
 			if "open" == transport.ReadyState() {
 				transport.Close()
 			}
@@ -428,11 +568,24 @@ func (s *socket) MaybeUpgrade(transport transports.Transport) {
 	}, s.server.Opts().UpgradeTimeout())
 	s.muupgradeTimeoutTimer.Unlock()
 
-	transport.On("packet", onPacket)
-	transport.Once("close", onTransportClose)
-	transport.Once("error", onError)
+	transport.On(transports.EVENT_PACKET, onPacket)
+	transport.Once(transports.EVENT_CLOSE, onTransportClose)
+	transport.Once(transports.EVENT_ERROR, onError)
 
-	s.Once("close", onClose)
+	s.Once(EVENT_CLOSE, onClose)
+}
+
+// Records the outcome of an upgrade attempt to the given transport, a failed
+// attempt emits "upgrade_error" with the transport and ErrUpgradeFailed or
+// ErrUpgradeTimeout.
+func (s *socket) recordUpgrade(transport transports.Transport, result string, cause error) {
+	s.server.Opts().Metrics().Count(metrics.UPGRADES, 1, metrics.Labels{"from": s.Transport().Name(), "to": transport.Name(), "result": result})
+	switch result {
+	case "failure":
+		s.Emit(EVENT_UPGRADE_ERROR, transport, ErrUpgradeFailed.Wrap(cause))
+	case "timeout":
+		s.Emit(EVENT_UPGRADE_ERROR, transport, ErrUpgradeTimeout)
+	}
 }
 
 // Clears listeners and timers associated with current transport.
@@ -445,42 +598,41 @@ func (s *socket) clearTransport() {
 	s.mucleanupFn.RUnlock()
 
 	// silence further transport errors and prevent uncaught exceptions
-	s.Transport().On("error", func(...any) {
-		socket_log.Debug("error triggered by discarded transport")
+	s.Transport().On(transports.EVENT_ERROR, func(...any) {
+		s.log().Debug("error triggered by discarded transport")
 	})
 
 	// ensure transport won't stay open
 	s.Transport().Close()
 
 	s.mupingTimeoutTimer.RLock()
-	utils.ClearTimeout(s.pingTimeoutTimer)
+	stopTimer(s.pingTimeoutTimer)
 	s.mupingTimeoutTimer.RUnlock()
 }
 
-// Called upon transport considered closed.
-// Possible reasons: `ping timeout`, `client error`, `parse error`,
-// `transport error`, `server close`, `transport close`
-func (s *socket) OnClose(reason string, description ...any) {
+// Called upon transport considered closed, the "close" event is emitted with
+// the reason and its description.
+func (s *socket) OnClose(reason CloseReason, description ...any) {
 	description = append(description, nil)
 	if "closed" != s.ReadyState() {
 		s.SetReadyState("closed")
 
 		// clear timers
 		s.mupingIntervalTimer.RLock()
-		utils.ClearTimeout(s.pingIntervalTimer)
+		stopTimer(s.pingIntervalTimer)
 		s.mupingIntervalTimer.RUnlock()
 
 		s.mupingTimeoutTimer.RLock()
-		utils.ClearTimeout(s.pingTimeoutTimer)
+		stopTimer(s.pingTimeoutTimer)
 		s.mupingTimeoutTimer.RUnlock()
 
 		s.mucheckIntervalTimer.Lock()
-		utils.ClearInterval(s.checkIntervalTimer)
+		stopTimer(s.checkIntervalTimer)
 		s.checkIntervalTimer = nil
 		s.mucheckIntervalTimer.Unlock()
 
 		s.muupgradeTimeoutTimer.RLock()
-		utils.ClearTimeout(s.upgradeTimeoutTimer)
+		stopTimer(s.upgradeTimeoutTimer)
 		s.muupgradeTimeoutTimer.RUnlock()
 
 		// clean writeBuffer in defer, so developers can still
@@ -499,8 +651,16 @@ func (s *socket) OnClose(reason string, description ...any) {
 		s.sentCallbackFn = s.sentCallbackFn[:0]
 		s.musentCallbackFn.Unlock()
 
+		// fail the deliveries which never reached the transport
+		s.mudeliveries.Lock()
+		for p, delivery := range s.deliveries {
+			delivery <- ErrDeliveryAborted
+			delete(s.deliveries, p)
+		}
+		s.mudeliveries.Unlock()
+
 		s.clearTransport()
-		s.Emit("close", reason, description[0])
+		s.Emit(EVENT_CLOSE, reason, description[0])
 	}
 }
 
@@ -517,10 +677,10 @@ func (s *socket) setupSendCallback() {
 
 			switch fns := seqFn.(type) {
 			case func(transports.Transport):
-				socket_log.Debug("executing send callback")
+				s.log().Debug("executing send callback")
 				fns(s.Transport())
 			case []func(transports.Transport):
-				socket_log.Debug("executing batch send callback")
+				s.log().Debug("executing batch send callback")
 				for _, fn := range fns {
 					fn(s.Transport())
 				}
@@ -528,95 +688,185 @@ func (s *socket) setupSendCallback() {
 		}
 	}
 
-	s.Transport().On("drain", onDrain)
+	s.Transport().On(transports.EVENT_DRAIN, onDrain)
 
 	s.mucleanupFn.Lock()
 	s.cleanupFn = append(s.cleanupFn, func() {
-		s.Transport().RemoveListener("drain", onDrain)
+		s.Transport().RemoveListener(transports.EVENT_DRAIN, onDrain)
 	})
 	s.mucleanupFn.Unlock()
 }
 
+// Resolves the deliveries of the packets written by the transport.
+func (s *socket) onWritten(packets []*packet.Packet, err error) {
+	s.mudeliveries.Lock()
+	defer s.mudeliveries.Unlock()
+
+	for _, p := range packets {
+		if delivery, ok := s.deliveries[p]; ok {
+			s.log().Debug("resolving packet delivery")
+			delivery <- err
+			delete(s.deliveries, p)
+		}
+	}
+}
+
 // Sends a message packet.
 func (s *socket) Send(data io.Reader, options *packet.Options, callback func(transports.Transport)) Socket {
-	s.sendPacket(packet.MESSAGE, data, options, callback)
+	s.sendPacket(packet.MESSAGE, data, options, callback, nil)
 	return s
 }
 
 func (s *socket) Write(data io.Reader, options *packet.Options, callback func(transports.Transport)) Socket {
-	s.sendPacket(packet.MESSAGE, data, options, callback)
+	s.sendPacket(packet.MESSAGE, data, options, callback, nil)
 	return s
 }
 
+// Sends a message packet, the returned channel receives nil once the transport
+// has completed the write, or an error if it failed or the socket was closed first.
+func (s *socket) SendWithDelivery(data io.Reader, options *packet.Options) <-chan error {
+	delivery := make(chan error, 1)
+	s.sendPacket(packet.MESSAGE, data, options, nil, delivery)
+	return delivery
+}
+
 // Sends a packet.
-func (s *socket) sendPacket(packetType packet.Type, data io.Reader, options *packet.Options, callback func(transports.Transport)) {
+func (s *socket) sendPacket(packetType packet.Type, data io.Reader, options *packet.Options, callback func(transports.Transport), delivery chan error) {
+	if "closing" == s.ReadyState() || "closed" == s.ReadyState() {
+		if delivery != nil {
+			delivery <- ErrDeliveryAborted
+		}
+		return
+	}
 
-	if "closing" != s.ReadyState() && "closed" != s.ReadyState() {
-		socket_log.Debug(`sending packet "%s" (%v)`, packetType, data)
+	s.log().Debug(`sending packet "%s" (%v)`, packetType, data)
 
-		packet := &packet.Packet{
-			Type:    packetType,
-			Data:    data,
-			Options: options,
-		}
+	packet := &packet.Packet{
+		Type:    packetType,
+		Data:    data,
+		Options: options,
+	}
 
-		// exports packetCreate event
-		s.Emit("packetCreate", packet)
+	// exports packetCreate event
+	s.Emit(EVENT_PACKET_CREATE, packet)
 
-		s.muwriteBuffer.Lock()
-		s.writeBuffer = append(s.writeBuffer, packet)
-		s.muwriteBuffer.Unlock()
+	// register the delivery before the packet can be flushed
+	if delivery != nil {
+		s.mudeliveries.Lock()
+		s.deliveries[packet] = delivery
+		s.mudeliveries.Unlock()
+	}
 
-		// add send callback to object, if defined
-		if callback != nil {
-			s.mupacketsFn.Lock()
-			s.packetsFn = append(s.packetsFn, callback)
-			s.mupacketsFn.Unlock()
-		}
+	s.enqueue(packet)
 
-		s.flush()
+	// add send callback to object, if defined
+	if callback != nil {
+		s.mupacketsFn.Lock()
+		s.packetsFn = append(s.packetsFn, callback)
+		s.mupacketsFn.Unlock()
 	}
+
+	s.flush()
 }
 
-// Attempts to flush the packets buffer.
-func (s *socket) flush() {
-	s.muwriteBuffer.RLock()
-	wbuf := append([]*packet.Packet{}, s.writeBuffer...)
-	s.muwriteBuffer.RUnlock()
+// Returns the write priority of a packet, protocol packets are always control packets.
+func priorityOf(data *packet.Packet) packet.Priority {
+	if data.Type != packet.MESSAGE {
+		return packet.PRIORITY_CONTROL
+	}
+	if data.Options == nil {
+		return packet.PRIORITY_NORMAL
+	}
+	if data.Options.Priority > packet.PRIORITY_HIGH {
+		return packet.PRIORITY_HIGH
+	}
+	return data.Options.Priority
+}
+
+// Appends a packet to the write buffer, superseding a queued bulk message with
+// the same key according to the bulk policy.
+func (s *socket) enqueue(data *packet.Packet) {
+	s.muwriteBuffer.Lock()
+	defer s.muwriteBuffer.Unlock()
+
+	if policy := s.BulkPolicy(); policy != BULK_KEEP_ALL && priorityOf(data) == packet.PRIORITY_BULK && data.Options.Key != "" {
+		for i, queued := range s.writeBuffer {
+			if priorityOf(queued) != packet.PRIORITY_BULK || queued.Options.Key != data.Options.Key {
+				continue
+			}
+			s.log().Debug(`bulk message "%s" superseded`, data.Options.Key)
+			s.onWritten([]*packet.Packet{queued}, ErrSuperseded)
+			if policy == BULK_COALESCE {
+				s.writeBuffer[i] = data
+				return
+			}
+			s.writeBuffer = append(s.writeBuffer[:i], s.writeBuffer[i+1:]...)
+			break
+		}
+	}
 
-	if "closed" != s.ReadyState() && s.Transport().Writable() && len(wbuf) > 0 {
-		socket_log.Debug("flushing buffer to transport")
-		s.Emit("flush", wbuf)
-		s.server.Emit("flush", s, wbuf)
+	s.writeBuffer = append(s.writeBuffer, data)
+}
 
-		s.muwriteBuffer.Lock()
-		s.writeBuffer = s.writeBuffer[:0]
+// Attempts to flush the packets buffer.
+func (s *socket) flush() {
+	// the buffer is taken and emptied at once, the packets enqueued or
+	// superseded meanwhile are left to the next flush
+	s.muwriteBuffer.Lock()
+	if "closed" == s.ReadyState() || !s.Transport().Writable() || len(s.writeBuffer) == 0 {
 		s.muwriteBuffer.Unlock()
+		return
+	}
+	wbuf := s.writeBuffer
+	s.writeBuffer = nil
+	s.muwriteBuffer.Unlock()
+
+	// control packets go first so that a backlog of messages can't delay the heartbeat
+	sort.SliceStable(wbuf, func(i, j int) bool {
+		return priorityOf(wbuf[i]) > priorityOf(wbuf[j])
+	})
 
-		if !s.Transport().SupportsFraming() {
-			s.musentCallbackFn.Lock()
-			s.mupacketsFn.RLock()
-			s.sentCallbackFn = append(s.sentCallbackFn, s.packetsFn)
-			s.mupacketsFn.RUnlock()
-			s.musentCallbackFn.Unlock()
+	s.log().Debug("flushing buffer to transport")
+	s.Emit(EVENT_FLUSH, wbuf)
+	s.server.Emit(EVENT_FLUSH, s, wbuf)
 
-		} else {
-			s.musentCallbackFn.Lock()
-			s.mupacketsFn.RLock()
-			for _, fn := range s.packetsFn {
-				s.sentCallbackFn = append(s.sentCallbackFn, fn)
-			}
-			s.mupacketsFn.RUnlock()
-			s.musentCallbackFn.Unlock()
+	recorder, transportName := s.server.Opts().Metrics(), s.Transport().Name()
+	for _, p := range wbuf {
+		recorder.Count(metrics.PACKETS_SENT, 1, metrics.Labels{"type": string(p.Type)})
+		if l, ok := p.Data.(interface{ Len() int }); ok {
+			recorder.Count(metrics.BYTES_SENT, float64(l.Len()), metrics.Labels{"transport": transportName})
 		}
-		s.mupacketsFn.Lock()
-		s.packetsFn = s.packetsFn[:0]
-		s.mupacketsFn.Unlock()
+	}
+
+	if !s.Transport().SupportsFraming() {
+		s.musentCallbackFn.Lock()
+		s.mupacketsFn.RLock()
+		s.sentCallbackFn = append(s.sentCallbackFn, s.packetsFn)
+		s.mupacketsFn.RUnlock()
+		s.musentCallbackFn.Unlock()
 
-		s.Transport().Send(wbuf)
-		s.Emit("drain")
-		s.server.Emit("drain", s)
+	} else {
+		s.musentCallbackFn.Lock()
+		s.mupacketsFn.RLock()
+		for _, fn := range s.packetsFn {
+			s.sentCallbackFn = append(s.sentCallbackFn, fn)
+		}
+		s.mupacketsFn.RUnlock()
+		s.musentCallbackFn.Unlock()
 	}
+	s.mupacketsFn.Lock()
+	s.packetsFn = s.packetsFn[:0]
+	s.mupacketsFn.Unlock()
+
+	_, span := tracing.Start(s.server.Opts().Tracer(), s.ctx, "engine.flush", tracing.Attributes{
+		"sid":       s.id,
+		"transport": transportName,
+		"packets":   len(wbuf),
+	})
+	s.Transport().Send(wbuf)
+	span.End(nil)
+	s.Emit(EVENT_DRAIN)
+	s.server.Emit(EVENT_DRAIN, s)
 }
 
 // Get available upgrades for this socket.
@@ -643,7 +893,7 @@ func (s *socket) Close(discard bool) {
 	s.muwriteBuffer.RUnlock()
 
 	if writeBufferLength > 0 {
-		s.Once("drain", func(...any) {
+		s.Once(EVENT_DRAIN, func(...any) {
 			s.closeTransport(discard)
 		})
 		return
@@ -657,5 +907,19 @@ func (s *socket) closeTransport(discard bool) {
 	if discard {
 		s.Transport().Discard()
 	}
-	s.Transport().Close(func() { s.OnClose("forced close") })
+	s.Transport().Close(func() { s.OnClose(CLOSE_FORCED_CLOSE) })
+}
+
+// Stops a timer of the socket, which may not have been scheduled yet.
+func stopTimer(timer utils.ClockTimer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+// Restarts a timer of the socket, which may not have been scheduled yet.
+func refreshTimer(timer utils.ClockTimer) {
+	if timer != nil {
+		timer.Refresh()
+	}
 }
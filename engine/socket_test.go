@@ -0,0 +1,295 @@
+package engine_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/conformance"
+	"github.com/zishang520/engine.io/engine"
+	"github.com/zishang520/engine.io/enginetest"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// Returns the data of the polled messages, in their order.
+func pollMessages(t *testing.T, client interface{ PollPackets() []*packet.Packet }) string {
+	t.Helper()
+
+	var messages []string
+	for _, p := range client.PollPackets() {
+		if p.Type == packet.MESSAGE {
+			messages = append(messages, fmt.Sprint(p.Data))
+		}
+	}
+	return fmt.Sprint(messages)
+}
+
+func send(socket engine.Socket, data string, priority packet.Priority, key string) <-chan error {
+	return socket.SendWithDelivery(types.NewStringBufferString(data), &packet.Options{Priority: priority, Key: key})
+}
+
+func expectDelivery(t *testing.T, delivery <-chan error, want error) {
+	t.Helper()
+
+	select {
+	case err := <-delivery:
+		if !errors.Is(err, want) {
+			t.Fatalf("delivery = %v, want match for %v", err, want)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the delivery was not reported")
+	}
+}
+
+func TestSendWithDelivery(t *testing.T) {
+	t.Run("Delivered", func(t *testing.T) {
+		server := enginetest.NewServer(t, nil)
+		client := server.NewClient(4, nil)
+		socket := server.Open(client)
+
+		// no poll is pending, the message waits in the write buffer
+		delivery := send(socket, "hello", packet.PRIORITY_NORMAL, "")
+		select {
+		case err := <-delivery:
+			t.Fatalf("delivery = %v before the message was written", err)
+		default:
+		}
+
+		if messages := pollMessages(t, client); messages != "[hello]" {
+			t.Fatalf("server sent %s, want match for %s", messages, "[hello]")
+		}
+		expectDelivery(t, delivery, nil)
+	})
+
+	t.Run("Aborted", func(t *testing.T) {
+		server := enginetest.NewServer(t, nil)
+		client := server.NewClient(4, nil)
+		socket := server.Open(client)
+		events := enginetest.Record(t, socket, engine.EVENT_CLOSE)
+
+		delivery := send(socket, "hello", packet.PRIORITY_NORMAL, "")
+		server.Clock.Advance(server.Opts().PingInterval() + server.Opts().PingTimeout())
+		events.Expect(engine.EVENT_CLOSE)
+		expectDelivery(t, delivery, engine.ErrDeliveryAborted)
+
+		// sent once closed
+		expectDelivery(t, send(socket, "late", packet.PRIORITY_NORMAL, ""), engine.ErrDeliveryAborted)
+	})
+}
+
+func TestPriorities(t *testing.T) {
+	server := enginetest.NewServer(t, nil)
+	client := server.NewClient(4, nil)
+	socket := server.Open(client)
+
+	send(socket, "normal 1", packet.PRIORITY_NORMAL, "")
+	send(socket, "bulk", packet.PRIORITY_BULK, "")
+	send(socket, "high", packet.PRIORITY_HIGH, "")
+	send(socket, "normal 2", packet.PRIORITY_NORMAL, "")
+	// PRIORITY_CONTROL is reserved for the protocol packets
+	send(socket, "control", packet.PRIORITY_CONTROL, "")
+
+	server.Clock.Advance(server.Opts().PingInterval())
+	packets := client.PollPackets()
+	if len(packets) == 0 || packets[0].Type != packet.PING {
+		t.Fatalf("server sent %v, want the ping first", packets)
+	}
+	var messages []string
+	for _, p := range packets[1:] {
+		messages = append(messages, fmt.Sprint(p.Data))
+	}
+	if want := "[high control normal 1 normal 2 bulk]"; fmt.Sprint(messages) != want {
+		t.Fatalf("server sent %v, want match for %s", messages, want)
+	}
+}
+
+func TestBulkPolicy(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		policy     engine.BulkPolicy
+		messages   string
+		superseded bool
+	}{
+		{"KeepAll", engine.BULK_KEEP_ALL, "[normal a1 b a2]", false},
+		{"Coalesce", engine.BULK_COALESCE, "[normal a2 b]", true},
+		{"DropSuperseded", engine.BULK_DROP_SUPERSEDED, "[normal b a2]", true},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			server := enginetest.NewServer(t, nil)
+			client := server.NewClient(4, nil)
+			socket := server.Open(client)
+			socket.SetBulkPolicy(test.policy)
+			if policy := socket.BulkPolicy(); policy != test.policy {
+				t.Fatalf("BulkPolicy() = %v, want match for %v", policy, test.policy)
+			}
+
+			first := send(socket, "a1", packet.PRIORITY_BULK, "a")
+			send(socket, "b", packet.PRIORITY_BULK, "b")
+			second := send(socket, "a2", packet.PRIORITY_BULK, "a")
+			// a normal message with the same key is never superseded
+			send(socket, "normal", packet.PRIORITY_NORMAL, "a")
+			if test.superseded {
+				// reported before the flush
+				expectDelivery(t, first, engine.ErrSuperseded)
+			}
+
+			if messages := pollMessages(t, client); messages != test.messages {
+				t.Fatalf("server sent %s, want match for %s", messages, test.messages)
+			}
+			if !test.superseded {
+				expectDelivery(t, first, nil)
+			}
+			expectDelivery(t, second, nil)
+		})
+	}
+}
+
+// Opens a session over a WebSocket and returns its socket.
+func openWebSocket(t *testing.T, server *enginetest.Server) (*websocket.Conn, engine.Socket) {
+	t.Helper()
+
+	conn := server.NewClient(4, nil).Dial(nil)
+	if _, data := conformance.ReadFrame(t, conn, 5*time.Second); len(data) == 0 || data[0] != '0' {
+		t.Fatalf("server sent %q, want match for an open packet", data)
+	}
+	var socket engine.Socket
+	server.Clients().Range(func(_, client any) bool {
+		socket = client.(engine.Socket)
+		return false
+	})
+	return conn, socket
+}
+
+func TestSendWhileFlushing(t *testing.T) {
+	server := enginetest.NewServer(t, nil)
+	conn, socket := openWebSocket(t, server)
+
+	var late <-chan error
+	socket.Once(engine.EVENT_FLUSH, func(...any) {
+		late = send(socket, "late", packet.PRIORITY_NORMAL, "")
+	})
+	first := send(socket, "first", packet.PRIORITY_NORMAL, "")
+	send(socket, "end", packet.PRIORITY_NORMAL, "")
+
+	var messages []string
+	for len(messages) == 0 || messages[len(messages)-1] != "end" {
+		if _, data := conformance.ReadFrame(t, conn, 5*time.Second); data[0] == '4' {
+			messages = append(messages, string(data[1:]))
+		}
+	}
+	// sent once, by the flush of the listener which writes before the one it is
+	// called from
+	if want := "[late first end]"; fmt.Sprint(messages) != want {
+		t.Fatalf("server sent %v, want match for %s", messages, want)
+	}
+	expectDelivery(t, first, nil)
+	expectDelivery(t, late, nil)
+}
+
+func TestErrors(t *testing.T) {
+	t.Run("InvalidHeartbeat", func(t *testing.T) {
+		server := enginetest.NewServer(t, nil)
+		client := server.NewClient(4, nil)
+		events := enginetest.Record(t, server.Open(client), engine.EVENT_CLOSE)
+
+		// the server pings in protocol v4
+		client.SendAndDrop(&packet.Packet{Type: packet.PING})
+		args := events.Expect(engine.EVENT_CLOSE).Args
+		if err, _ := args[1].(error); args[0] != engine.CLOSE_TRANSPORT_ERROR || !errors.Is(err, engine.ErrInvalidHeartbeat) {
+			t.Fatalf("socket closed with %v, want match for %v and %v", args, engine.CLOSE_TRANSPORT_ERROR, engine.ErrInvalidHeartbeat)
+		}
+	})
+
+	t.Run("UpgradeAborted", func(t *testing.T) {
+		server := enginetest.NewServer(t, nil)
+		client := server.NewClient(4, nil)
+		socket := server.Open(client)
+		events := enginetest.Record(t, socket, engine.EVENT_UPGRADING, engine.EVENT_UPGRADE_ERROR)
+
+		conn := client.Dial(nil)
+		if err := conn.WriteMessage(websocket.TextMessage, []byte("2probe")); err != nil {
+			t.Fatal(err)
+		}
+		events.Expect(engine.EVENT_UPGRADING)
+
+		socket.Close(true)
+		err, _ := events.Expect(engine.EVENT_UPGRADE_ERROR).Args[1].(error)
+		if !errors.Is(err, engine.ErrUpgradeFailed) || !errors.Is(err, engine.ErrSocketClosed) {
+			t.Fatalf("upgrade failed with %v, want match for %v caused by %v", err, engine.ErrUpgradeFailed, engine.ErrSocketClosed)
+		}
+	})
+}
+
+func TestJSONPPayloads(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		payload  string
+		status   int
+		messages string
+	}{
+		{"Valid", "4a\nb\x1e4c", http.StatusOK, "[a\nb c]"},
+		{"InvalidPacket", "4a\x1e9x\x1e4c", http.StatusBadRequest, "[a]"},
+		{"PacketTooLarge", "4" + strings.Repeat("x", 32), http.StatusRequestEntityTooLarge, "[]"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			opts := config.DefaultServerOptions()
+			opts.SetMaxPacketSize(16)
+			server := enginetest.NewServer(t, opts)
+			client := server.NewClient(4, url.Values{"j": {"0"}})
+			socket := server.Open(client)
+			var messages []string
+			engine.OnMessage(socket, func(data io.Reader, _ context.Context) {
+				messages = append(messages, fmt.Sprint(data))
+			})
+			events := enginetest.Record(t, socket, engine.EVENT_CLOSE)
+
+			if res := client.Post(test.payload); res.StatusCode != test.status {
+				t.Fatalf("POST answered %d, want match for %d", res.StatusCode, test.status)
+			}
+			if fmt.Sprint(messages) != test.messages {
+				t.Fatalf("socket received %q, want match for %q", messages, test.messages)
+			}
+			if test.status == http.StatusOK {
+				events.ExpectNone(engine.EVENT_CLOSE)
+			} else if reason := events.Expect(engine.EVENT_CLOSE).Args[0]; reason != engine.CLOSE_TRANSPORT_ERROR {
+				t.Fatalf("socket closed with %v, want match for %v", reason, engine.CLOSE_TRANSPORT_ERROR)
+			}
+		})
+	}
+}
+
+func TestAdaptiveHeartbeat(t *testing.T) {
+	opts := config.DefaultServerOptions()
+	opts.SetAdaptiveHeartbeat(true)
+	server := enginetest.NewServer(t, opts)
+	client := server.NewClient(4, nil)
+	socket := server.Open(client)
+	events := enginetest.Record(t, socket, engine.EVENT_LATENCY, engine.EVENT_CLOSE)
+	interval, timeout := server.Opts().PingInterval(), server.Opts().PingTimeout()
+
+	server.Clock.Advance(interval)
+	rtt := 3 * time.Second
+	server.Clock.Advance(rtt)
+	client.Send(&packet.Packet{Type: packet.PONG})
+	if args := events.Expect(engine.EVENT_LATENCY).Args; args[0] != rtt || socket.Latency().P99 != rtt {
+		t.Fatalf("latency = %v, want match for %s", args, rtt)
+	}
+
+	// the next ping waits for the ping timeout extended by twice the p99
+	server.Clock.Advance(interval)
+	server.Clock.Advance(timeout)
+	events.ExpectNone(engine.EVENT_CLOSE)
+	server.Clock.Advance(2 * rtt)
+	if reason := events.Expect(engine.EVENT_CLOSE).Args[0]; reason != engine.CLOSE_PING_TIMEOUT {
+		t.Fatalf("socket closed with %v, want match for %v", reason, engine.CLOSE_PING_TIMEOUT)
+	}
+}
@@ -1,9 +1,11 @@
 package engine
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"sync"
+	"time"
 
 	"github.com/zishang520/engine.io/config"
 	"github.com/zishang520/engine.io/events"
@@ -12,6 +14,40 @@ import (
 	"github.com/zishang520/engine.io/types"
 )
 
+type BulkPolicy int
+
+// How a queued bulk message is handled when a newer one with the same key is sent.
+const (
+	BULK_KEEP_ALL        BulkPolicy = iota // every bulk message is sent
+	BULK_COALESCE                          // the queued message is replaced in place by the newer one
+	BULK_DROP_SUPERSEDED                   // the queued message is dropped and the newer one is queued last
+)
+
+// CloseReason is the first argument of the "close" event of a socket.
+type CloseReason string
+
+// Reasons for a socket to close.
+const (
+	CLOSE_PING_TIMEOUT    CloseReason = "ping timeout"    // the client did not answer the heartbeat in time
+	CLOSE_PARSE_ERROR     CloseReason = "parse error"     // the client sent a packet that could not be decoded
+	CLOSE_TRANSPORT_ERROR CloseReason = "transport error" // the transport failed, the description is the error
+	CLOSE_TRANSPORT_CLOSE CloseReason = "transport close" // the client closed the connection
+	CLOSE_FORCED_CLOSE    CloseReason = "forced close"    // the socket was closed by the server
+)
+
+var closeErrors map[CloseReason]error = map[CloseReason]error{
+	CLOSE_PING_TIMEOUT:    ErrPingTimeout,
+	CLOSE_PARSE_ERROR:     ErrParse,
+	CLOSE_TRANSPORT_ERROR: ErrTransportError,
+	CLOSE_TRANSPORT_CLOSE: ErrTransportClose,
+	CLOSE_FORCED_CLOSE:    ErrForcedClose,
+}
+
+// Returns the sentinel error of the reason, nil for an unknown reason.
+func (r CloseReason) Err() error {
+	return closeErrors[r]
+}
+
 type Server interface {
 	events.EventEmitter
 
@@ -56,9 +92,23 @@ type Socket interface {
 	Server() Server
 	Request() *types.HttpContext
 	RemoteAddress() string
+
+	// Returns a context carrying the span context of the handshake.
+	Context() context.Context
 	Upgraded() bool
 	Upgrading() bool
+	CreatedAt() time.Time
+
+	// Returns the number of packets waiting to be flushed.
+	WriteBufferLen() int
+
+	// Returns the round-trip time statistics measured from the heartbeat.
+	Latency() Latency
 	Transport() transports.Transport
+	BulkPolicy() BulkPolicy
+
+	// Sets how superseded bulk messages are handled.
+	SetBulkPolicy(BulkPolicy)
 
 	// Upgrades socket to the given transport
 	MaybeUpgrade(transports.Transport)
@@ -67,6 +117,9 @@ type Socket interface {
 	Send(io.Reader, *packet.Options, func(transports.Transport)) Socket
 	Write(io.Reader, *packet.Options, func(transports.Transport)) Socket
 
+	// Sends a message packet and reports once the transport has completed the write.
+	SendWithDelivery(io.Reader, *packet.Options) <-chan error
+
 	// Closes the socket and underlying transport.
 	Close(bool)
 }
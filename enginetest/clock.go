@@ -0,0 +1,169 @@
+package enginetest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/zishang520/engine.io/utils"
+)
+
+// A manual clock, the time only moves when advanced so that the heartbeats and
+// timeouts fire at will. The timers call their function in the goroutine
+// advancing the clock, in the order of their expiration.
+type Clock struct {
+	now    time.Time
+	timers []*clockTimer
+	seq    uint64 // orders the timers expiring together
+
+	mu sync.Mutex
+}
+
+// A timer scheduled by a Clock.
+type clockTimer struct {
+	clock    *Clock
+	fn       func()
+	sleep    time.Duration
+	interval bool
+
+	when      time.Time
+	seq       uint64
+	scheduled bool
+	stopped   bool
+}
+
+// Creates a clock set to now.
+func NewClock(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+func (c *Clock) SetTimeout(fn func(), sleep time.Duration) utils.ClockTimer {
+	return c.schedule(&clockTimer{clock: c, fn: fn, sleep: sleep})
+}
+
+func (c *Clock) SetInterval(fn func(), sleep time.Duration) utils.ClockTimer {
+	if sleep <= 0 {
+		// an interval must move forward
+		sleep = time.Nanosecond
+	}
+	return c.schedule(&clockTimer{clock: c, fn: fn, sleep: sleep, interval: true})
+}
+
+// Returns the number of pending timers.
+func (c *Clock) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.timers)
+}
+
+// Moves the time forward by d, firing the timers expiring meanwhile. A timer
+// scheduled by a fired one fires too when it expires within d.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	until := c.now.Add(d)
+	c.mu.Unlock()
+
+	for {
+		c.mu.Lock()
+		t := c.first()
+		if t == nil || t.when.After(until) {
+			c.now = until
+			c.mu.Unlock()
+			return
+		}
+		c.remove(t)
+		c.now = t.when
+		if t.interval {
+			c.add(t, t.when.Add(t.sleep))
+		}
+		c.mu.Unlock()
+
+		t.fn()
+	}
+}
+
+// Advances the time to the expiration of the next timer and fires it, returns
+// false when no timer is pending.
+func (c *Clock) AdvanceNext() bool {
+	c.mu.Lock()
+	t := c.first()
+	if t == nil {
+		c.mu.Unlock()
+		return false
+	}
+	d := t.when.Sub(c.now)
+	c.mu.Unlock()
+
+	c.Advance(d)
+	return true
+}
+
+// Schedules the timer sleep from now.
+func (c *Clock) schedule(t *clockTimer) *clockTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.add(t, c.now.Add(t.sleep))
+	return t
+}
+
+// Adds the timer expiring at when, c.mu must be held.
+func (c *Clock) add(t *clockTimer, when time.Time) {
+	c.seq++
+	t.when, t.seq, t.scheduled = when, c.seq, true
+	c.timers = append(c.timers, t)
+}
+
+// Returns the timer expiring first, c.mu must be held.
+func (c *Clock) first() *clockTimer {
+	first := -1
+	for i, t := range c.timers {
+		if first < 0 || t.when.Before(c.timers[first].when) || (t.when.Equal(c.timers[first].when) && t.seq < c.timers[first].seq) {
+			first = i
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+	return c.timers[first]
+}
+
+// Unlinks the timer, c.mu must be held.
+func (c *Clock) remove(t *clockTimer) bool {
+	if !t.scheduled {
+		return false
+	}
+	t.scheduled = false
+	for i, pending := range c.timers {
+		if pending == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+func (t *clockTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.stopped = true
+	return t.clock.remove(t)
+}
+
+func (t *clockTimer) Refresh() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	if !t.stopped {
+		t.clock.remove(t)
+		t.clock.add(t, t.clock.now.Add(t.sleep))
+	}
+}
@@ -0,0 +1,121 @@
+package enginetest
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	t.Run("SetTimeout", func(t *testing.T) {
+		c := NewClock(Epoch)
+		var fired []time.Duration
+		for _, sleep := range []time.Duration{30 * time.Second, 5 * time.Second, 5 * time.Second, 90 * time.Second} {
+			sleep := sleep
+			c.SetTimeout(func() {
+				if elapsed := c.Now().Sub(Epoch); elapsed != sleep {
+					t.Fatalf("timer of %s fired at %s", sleep, elapsed)
+				}
+				fired = append(fired, sleep)
+			}, sleep)
+		}
+
+		c.Advance(30 * time.Second)
+		if want := []time.Duration{5 * time.Second, 5 * time.Second, 30 * time.Second}; !reflect.DeepEqual(fired, want) {
+			t.Fatalf("*Clock.Advance() fired %v, want match for %v", fired, want)
+		}
+		if now := c.Now(); !now.Equal(Epoch.Add(30 * time.Second)) {
+			t.Fatalf("*Clock.Now() = %v, want match for %v", now, Epoch.Add(30*time.Second))
+		}
+		if n := c.Len(); n != 1 {
+			t.Fatalf("*Clock.Len() = %d, want match for %d", n, 1)
+		}
+	})
+
+	t.Run("SetInterval", func(t *testing.T) {
+		c := NewClock(Epoch)
+		fired := 0
+		timer := c.SetInterval(func() { fired++ }, time.Second)
+
+		c.Advance(3500 * time.Millisecond)
+		if fired != 3 {
+			t.Fatalf("interval fired %d times, want match for %d", fired, 3)
+		}
+		if !timer.Stop() {
+			t.Fatal("*clockTimer.Stop() = false, want match for true")
+		}
+		c.Advance(time.Minute)
+		if fired != 3 {
+			t.Fatalf("stopped interval fired %d times, want match for %d", fired, 3)
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		c := NewClock(Epoch)
+		timer := c.SetTimeout(func() { t.Fatal("stopped timer fired") }, time.Second)
+		if !timer.Stop() {
+			t.Fatal("*clockTimer.Stop() = false, want match for true")
+		}
+		if timer.Stop() {
+			t.Fatal("*clockTimer.Stop() = true on a stopped timer, want match for false")
+		}
+		timer.Refresh()
+		c.Advance(time.Minute)
+		if n := c.Len(); n != 0 {
+			t.Fatalf("*Clock.Len() = %d, want match for %d", n, 0)
+		}
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		c := NewClock(Epoch)
+		fired := 0
+		timer := c.SetTimeout(func() { fired++ }, time.Second)
+
+		c.Advance(800 * time.Millisecond)
+		timer.Refresh()
+		c.Advance(800 * time.Millisecond)
+		if fired != 0 {
+			t.Fatalf("refreshed timer fired %d times, want match for %d", fired, 0)
+		}
+		c.Advance(200 * time.Millisecond)
+		if fired != 1 {
+			t.Fatalf("refreshed timer fired %d times, want match for %d", fired, 1)
+		}
+
+		// a fired timer is restarted
+		timer.Refresh()
+		c.Advance(time.Second)
+		if fired != 2 {
+			t.Fatalf("refreshed timer fired %d times, want match for %d", fired, 2)
+		}
+	})
+
+	t.Run("Nested", func(t *testing.T) {
+		c := NewClock(Epoch)
+		var fired []string
+		c.SetTimeout(func() {
+			fired = append(fired, "outer")
+			c.SetTimeout(func() { fired = append(fired, "inner") }, time.Second)
+		}, time.Second)
+
+		c.Advance(2 * time.Second)
+		if want := []string{"outer", "inner"}; !reflect.DeepEqual(fired, want) {
+			t.Fatalf("*Clock.Advance() fired %v, want match for %v", fired, want)
+		}
+	})
+
+	t.Run("AdvanceNext", func(t *testing.T) {
+		c := NewClock(Epoch)
+		if c.AdvanceNext() {
+			t.Fatal("*Clock.AdvanceNext() = true without timers, want match for false")
+		}
+		fired := false
+		c.SetTimeout(func() { fired = true }, time.Hour)
+		if !c.AdvanceNext() || !fired {
+			t.Fatal("*Clock.AdvanceNext() did not fire the pending timer")
+		}
+		if now := c.Now(); !now.Equal(Epoch.Add(time.Hour)) {
+			t.Fatalf("*Clock.Now() = %v, want match for %v", now, Epoch.Add(time.Hour))
+		}
+	})
+}
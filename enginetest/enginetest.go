@@ -0,0 +1,82 @@
+// Package enginetest runs an engine.Server on a manual clock for the tests of
+// the applications, the heartbeats and timeouts fire when the clock is
+// advanced rather than after real time:
+//
+//	func TestPingTimeout(t *testing.T) {
+//		server := enginetest.NewServer(t, nil)
+//		client := server.NewClient(4, nil)
+//		events := enginetest.Record(t, server.Open(client), engine.EVENT_CLOSE)
+//
+//		server.Clock.Advance(server.Opts().PingInterval() + server.Opts().PingTimeout())
+//		events.Expect(engine.EVENT_CLOSE)
+//	}
+//
+// The clients are the raw clients of the conformance package.
+package enginetest
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/conformance"
+	"github.com/zishang520/engine.io/engine"
+)
+
+// The time the clocks of the servers start at.
+var Epoch = time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// An engine.Server listening on a local port, on a manual clock.
+type Server struct {
+	engine.Server
+
+	// The clock of the heartbeats and timeouts of the server.
+	Clock *Clock
+
+	// The Engine.IO URL of the server.
+	URL string
+
+	tb testing.TB
+}
+
+// Starts a server with opts, closed at the end of the test. The server runs on
+// the Clock of opts, or on a new one starting at Epoch.
+func NewServer(tb testing.TB, opts *config.ServerOptions) *Server {
+	if opts == nil {
+		opts = config.DefaultServerOptions()
+	}
+	clock, ok := opts.GetRawClock().(*Clock)
+	if !ok {
+		clock = NewClock(Epoch)
+		opts.SetClock(clock)
+	}
+
+	server := engine.NewServer(opts)
+	srv := httptest.NewServer(server)
+	tb.Cleanup(func() {
+		// answers the pending polls before the listener waits for them
+		server.Close()
+		srv.Close()
+	})
+	return &Server{Server: server, Clock: clock, URL: srv.URL + "/engine.io/", tb: tb}
+}
+
+// Creates a client of the server speaking the protocol revision, the query is
+// appended to its requests.
+func (s *Server) NewClient(protocol int, query url.Values) *conformance.Client {
+	return conformance.NewClient(s.tb, s.URL, protocol, query)
+}
+
+// Opens a session of the client over polling and returns its socket.
+func (s *Server) Open(client *conformance.Client) engine.Socket {
+	s.tb.Helper()
+
+	client.Open()
+	socket, ok := s.Clients().Load(client.Sid)
+	if !ok {
+		s.tb.Fatalf("session %q is not a client of the server", client.Sid)
+	}
+	return socket.(engine.Socket)
+}
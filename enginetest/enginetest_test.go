@@ -0,0 +1,82 @@
+package enginetest
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/engine"
+	"github.com/zishang520/engine.io/packet"
+)
+
+func TestServer(t *testing.T) {
+	t.Run("PingTimeout", func(t *testing.T) {
+		server := NewServer(t, nil)
+		client := server.NewClient(4, nil)
+		events := Record(t, server.Open(client), engine.EVENT_CLOSE)
+
+		server.Clock.Advance(server.Opts().PingInterval())
+		if packets := client.PollPackets(); len(packets) != 1 || packets[0].Type != packet.PING {
+			t.Fatalf("server sent %v, want a ping", packets)
+		}
+		events.ExpectNone(engine.EVENT_CLOSE)
+
+		server.Clock.Advance(server.Opts().PingTimeout())
+		if reason := events.Expect(engine.EVENT_CLOSE).Args[0]; reason != engine.CLOSE_PING_TIMEOUT {
+			t.Fatalf("socket closed with %v, want match for %v", reason, engine.CLOSE_PING_TIMEOUT)
+		}
+	})
+
+	t.Run("Heartbeat", func(t *testing.T) {
+		server := NewServer(t, nil)
+		client := server.NewClient(4, nil)
+		events := Record(t, server.Open(client), engine.EVENT_HEARTBEAT, engine.EVENT_CLOSE)
+
+		for i := 0; i < 3; i++ {
+			server.Clock.Advance(server.Opts().PingInterval())
+			client.PollPackets()
+			client.Send(&packet.Packet{Type: packet.PONG})
+			events.Expect(engine.EVENT_HEARTBEAT)
+		}
+		events.ExpectNone(engine.EVENT_CLOSE)
+	})
+
+	t.Run("PingTimeoutV3", func(t *testing.T) {
+		opts := config.DefaultServerOptions()
+		opts.SetAllowEIO3(true)
+		server := NewServer(t, opts)
+		client := server.NewClient(3, nil)
+		events := Record(t, server.Open(client), engine.EVENT_CLOSE)
+
+		server.Clock.Advance(server.Opts().PingInterval())
+		client.Send(&packet.Packet{Type: packet.PING})
+		server.Clock.Advance(server.Opts().PingInterval())
+		events.ExpectNone(engine.EVENT_CLOSE)
+
+		server.Clock.Advance(server.Opts().PingTimeout())
+		if reason := events.Expect(engine.EVENT_CLOSE).Args[0]; reason != engine.CLOSE_PING_TIMEOUT {
+			t.Fatalf("socket closed with %v, want match for %v", reason, engine.CLOSE_PING_TIMEOUT)
+		}
+	})
+
+	t.Run("CloseTimeout", func(t *testing.T) {
+		server := NewServer(t, nil)
+		client := server.NewClient(4, nil)
+		socket := server.Open(client)
+		events := Record(t, socket, engine.EVENT_CLOSE)
+
+		// no poll is pending, the close packet waits for one
+		socket.Close(false)
+		events.ExpectNone(engine.EVENT_CLOSE)
+
+		// the close timeout of polling
+		server.Clock.Advance(30 * time.Second)
+		if reason := events.Expect(engine.EVENT_CLOSE).Args[0]; reason != engine.CLOSE_FORCED_CLOSE {
+			t.Fatalf("socket closed with %v, want match for %v", reason, engine.CLOSE_FORCED_CLOSE)
+		}
+		if res := client.Poll(); res.StatusCode != http.StatusBadRequest {
+			t.Fatalf("poll of the closed session answered %d, want match for %d", res.StatusCode, http.StatusBadRequest)
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package enginetest
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/events"
+)
+
+// How long Expect waits for an event, the events of the sockets are emitted by
+// the goroutines serving the requests.
+var WaitTimeout = time.Second
+
+// An event recorded by a Recorder.
+type Event struct {
+	Name events.EventName
+	Args []any
+}
+
+// Records the events of an emitter so that the tests can assert them, the
+// events are kept until expected.
+type Recorder struct {
+	tb      testing.TB
+	events  []*Event
+	changed chan struct{} // closed when an event is recorded
+
+	mu sync.Mutex
+}
+
+// Records the named events of emitter until the end of the test.
+func Record(tb testing.TB, emitter events.EventEmitter, names ...events.EventName) *Recorder {
+	r := &Recorder{tb: tb, changed: make(chan struct{})}
+	for _, name := range names {
+		name := name
+		listener := func(args ...any) {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+
+			r.events = append(r.events, &Event{Name: name, Args: args})
+			close(r.changed)
+			r.changed = make(chan struct{})
+		}
+		emitter.On(name, listener)
+		tb.Cleanup(func() { emitter.RemoveListener(name, listener) })
+	}
+	return r
+}
+
+// Returns the events recorded and not expected yet, in their order.
+func (r *Recorder) Events() []*Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]*Event{}, r.events...)
+}
+
+// Waits for an event of the name and returns it, the test fails when none is
+// emitted within WaitTimeout.
+func (r *Recorder) Expect(name events.EventName) *Event {
+	r.tb.Helper()
+
+	timeout := time.NewTimer(WaitTimeout)
+	defer timeout.Stop()
+
+	for {
+		r.mu.Lock()
+		for i, event := range r.events {
+			if event.Name == name {
+				r.events = append(r.events[:i], r.events[i+1:]...)
+				r.mu.Unlock()
+				return event
+			}
+		}
+		changed := r.changed
+		r.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-timeout.C:
+			r.tb.Fatalf("event %q not emitted within %s", name, WaitTimeout)
+			return nil
+		}
+	}
+}
+
+// Fails the test when an event of the name was recorded and not expected.
+func (r *Recorder) ExpectNone(name events.EventName) {
+	r.tb.Helper()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, event := range r.events {
+		if event.Name == name {
+			r.tb.Fatalf("event %q emitted with %v, want none", name, event.Args)
+		}
+	}
+}
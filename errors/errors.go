@@ -1,9 +1,14 @@
 package errors
 
+import (
+	"errors"
+)
+
 type Error struct {
 	Message     string
 	Description error
 	Type        string
+	Code        string // stable identifier of the failure, compared by Is
 }
 
 func (e *Error) Err() error {
@@ -14,6 +19,31 @@ func (e *Error) Error() string {
 	return e.Message
 }
 
+// Returns the error this one wraps.
+func (e *Error) Unwrap() error {
+	return e.Description
+}
+
+// Reports whether target is an *Error with the same code, so that a sentinel
+// matches every error created from it.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code != "" && t.Code == e.Code
+}
+
+// Sets the code of the error.
+func (e *Error) WithCode(code string) *Error {
+	e.Code = code
+	return e
+}
+
+// Returns a copy of the error wrapping description.
+func (e *Error) Wrap(description error) *Error {
+	err := *e
+	err.Description = description
+	return &err
+}
+
 func New(message string) *Error {
 	return &Error{Message: message}
 }
@@ -23,5 +53,21 @@ func NewTransportError(reason string, description error) *Error {
 		Message:     reason,
 		Description: description,
 		Type:        "TransportError",
+		Code:        "TRANSPORT_ERROR",
 	}
 }
+
+// Reports whether any error in err's chain matches target, see errors.Is.
+func Is(err, target error) bool {
+	return errors.Is(err, target)
+}
+
+// Finds the first error in err's chain that matches target, see errors.As.
+func As(err error, target any) bool {
+	return errors.As(err, target)
+}
+
+// Returns the result of calling the Unwrap method on err, see errors.Unwrap.
+func Unwrap(err error) error {
+	return errors.Unwrap(err)
+}
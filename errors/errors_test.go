@@ -17,3 +17,35 @@ func TestError(t *testing.T) {
 		}
 	})
 }
+
+func TestWrap(t *testing.T) {
+	sentinel := New("payload too large").WithCode("PAYLOAD_TOO_LARGE")
+	cause := New("read limit exceeded").Err()
+	err := NewTransportError("Error reading data", sentinel.Wrap(cause)).Err()
+
+	t.Run("Is", func(t *testing.T) {
+		if !Is(err, sentinel) {
+			t.Fatalf(`Is(%v, %v) = false, want match for true`, err, sentinel)
+		}
+		if !Is(err, cause) {
+			t.Fatalf(`Is(%v, %v) = false, want match for true`, err, cause)
+		}
+		if Is(err, New("payload too large")) {
+			t.Fatal(`Is() should not match an error without code`)
+		}
+	})
+	t.Run("As", func(t *testing.T) {
+		var e *Error
+		if !As(err, &e) || e.Type != "TransportError" || e.Code != "TRANSPORT_ERROR" {
+			t.Fatalf(`As() = %+v, want match for the transport error`, e)
+		}
+	})
+	t.Run("Unwrap", func(t *testing.T) {
+		if inner := Unwrap(Unwrap(err)); inner != cause {
+			t.Fatalf(`Unwrap() = %v, want match for %v`, inner, cause)
+		}
+		if sentinel.Description != nil {
+			t.Fatal(`*Error.Wrap() should not modify the sentinel`)
+		}
+	})
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"regexp"
+	"strings"
+)
+
+// AnyListener receives the name and the arguments of the events it observes.
+type AnyListener func(EventName, ...any)
+
+type anyListener struct {
+	listener AnyListener
+	id       uintptr
+	pattern  *regexp.Regexp // nil for every event
+}
+
+func (e *emmiter) OnAny(listener AnyListener) {
+	e.addAnyListener(listener, nil)
+}
+
+func (e *emmiter) OnPattern(pattern string, listener AnyListener) {
+	e.addAnyListener(listener, regexp.MustCompile("^"+strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `.*`)+"$"))
+}
+
+func (e *emmiter) addAnyListener(listener AnyListener, pattern *regexp.Regexp) {
+	if listener == nil {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.anyListeners = append(e.anyListeners, &anyListener{listener: listener, id: identity(listener), pattern: pattern})
+}
+
+func (e *emmiter) OffAny(listener AnyListener) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if listener == nil {
+		removed := len(e.anyListeners) > 0
+		e.anyListeners = nil
+		return removed
+	}
+
+	id := identity(listener)
+	for i, l := range e.anyListeners {
+		if l.id == id {
+			e.anyListeners = append(e.anyListeners[:i:i], e.anyListeners[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the pattern and catch-all listeners of the event, e.mu must be held.
+func (e *emmiter) matchAnyListeners(evt EventName) (listeners []*anyListener) {
+	for _, l := range e.anyListeners {
+		if l.pattern == nil || l.pattern.MatchString(string(evt)) {
+			listeners = append(listeners, l)
+		}
+	}
+	return listeners
+}
@@ -0,0 +1,180 @@
+package events
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/zishang520/engine.io/log"
+)
+
+var events_log = log.NewLog("engine:events")
+
+// PanicError reports a panic recovered from a listener.
+type PanicError struct {
+	Event EventName
+	Value any    // the value passed to panic
+	Stack []byte // the stack of the panicking listener
+}
+
+func (p *PanicError) Error() string {
+	return fmt.Sprintf(`listener of "%s" panicked: %v`, p.Event, p.Value)
+}
+
+// PanicHandler is called with the panics recovered from the listeners of an emitter.
+type PanicHandler func(*PanicError)
+
+type emission struct {
+	evt  EventName
+	data []any
+}
+
+// The queue of an emitter in async mode, drained in order by a single goroutine
+// which only runs while the queue is not empty.
+type dispatcher struct {
+	size    int
+	events  map[EventName]bool // nil for every event
+	queue   []emission
+	running bool
+	closing bool // the emitter goes back to sync once the queue is drained
+
+	mu   sync.Mutex
+	cond *sync.Cond
+}
+
+// Calls a listener, a panic is recovered and reported.
+func (e *emmiter) call(evt EventName, l Listener, data []any) {
+	defer func() {
+		if r := recover(); r != nil {
+			e.onPanic(&PanicError{Event: evt, Value: r, Stack: debug.Stack()})
+		}
+	}()
+	l(data...)
+}
+
+// Reports a listener panic to the panic handler, or else to the "error"
+// listeners, or else to the log.
+func (e *emmiter) onPanic(err *PanicError) {
+	e.mu.RLock()
+	handler := e.panicHandler
+	e.mu.RUnlock()
+
+	if handler != nil {
+		handler(err)
+	} else if err.Event != "error" && e.ListenerCount("error") > 0 {
+		e.emit("error", []any{err})
+	} else {
+		events_log.Error("%v\n%s", err, err.Stack)
+	}
+}
+
+func (e *emmiter) SetPanicHandler(handler PanicHandler) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.panicHandler = handler
+}
+
+func (e *emmiter) SetAsync(queueSize int, evts ...EventName) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var events map[EventName]bool
+	if len(evts) > 0 {
+		events = map[EventName]bool{}
+		for _, evt := range evts {
+			events[evt] = true
+		}
+	}
+
+	d := e.dispatcher
+	if d == nil {
+		if queueSize > 0 {
+			d = &dispatcher{size: queueSize, events: events}
+			d.cond = sync.NewCond(&d.mu)
+			e.dispatcher = d
+		}
+		return
+	}
+
+	// the pending emissions keep their order with the following ones, which go
+	// through the same queue
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if queueSize <= 0 {
+		if len(d.queue) == 0 && !d.running {
+			e.dispatcher = nil
+			return
+		}
+		// routed through the queue until it is drained
+		d.size, d.closing = 0, true
+	} else {
+		d.size, d.events, d.closing = queueSize, events, false
+	}
+	d.cond.Broadcast()
+}
+
+// Queues the emission when the event is dispatched asynchronously, it waits
+// while the queue is full.
+func (e *emmiter) enqueue(evt EventName, data []any) bool {
+	e.mu.RLock()
+	d := e.dispatcher
+	e.mu.RUnlock()
+
+	if d == nil {
+		return false
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.events != nil && !d.events[evt] {
+		return false
+	}
+	for d.size > 0 && len(d.queue) >= d.size {
+		d.cond.Wait()
+	}
+	d.queue = append(d.queue, emission{evt: evt, data: data})
+	if !d.running {
+		d.running = true
+		go e.drain(d)
+	}
+	return true
+}
+
+func (e *emmiter) drain(d *dispatcher) {
+	for {
+		d.mu.Lock()
+		if len(d.queue) == 0 {
+			d.running = false
+			closing := d.closing
+			d.mu.Unlock()
+			if closing {
+				e.detach(d)
+			}
+			return
+		}
+		next := d.queue[0]
+		d.queue[0] = emission{}
+		d.queue = d.queue[1:]
+		d.cond.Broadcast()
+		d.mu.Unlock()
+
+		e.emit(next.evt, next.data)
+	}
+}
+
+// Restores the synchronous dispatch once the queue of a closing dispatcher is
+// drained.
+func (e *emmiter) detach(d *dispatcher) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if e.dispatcher == d && d.closing && len(d.queue) == 0 && !d.running {
+		e.dispatcher = nil
+	}
+}
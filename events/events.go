@@ -6,8 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"runtime"
+	"strings"
 	"sync"
 	"sync/atomic"
+	"unsafe"
 )
 
 const (
@@ -30,7 +33,7 @@ type (
 
 	listener struct {
 		listener Listener
-		ptr      uintptr
+		id       uintptr
 	}
 
 	events map[EventName][]*listener
@@ -64,6 +67,9 @@ type (
 		// Returns an indicator if event and listeners were found before the remove.
 		RemoveAllListeners(EventName) bool
 		// RemoveListener removes given listener from the event named eventName.
+		// The listener is matched by the closure it was registered with: a copy of
+		// that func value removes it, another closure made by the same code does
+		// not. Method values are matched by method, whatever their receiver.
 		// Returns an indicator whether listener was removed
 		RemoveListener(EventName, Listener) bool
 		// Clear removes all events and all listeners, restores Events to an empty value
@@ -73,11 +79,38 @@ type (
 		SetMaxListeners(uint)
 		// Len returns the length of all registered events
 		Len() int
+		// PrependListener adds listeners to the beginning of the listeners array for the event named eventName.
+		PrependListener(EventName, ...Listener) error
+		// PrependOnceListener adds one time listeners to the beginning of the listeners array for the event named eventName.
+		PrependOnceListener(EventName, ...Listener) error
+		// OnAny adds a listener invoked for every event, after the listeners of the event.
+		OnAny(AnyListener)
+		// OffAny removes a listener added by OnAny or OnPattern, or all of them when nil.
+		// The listener is matched as by RemoveListener.
+		// Returns an indicator whether a listener was removed
+		OffAny(AnyListener) bool
+		// OnPattern adds a listener invoked for the events matching the pattern, in
+		// which `*` matches any sequence of characters, after the listeners of the event.
+		OnPattern(string, AnyListener)
+		// SetPanicHandler sets the handler of the panics recovered from the listeners,
+		// without handler a panic is emitted as a *PanicError to the "error" listeners,
+		// if any, or else logged.
+		SetPanicHandler(PanicHandler)
+		// SetAsync dispatches the given events, or every event when none is given,
+		// in order on a separate goroutine so that Emit does not wait for the
+		// listeners. Emit waits while queueSize emissions are pending, a listener
+		// must therefore not emit the async events of its own emitter. A queueSize
+		// of zero restores the synchronous dispatch once the pending emissions are
+		// delivered, the emissions until then are queued after them.
+		SetAsync(queueSize int, evts ...EventName)
 	}
 
 	emmiter struct {
 		maxListeners uint
 		evtListeners events
+		anyListeners []*anyListener
+		panicHandler PanicHandler
+		dispatcher   *dispatcher
 		mu           sync.RWMutex
 	}
 )
@@ -100,6 +133,10 @@ func New() EventEmitter {
 }
 
 func (e *emmiter) addlistener(evt EventName, listeners ...*listener) error {
+	return e.insertlistener(evt, false, listeners...)
+}
+
+func (e *emmiter) insertlistener(evt EventName, prepend bool, listeners ...*listener) error {
 	if len(listeners) == 0 {
 		return nil
 	}
@@ -123,7 +160,11 @@ func (e *emmiter) addlistener(evt EventName, listeners ...*listener) error {
 		evts = make([]*listener, 0, e.maxListeners)
 	}
 
-	e.evtListeners[evt] = append(evts, listeners...)
+	if prepend {
+		e.evtListeners[evt] = append(append(make([]*listener, 0, len(evts)+len(listeners)), listeners...), evts...)
+	} else {
+		e.evtListeners[evt] = append(evts, listeners...)
+	}
 	return nil
 }
 
@@ -133,27 +174,38 @@ func (e *emmiter) AddListener(evt EventName, listeners ...Listener) error {
 	}
 	var events []*listener
 	for _, event := range listeners {
-		events = append(events, &listener{listener: event, ptr: reflect.ValueOf(event).Pointer()})
+		events = append(events, &listener{listener: event, id: identity(event)})
 	}
 	return e.addlistener(evt, events...)
 }
 
 func (e *emmiter) Emit(evt EventName, data ...any) {
+	if !e.enqueue(evt, data) {
+		e.emit(evt, data)
+	}
+}
+
+func (e *emmiter) emit(evt EventName, data []any) {
 	e.mu.RLock()
-	if e.evtListeners == nil {
+	if e.evtListeners == nil && len(e.anyListeners) == 0 {
 		defer e.mu.RUnlock() // RUnlock
 		return               // has no listeners to emit/speak yet
 	}
 	listeners := append([]*listener{}, e.evtListeners[evt]...)
+	anyListeners := e.matchAnyListeners(evt)
 	e.mu.RUnlock()
 
 	if len(listeners) > 0 {
 		for _, event := range listeners {
 			if event != nil {
-				event.listener(data...)
+				e.call(evt, event.listener, data)
 			}
 		}
 	}
+	// the pattern and catch-all listeners are invoked after the specific ones
+	for _, l := range anyListeners {
+		e.call(evt, func(data ...any) { l.listener(evt, data...) }, data)
+	}
 }
 
 func (e *emmiter) EventNames() (names []EventName) {
@@ -222,11 +274,30 @@ type oneTimelistener struct {
 func (l *oneTimelistener) execute(vals ...any) {
 	if atomic.CompareAndSwapInt32(&l.fired, 0, 1) {
 		defer l.emitter.RemoveListener(l.evt, l.listener)
-		l.listener(vals)
+		l.listener(vals...)
 	}
 }
 
 func (e *emmiter) Once(evt EventName, listeners ...Listener) error {
+	return e.addOnceListener(evt, false, listeners...)
+}
+
+func (e *emmiter) PrependListener(evt EventName, listeners ...Listener) error {
+	if len(listeners) == 0 {
+		return nil
+	}
+	var events []*listener
+	for _, event := range listeners {
+		events = append(events, &listener{listener: event, id: identity(event)})
+	}
+	return e.insertlistener(evt, true, events...)
+}
+
+func (e *emmiter) PrependOnceListener(evt EventName, listeners ...Listener) error {
+	return e.addOnceListener(evt, true, listeners...)
+}
+
+func (e *emmiter) addOnceListener(evt EventName, prepend bool, listeners ...Listener) error {
 	if len(listeners) == 0 {
 		return nil
 	}
@@ -235,9 +306,9 @@ func (e *emmiter) Once(evt EventName, listeners ...Listener) error {
 	for _, event := range listeners {
 		oneTime := &oneTimelistener{evt: evt, emitter: e, listener: event}
 		oneTime.executeRef = oneTime.execute
-		events = append(events, &listener{listener: oneTime.executeRef, ptr: reflect.ValueOf(event).Pointer()})
+		events = append(events, &listener{listener: oneTime.executeRef, id: identity(event)})
 	}
-	return e.addlistener(evt, events...)
+	return e.insertlistener(evt, prepend, events...)
 }
 
 func (e *emmiter) RemoveAllListeners(evt EventName) bool {
@@ -256,7 +327,20 @@ func (e *emmiter) RemoveAllListeners(evt EventName) bool {
 	return false
 }
 
-// RemoveListener removes the specified listener from the listener array for the event named eventName.
+// Returns the identity of a listener, by which it is removed: the closure its
+// value points to, shared by the copies of the value but not by the closures
+// made by the same code, such as the wrappers of On1. A method value is a new
+// closure each time it is evaluated, it is therefore identified by its code.
+func identity[F Listener | AnyListener](fn F) uintptr {
+	ptr := reflect.ValueOf(fn).Pointer()
+	if f := runtime.FuncForPC(ptr); f != nil && strings.HasSuffix(f.Name(), "-fm") {
+		return ptr
+	}
+	return *(*uintptr)(unsafe.Pointer(&fn))
+}
+
+// RemoveListener removes the specified listener from the listener array for the event named eventName,
+// matching it by identity.
 func (e *emmiter) RemoveListener(evt EventName, listener Listener) bool {
 	if listener == nil {
 		return false
@@ -274,10 +358,10 @@ func (e *emmiter) RemoveListener(evt EventName, listener Listener) bool {
 	}
 
 	idx := -1
-	listenerPointer := reflect.ValueOf(listener).Pointer()
+	id := identity(listener)
 
 	for index, event := range listeners {
-		if event.ptr == listenerPointer {
+		if event.id == id {
 			idx = index
 			break
 		}
@@ -297,6 +381,7 @@ func (e *emmiter) Clear() {
 	defer e.mu.Unlock()
 
 	e.evtListeners = events{}
+	e.anyListeners = nil
 }
 
 func (e *emmiter) SetMaxListeners(n uint) {
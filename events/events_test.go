@@ -2,6 +2,7 @@ package events
 
 import (
 	"fmt"
+	"sync"
 	"testing"
 	"time"
 )
@@ -179,3 +180,285 @@ func TestRemoveListener(t *testing.T) {
 
 	e.Emit("my_event")
 }
+
+func TestEventsOnceArguments(t *testing.T) {
+	e := New()
+	var got []any
+	e.Once("my_event", func(payload ...any) {
+		got = payload
+	})
+	e.Emit("my_event", "a", 1)
+
+	if len(got) != 2 || got[0] != "a" || got[1] != 1 {
+		t.Fatalf("Once's listener received %v, while expecting: %v", got, []any{"a", 1})
+	}
+}
+
+func TestTypedListeners(t *testing.T) {
+	e := New()
+
+	t.Run("On2", func(t *testing.T) {
+		var name string
+		var count int
+		listener := On2(e, "typed", func(n string, c int) {
+			name, count = n, c
+		})
+		e.Emit("typed", "a", 2)
+		if name != "a" || count != 2 {
+			t.Fatalf("On2's listener received (%q, %d), while expecting: (%q, %d)", name, count, "a", 2)
+		}
+
+		e.Emit("typed", 3)
+		if name != "" || count != 0 {
+			t.Fatalf("On2's listener received (%q, %d), while expecting the zero values", name, count)
+		}
+
+		if !e.RemoveListener("typed", listener) || e.ListenerCount("typed") != 0 {
+			t.Fatal("On2's listener should be removable")
+		}
+	})
+
+	t.Run("RemoveListener", func(t *testing.T) {
+		var got []string
+		a := On1(e, "typed", func(s string) { got = append(got, "A "+s) })
+		b := On1(e, "typed", func(s string) { got = append(got, "B "+s) })
+
+		if !e.RemoveListener("typed", b) || e.RemoveListener("typed", b) {
+			t.Fatal("RemoveListener should remove the second listener once")
+		}
+		e.Emit("typed", "x")
+		if fmt.Sprint(got) != "[A x]" {
+			t.Fatalf("listeners were called as %v, while expecting: %v", got, "[A x]")
+		}
+
+		once := Once1(e, "typed", func(s string) { got = append(got, "once "+s) })
+		if !e.RemoveListener("typed", once) || !e.RemoveListener("typed", a) || e.ListenerCount("typed") != 0 {
+			t.Fatal("RemoveListener should remove each typed listener")
+		}
+	})
+
+	t.Run("Once1", func(t *testing.T) {
+		count := 0
+		Once1(e, "typed", func(err error) {
+			count++
+		})
+		e.Emit("typed", nil)
+		e.Emit("typed", nil)
+		if count != 1 {
+			t.Fatalf("Once1's listener fired %d times, while expecting: %d", count, 1)
+		}
+	})
+}
+
+func TestPanicRecovery(t *testing.T) {
+	t.Run("error listener", func(t *testing.T) {
+		e := New()
+		var recovered *PanicError
+		e.On("error", func(args ...any) {
+			recovered, _ = args[0].(*PanicError)
+		})
+		e.On("message", func(...any) {
+			panic("boom")
+		})
+		called := false
+		e.On("message", func(...any) {
+			called = true
+		})
+		e.Emit("message")
+
+		if recovered == nil || recovered.Event != "message" || recovered.Value != "boom" || len(recovered.Stack) == 0 {
+			t.Fatalf("error listener received %+v, while expecting the recovered panic", recovered)
+		}
+		if !called {
+			t.Fatal("the listeners following a panicking one should be called")
+		}
+	})
+
+	t.Run("handler", func(t *testing.T) {
+		e := New()
+		var recovered *PanicError
+		e.SetPanicHandler(func(err *PanicError) {
+			recovered = err
+		})
+		e.On("error", func(...any) {
+			panic("error listener")
+		})
+		e.Emit("error")
+
+		if recovered == nil || recovered.Value != "error listener" {
+			t.Fatalf("panic handler received %+v, while expecting the recovered panic", recovered)
+		}
+	})
+}
+
+func TestAsync(t *testing.T) {
+	e := New()
+	e.SetAsync(2, "message")
+
+	release := make(chan struct{})
+	received := make(chan int, 10)
+	e.On("message", func(args ...any) {
+		<-release
+		received <- args[0].(int)
+	})
+	syncCalled := false
+	e.On("sync", func(...any) {
+		syncCalled = true
+	})
+
+	for i := 0; i < 3; i++ {
+		e.Emit("message", i)
+	}
+	e.Emit("sync")
+	if !syncCalled {
+		t.Fatal("events which are not async should be dispatched synchronously")
+	}
+
+	close(release)
+	for i := 0; i < 3; i++ {
+		select {
+		case n := <-received:
+			if n != i {
+				t.Fatalf("async listener received %d, while expecting: %d", n, i)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("async listener was not called")
+		}
+	}
+}
+
+func TestSetSync(t *testing.T) {
+	e := New()
+	e.SetAsync(10, "message")
+
+	release := make(chan struct{})
+	var received []int
+	var mu sync.Mutex
+	e.On("message", func(args ...any) {
+		<-release
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, args[0].(int))
+	})
+	last := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(received) == 0 {
+			return -1
+		}
+		return received[len(received)-1]
+	}
+	for i := 0; i < 3; i++ {
+		e.Emit("message", i)
+	}
+
+	// the emissions following SetAsync(0) wait for the queued ones
+	e.SetAsync(0)
+	close(release)
+	e.Emit("message", 3)
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		if e.Emit("message", 4); last() == 4 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the emitter did not go back to the synchronous dispatch")
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) < 4 || fmt.Sprint(received[:4]) != "[0 1 2 3]" {
+		t.Fatalf("listener received %v, while expecting the order of the emissions", received)
+	}
+}
+
+func TestAnyListeners(t *testing.T) {
+	t.Run("OnAny", func(t *testing.T) {
+		e := New()
+		var order []string
+		e.On("upgrade", func(...any) {
+			order = append(order, "specific")
+		})
+		listener := AnyListener(func(evt EventName, args ...any) {
+			order = append(order, fmt.Sprintf("any:%s:%v", evt, args))
+		})
+		e.OnAny(listener)
+		e.Emit("upgrade", 1)
+		e.Emit("close")
+
+		if fmt.Sprint(order) != "[specific any:upgrade:[1] any:close:[]]" {
+			t.Fatalf("listeners were called as %v, while expecting the catch-all listener after the specific one", order)
+		}
+
+		if !e.OffAny(listener) || e.OffAny(listener) {
+			t.Fatal("OffAny should remove the listener once")
+		}
+		e.Emit("close")
+		if len(order) != 3 {
+			t.Fatalf("a removed catch-all listener was called: %v", order)
+		}
+
+		var names []string
+		for _, name := range []string{"first", "second"} {
+			name := name
+			e.OnAny(func(EventName, ...any) { names = append(names, name) })
+		}
+		e.OnAny(listener)
+		if !e.OffAny(listener) {
+			t.Fatal("OffAny should remove the listener among the closures made by the same code")
+		}
+		e.Emit("close")
+		if fmt.Sprint(names) != "[first second]" || len(order) != 3 {
+			t.Fatalf("listeners were called as %v %v, while expecting only: %v", names, order[3:], "[first second]")
+		}
+	})
+
+	t.Run("OnPattern", func(t *testing.T) {
+		e := New()
+		var got []EventName
+		e.OnPattern("upgrad*", func(evt EventName, _ ...any) {
+			got = append(got, evt)
+		})
+		for _, evt := range []EventName{"upgrading", "upgrade", "upgrade_error", "open", "pre_upgrade"} {
+			e.Emit(evt)
+		}
+
+		if fmt.Sprint(got) != "[upgrading upgrade upgrade_error]" {
+			t.Fatalf("pattern listener received %v, while expecting: %v", got, "[upgrading upgrade upgrade_error]")
+		}
+
+		if !e.OffAny(nil) {
+			t.Fatal("OffAny(nil) should remove every catch-all listener")
+		}
+	})
+
+	t.Run("Prepend", func(t *testing.T) {
+		e := New()
+		var order []int
+		e.On("message", func(...any) { order = append(order, 2) })
+		e.PrependListener("message", func(...any) { order = append(order, 1) })
+		e.PrependOnceListener("message", func(...any) { order = append(order, 0) })
+		e.Emit("message")
+		e.Emit("message")
+
+		if fmt.Sprint(order) != "[0 1 2 1 2]" {
+			t.Fatalf("listeners were called as %v, while expecting: %v", order, "[0 1 2 1 2]")
+		}
+	})
+}
+
+type counter struct{ count int }
+
+func (c *counter) increment(...any) { c.count++ }
+
+func TestRemoveMethodListener(t *testing.T) {
+	e := New()
+	c := &counter{}
+	e.On("my_event", c.increment)
+	if !e.RemoveListener("my_event", c.increment) {
+		t.Fatal("a method value should be removable by a new evaluation of it")
+	}
+	e.Emit("my_event")
+	if c.count != 0 {
+		t.Fatalf("a removed method value was called %d times", c.count)
+	}
+}
@@ -0,0 +1,55 @@
+package events
+
+// Returns the i-th argument of a listener as a T, or the zero value of T when
+// the argument is missing or of another type.
+func Arg[T any](args []any, i int) (value T) {
+	if i < len(args) {
+		value, _ = args[i].(T)
+	}
+	return value
+}
+
+// Registers a listener for an event without arguments, the returned Listener
+// can be passed to RemoveListener.
+func On0(emitter EventEmitter, evt EventName, fn func()) Listener {
+	listener := Listener(func(...any) { fn() })
+	emitter.On(evt, listener)
+	return listener
+}
+
+// Registers a listener for an event with one argument of type A, the returned
+// Listener can be passed to RemoveListener.
+func On1[A any](emitter EventEmitter, evt EventName, fn func(A)) Listener {
+	listener := Listener(func(args ...any) { fn(Arg[A](args, 0)) })
+	emitter.On(evt, listener)
+	return listener
+}
+
+// Registers a listener for an event with two arguments of type A and B, the
+// returned Listener can be passed to RemoveListener.
+func On2[A any, B any](emitter EventEmitter, evt EventName, fn func(A, B)) Listener {
+	listener := Listener(func(args ...any) { fn(Arg[A](args, 0), Arg[B](args, 1)) })
+	emitter.On(evt, listener)
+	return listener
+}
+
+// Registers a one time listener for an event without arguments.
+func Once0(emitter EventEmitter, evt EventName, fn func()) Listener {
+	listener := Listener(func(...any) { fn() })
+	emitter.Once(evt, listener)
+	return listener
+}
+
+// Registers a one time listener for an event with one argument of type A.
+func Once1[A any](emitter EventEmitter, evt EventName, fn func(A)) Listener {
+	listener := Listener(func(args ...any) { fn(Arg[A](args, 0)) })
+	emitter.Once(evt, listener)
+	return listener
+}
+
+// Registers a one time listener for an event with two arguments of type A and B.
+func Once2[A any, B any](emitter EventEmitter, evt EventName, fn func(A, B)) Listener {
+	listener := Listener(func(args ...any) { fn(Arg[A](args, 0), Arg[B](args, 1)) })
+	emitter.Once(evt, listener)
+	return listener
+}
@@ -0,0 +1,344 @@
+// Package eioclient holds the client side of the protocol shared by the
+// command line tools and the replayer: the requests of a session, its
+// handshake, the probe of an upgrade and the WebSocket frames.
+//
+// A Session starts no goroutine, its users drive the transports and decide how
+// the polling requests and the frames are serialized.
+package eioclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/parser"
+	"github.com/zishang520/engine.io/types"
+)
+
+// A request rejected by the server.
+type ServerError struct {
+	Status  int
+	Code    int // the Engine.IO error code, -1 when the body holds none
+	Message string
+}
+
+func (e *ServerError) Error() string {
+	if e.Code < 0 {
+		return fmt.Sprintf("HTTP %d", e.Status)
+	}
+	return fmt.Sprintf("HTTP %d, code %d %q", e.Status, e.Code, e.Message)
+}
+
+func rejected(status int, body []byte) error {
+	err := &ServerError{Status: status, Code: -1}
+	var message types.CodeMessage
+	if json.Unmarshal(body, &message) == nil {
+		err.Code, err.Message = message.Code, message.Message
+	}
+	return err
+}
+
+// A packet breaking the protocol.
+type ProtocolError string
+
+func (e ProtocolError) Error() string {
+	return "protocol: " + string(e)
+}
+
+// The configuration of a session.
+type Config struct {
+	URL      string      // the Engine.IO URL, such as http://localhost:3000/engine.io/
+	Protocol int         // the protocol revision, 3 or 4
+	Base64   bool        // whether the binary packets are sent as base64
+	Query    url.Values  // appended to every request
+	Header   http.Header // sent with every request
+	Timeout  time.Duration
+	HTTP     *http.Client // http.DefaultClient when nil
+
+	// Called with every packet sent (">") or received ("<") over transport,
+	// such as to print it.
+	OnPacket func(transport, direction string, p *packet.Packet)
+	// Called with every polling response.
+	OnResponse func(method string, res *http.Response, size int, elapsed time.Duration)
+}
+
+// Session is an Engine.IO session seen by a client.
+type Session struct {
+	cfg    *Config
+	parser parser.Parser
+	http   *http.Client
+
+	// The fields of the open packet, set by Open.
+	Sid          string
+	PingInterval time.Duration
+	PingTimeout  time.Duration
+}
+
+func NewSession(cfg *Config) *Session {
+	p := parser.Parserv4()
+	if cfg.Protocol == 3 {
+		p = parser.Parserv3()
+	}
+	client := cfg.HTTP
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Session{cfg: cfg, parser: p, http: client}
+}
+
+func (s *Session) Parser() parser.Parser {
+	return s.parser
+}
+
+// Returns the URL of a request of transport.
+func (s *Session) URL(transport string) (string, error) {
+	u, err := url.Parse(s.cfg.URL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	for k, v := range s.cfg.Query {
+		q[k] = v
+	}
+	q.Set("EIO", strconv.Itoa(s.cfg.Protocol))
+	q.Set("transport", transport)
+	if s.cfg.Base64 && !q.Has("b64") {
+		q.Set("b64", "1")
+	}
+	if s.Sid != "" {
+		q.Set("sid", s.Sid)
+	}
+	u.RawQuery = q.Encode()
+	if transport == "websocket" {
+		u.Scheme = strings.Replace(u.Scheme, "http", "ws", 1)
+	}
+	return u.String(), nil
+}
+
+// Opens the session over transport, the WebSocket is returned when transport
+// is "websocket", the packets sent along with the open packet otherwise.
+func (s *Session) Open(ctx context.Context, transport string) (*websocket.Conn, []*packet.Packet, error) {
+	if transport == "websocket" {
+		conn, err := s.Dial(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(s.cfg.Timeout))
+		p, err := s.ReadFrame(conn)
+		if err == nil {
+			err = s.open(p)
+		}
+		if err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+		conn.SetReadDeadline(time.Time{})
+		return conn, nil, nil
+	}
+
+	packets, err := s.Request(ctx, http.MethodGet, nil, s.cfg.Timeout)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(packets) == 0 {
+		return nil, nil, ProtocolError("empty handshake")
+	}
+	if err := s.open(packets[0]); err != nil {
+		return nil, nil, err
+	}
+	return nil, packets[1:], nil
+}
+
+// Reads the fields of the open packet, its data is put back for the next
+// reader.
+func (s *Session) open(p *packet.Packet) error {
+	if p.Type != packet.OPEN || p.Data == nil {
+		return ProtocolError("the handshake did not start with an open packet")
+	}
+	data, err := io.ReadAll(p.Data)
+	if err != nil {
+		return err
+	}
+	p.Data = types.NewStringBuffer(data)
+	var handshake struct {
+		Sid          string `json:"sid"`
+		PingInterval int64  `json:"pingInterval"`
+		PingTimeout  int64  `json:"pingTimeout"`
+	}
+	if err := json.Unmarshal(data, &handshake); err != nil || handshake.Sid == "" {
+		return ProtocolError("the open packet holds no session")
+	}
+	s.Sid = handshake.Sid
+	s.PingInterval = time.Duration(handshake.PingInterval) * time.Millisecond
+	s.PingTimeout = time.Duration(handshake.PingTimeout) * time.Millisecond
+	if s.PingInterval <= 0 {
+		return ProtocolError("the open packet holds no ping interval")
+	}
+	return nil
+}
+
+// Sends a polling request and returns the packets of its response.
+func (s *Session) Request(ctx context.Context, method string, payload types.BufferInterface, timeout time.Duration) ([]*packet.Packet, error) {
+	rawURL, err := s.URL("polling")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload.Bytes())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range s.cfg.Header {
+		req.Header[k] = v
+	}
+	if _, ok := payload.(*types.BytesBuffer); ok {
+		req.Header.Set("Content-Type", "application/octet-stream")
+	} else if payload != nil {
+		req.Header.Set("Content-Type", "text/plain;charset=UTF-8")
+	}
+	start := time.Now()
+	res, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if s.cfg.OnResponse != nil {
+		s.cfg.OnResponse(method, res, len(data), time.Since(start))
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, rejected(res.StatusCode, data)
+	}
+	if method != http.MethodGet {
+		return nil, nil
+	}
+	var packets []*packet.Packet
+	if strings.HasPrefix(res.Header.Get("Content-Type"), "application/octet-stream") {
+		packets = s.parser.DecodePayload(types.NewBytesBuffer(data))
+	} else {
+		packets = s.parser.DecodePayload(types.NewStringBuffer(data))
+	}
+	if s.cfg.OnPacket != nil {
+		for _, p := range packets {
+			s.cfg.OnPacket("polling", "<", p)
+		}
+	}
+	return packets, nil
+}
+
+// Waits for the packets of the server, a poll lasts up to a heartbeat.
+func (s *Session) Poll(ctx context.Context) ([]*packet.Packet, error) {
+	return s.Request(ctx, http.MethodGet, nil, s.PingInterval+s.PingTimeout+s.cfg.Timeout)
+}
+
+// Sends the packets in a single polling request.
+func (s *Session) Post(ctx context.Context, packets []*packet.Packet) error {
+	if s.cfg.OnPacket != nil {
+		for _, p := range packets {
+			s.cfg.OnPacket("polling", ">", p)
+		}
+	}
+	payload, err := s.parser.EncodePayload(packets, !s.cfg.Base64)
+	if err != nil {
+		return err
+	}
+	_, err = s.Request(ctx, http.MethodPost, payload, s.cfg.Timeout)
+	return err
+}
+
+// Opens a WebSocket of the session, or a new session before Open.
+func (s *Session) Dial(ctx context.Context) (*websocket.Conn, error) {
+	rawURL, err := s.URL("websocket")
+	if err != nil {
+		return nil, err
+	}
+	dialer := &websocket.Dialer{Proxy: http.ProxyFromEnvironment, HandshakeTimeout: s.cfg.Timeout}
+	conn, res, err := dialer.DialContext(ctx, rawURL, s.cfg.Header)
+	if err != nil && res != nil {
+		// the handshake was rejected
+		defer res.Body.Close()
+		data, _ := io.ReadAll(res.Body)
+		return nil, rejected(res.StatusCode, data)
+	}
+	return conn, err
+}
+
+// Opens a WebSocket of the polling session and probes it, the upgrade packet
+// is left to the caller so that nothing is posted once it is written. The
+// server releases the pending poll once the probe is answered.
+func (s *Session) Probe(ctx context.Context) (*websocket.Conn, error) {
+	conn, err := s.Dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Now().Add(s.cfg.Timeout))
+	if err = s.WriteFrame(conn, &packet.Packet{Type: packet.PING, Data: types.NewStringBufferString("probe")}); err == nil {
+		var p *packet.Packet
+		if p, err = s.ReadFrame(conn); err == nil {
+			var data []byte
+			if p.Data != nil {
+				data, _ = io.ReadAll(p.Data)
+			}
+			if p.Type != packet.PONG || string(data) != "probe" {
+				err = ProtocolError("the probe was not answered")
+			}
+		}
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetReadDeadline(time.Time{})
+	return conn, nil
+}
+
+func (s *Session) WriteFrame(conn *websocket.Conn, p *packet.Packet) error {
+	if s.cfg.OnPacket != nil {
+		s.cfg.OnPacket("websocket", ">", p)
+	}
+	data, err := s.parser.EncodePacket(p, !s.cfg.Base64)
+	if err != nil {
+		return err
+	}
+	messageType := websocket.TextMessage
+	if _, ok := data.(*types.BytesBuffer); ok {
+		messageType = websocket.BinaryMessage
+	}
+	conn.SetWriteDeadline(time.Now().Add(s.cfg.Timeout))
+	return conn.WriteMessage(messageType, data.Bytes())
+}
+
+func (s *Session) ReadFrame(conn *websocket.Conn) (*packet.Packet, error) {
+	messageType, frame, err := conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var p *packet.Packet
+	if messageType == websocket.BinaryMessage {
+		p, err = s.parser.DecodePacket(types.NewBytesBuffer(frame))
+	} else {
+		p, err = s.parser.DecodePacket(types.NewStringBuffer(frame))
+	}
+	if err == nil && s.cfg.OnPacket != nil {
+		s.cfg.OnPacket("websocket", "<", p)
+	}
+	return p, err
+}
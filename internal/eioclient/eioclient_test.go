@@ -0,0 +1,118 @@
+package eioclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/conformance"
+	"github.com/zishang520/engine.io/engine"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// Starts a server sending back the messages.
+func start(t *testing.T, opts *config.ServerOptions) string {
+	server := conformance.EngineServer(opts)
+	srv := httptest.NewServer(server)
+	t.Cleanup(func() {
+		server.(engine.Server).Close()
+		srv.Close()
+	})
+	return srv.URL + "/engine.io/"
+}
+
+func message(data string) *packet.Packet {
+	return &packet.Packet{Type: packet.MESSAGE, Data: types.NewStringBufferString(data)}
+}
+
+func TestSession(t *testing.T) {
+	url := start(t, config.DefaultServerOptions())
+
+	t.Run("Polling", func(t *testing.T) {
+		var traced []string
+		s := NewSession(&Config{URL: url, Protocol: 4, Timeout: 5 * time.Second, OnPacket: func(transport, direction string, p *packet.Packet) {
+			traced = append(traced, fmt.Sprint(transport, direction, p.Type))
+		}})
+		if _, _, err := s.Open(context.Background(), "polling"); err != nil || s.Sid == "" || s.PingInterval <= 0 {
+			t.Fatalf("Open() = %v, want match for a session", err)
+		}
+		if err := s.Post(context.Background(), []*packet.Packet{message("hello")}); err != nil {
+			t.Fatalf("Post() = %v, want match for nil", err)
+		}
+		packets, err := s.Poll(context.Background())
+		if err != nil || len(packets) != 1 || packets[0].Type != packet.MESSAGE {
+			t.Fatalf("Poll() = %v, %v, want match for the message sent back", packets, err)
+		}
+		if want := "[polling<open polling>message polling<message]"; fmt.Sprint(traced) != want {
+			t.Fatalf("OnPacket() was called with %v, want match for %s", traced, want)
+		}
+	})
+
+	t.Run("Upgrade", func(t *testing.T) {
+		s := NewSession(&Config{URL: url, Protocol: 4, Timeout: 5 * time.Second})
+		if _, _, err := s.Open(context.Background(), "polling"); err != nil {
+			t.Fatal(err)
+		}
+		released := make(chan error, 1)
+		go func() {
+			_, err := s.Poll(context.Background())
+			released <- err
+		}()
+
+		conn, err := s.Probe(context.Background())
+		if err != nil {
+			t.Fatalf("Probe() = %v, want match for nil", err)
+		}
+		defer conn.Close()
+		if err := <-released; err != nil {
+			t.Fatalf("Poll() = %v while probing, want match for nil", err)
+		}
+		if err := s.WriteFrame(conn, &packet.Packet{Type: packet.UPGRADE}); err != nil {
+			t.Fatal(err)
+		}
+		s.WriteFrame(conn, message("hello"))
+		if p, err := s.ReadFrame(conn); err != nil || p.Type != packet.MESSAGE {
+			t.Fatalf("ReadFrame() = %v, %v, want match for the message sent back", p, err)
+		}
+	})
+
+	t.Run("WebSocket", func(t *testing.T) {
+		s := NewSession(&Config{URL: url, Protocol: 4, Timeout: 5 * time.Second})
+		conn, packets, err := s.Open(context.Background(), "websocket")
+		if err != nil || conn == nil || packets != nil || s.Sid == "" {
+			t.Fatalf("Open() = %v, %v, %v, want match for a WebSocket", conn, packets, err)
+		}
+		conn.Close()
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		// v3 is disabled by default
+		s := NewSession(&Config{URL: url, Protocol: 3, Timeout: 5 * time.Second})
+		_, _, err := s.Open(context.Background(), "polling")
+		var rejected *ServerError
+		if !errors.As(err, &rejected) || rejected.Status != 400 || rejected.Code != 5 {
+			t.Fatalf("Open() = %v, want match for HTTP 400 with code 5", err)
+		}
+		if want := `HTTP 400, code 5 "Unsupported protocol version"`; err.Error() != want {
+			t.Fatalf("Open() = %q, want match for %q", err, want)
+		}
+	})
+}
+
+func TestURL(t *testing.T) {
+	s := NewSession(&Config{URL: "https://example.com/engine.io/?a=1", Protocol: 3, Base64: true, Query: map[string][]string{"b": {"2"}}})
+	s.Sid = "s1"
+	for transport, want := range map[string]string{
+		"polling":   "https://example.com/engine.io/?EIO=3&a=1&b=2&b64=1&sid=s1&transport=polling",
+		"websocket": "wss://example.com/engine.io/?EIO=3&a=1&b=2&b64=1&sid=s1&transport=websocket",
+	} {
+		if rawURL, err := s.URL(transport); err != nil || rawURL != want {
+			t.Fatalf("URL(%q) = %q, %v, want match for %q", transport, rawURL, err, want)
+		}
+	}
+}
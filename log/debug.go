@@ -0,0 +1,115 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// The debug namespaces, replaced as a whole so that every Log observes a change at once.
+type namespaces struct {
+	spec   string
+	names  []*regexp.Regexp
+	skips  []*regexp.Regexp
+	active bool
+}
+
+var debugNamespaces atomic.Value // *namespaces
+
+func init() {
+	debugNamespaces.Store(parseNamespaces(os.Getenv("DEBUG"), false))
+}
+
+// Parses a list of namespaces separated by commas or spaces, `*` matches any
+// sequence of characters and a leading `-` excludes the matching namespaces.
+func parseNamespaces(spec string, active bool) *namespaces {
+	n := &namespaces{spec: strings.TrimSpace(spec), active: active}
+	for _, pattern := range strings.FieldsFunc(n.spec, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' }) {
+		skip := strings.HasPrefix(pattern, "-")
+		pattern = strings.TrimPrefix(pattern, "-")
+		if pattern == "" {
+			continue
+		}
+		re := regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `.*`) + "$")
+		if skip {
+			n.skips = append(n.skips, re)
+		} else {
+			n.names = append(n.names, re)
+		}
+	}
+	return n
+}
+
+func (n *namespaces) match(namespace string) bool {
+	for _, re := range n.skips {
+		if re.MatchString(namespace) {
+			return false
+		}
+	}
+	for _, re := range n.names {
+		if re.MatchString(namespace) {
+			return true
+		}
+	}
+	return false
+}
+
+// Enables the debug output of the namespaces, such as "engine:*,-engine:polling",
+// replacing the previous ones for every Log at once.
+func Enable(spec string) {
+	debugNamespaces.Store(parseNamespaces(spec, true))
+}
+
+// Disables the debug output of every namespace and returns the namespaces that
+// were enabled, so that they can be restored with Enable.
+func Disable() string {
+	return debugNamespaces.Swap(parseNamespaces("", false)).(*namespaces).spec
+}
+
+// Returns the enabled debug namespaces, initially read from the `DEBUG`
+// environment variable.
+func Namespaces() string {
+	return debugNamespaces.Load().(*namespaces).spec
+}
+
+// Reports whether the debug output of the namespace is enabled, the namespaces
+// of the `DEBUG` environment variable only apply while the DEBUG variable of
+// this package is true or once Enable is called.
+func Enabled(namespace string) bool {
+	n := debugNamespaces.Load().(*namespaces)
+	return (n.active || DEBUG) && n.match(namespace)
+}
+
+// Toggles the debug output of the namespaces on every receipt of one of the
+// signals, such as syscall.SIGUSR1, until stop is called.
+func ToggleOnSignal(spec string, signals ...os.Signal) (stop func()) {
+	c := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(c, signals...)
+
+	go func() {
+		for {
+			select {
+			case <-c:
+				if n := debugNamespaces.Load().(*namespaces); n.active && n.spec == strings.TrimSpace(spec) {
+					Disable()
+				} else {
+					Enable(spec)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(c)
+			close(done)
+		})
+	}
+}
@@ -1,23 +1,49 @@
 package log
 
 import (
+	"fmt"
 	_log "log"
 	"os"
-	"regexp"
-	"strings"
 	"sync"
+	"time"
 
 	"github.com/gookit/color"
 )
 
+// Enables the debug output of the namespaces listed in the `DEBUG` environment variable.
+//
+// Deprecated: use Enable, which can be called at any time.
 var DEBUG bool = false
 
+var (
+	defaultLogger    Logger
+	mu_defaultLogger sync.RWMutex
+)
+
+// Sets the Logger used by every Log without its own, nil restores the colored
+// console output.
+func SetDefault(logger Logger) {
+	mu_defaultLogger.Lock()
+	defer mu_defaultLogger.Unlock()
+
+	defaultLogger = logger
+}
+
+// Returns the Logger set by SetDefault.
+func Default() Logger {
+	mu_defaultLogger.RLock()
+	defer mu_defaultLogger.RUnlock()
+
+	return defaultLogger
+}
+
 type Log struct {
 	*_log.Logger
 
-	mu              sync.RWMutex // ensures atomic writes; protects the following fields
-	prefix          string
-	namespaceRegexp *regexp.Regexp
+	mu     sync.RWMutex // ensures atomic writes; protects the following fields
+	prefix string
+	logger Logger
+	fields []any
 }
 
 func NewLog(prefix string) *Log {
@@ -29,69 +55,158 @@ func NewLog(prefix string) *Log {
 		l.SetPrefix(prefix)
 	}
 
-	if debug := os.Getenv("DEBUG"); debug != "" {
-		l.namespaceRegexp = regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(strings.TrimSpace(debug)), `\*`, `.*`) + "$")
+	return l
+}
+
+// Returns a copy of the log which adds the key/value pairs to its records, it
+// shares the console output of d.
+func (d *Log) With(keyvals ...any) *Log {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return &Log{
+		Logger: d.Logger,
+		prefix: d.prefix,
+		logger: d.logger,
+		fields: append(append(make([]any, 0, len(d.fields)+len(keyvals)), d.fields...), keyvals...),
 	}
+}
 
+// Returns a copy of the log sending its records to logger, d is returned as is
+// when logger is nil.
+func (d *Log) WithLogger(logger Logger) *Log {
+	if logger == nil {
+		return d
+	}
+	l := d.With()
+	l.logger = logger
 	return l
 }
 
-func (d *Log) checkNamespace(namespace string) bool {
-	if d.namespaceRegexp != nil {
-		return d.namespaceRegexp.MatchString(namespace)
+// Sets the Logger receiving the records, nil falls back to the default one.
+func (d *Log) SetLogger(logger Logger) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.logger = logger
+}
+
+// Returns the Logger receiving the records, nil when they are printed to the console.
+func (d *Log) GetLogger() Logger {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	if d.logger != nil {
+		return d.logger
+	}
+	return Default()
+}
+
+// Returns the key/value pairs added to the records.
+func (d *Log) Fields() []any {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.fields
+}
+
+// Forwards the message to the Logger, if any, and reports whether it did.
+func (d *Log) log(level Level, message string, args []any) bool {
+	logger := d.GetLogger()
+	if logger == nil {
+		return false
+	}
+	if namespace := d.Prefix(); logger.Enabled(namespace, level) {
+		logger.Log(&Record{
+			Time:      time.Now(),
+			Level:     level,
+			Namespace: namespace,
+			Message:   fmt.Sprintf(message, args...),
+			Fields:    d.Fields(),
+		})
 	}
-	return false
+	return true
 }
 
 // Console log Println.
 func (d *Log) Println(message string, args ...any) {
+	if d.log(LEVEL_INFO, message, args) {
+		return
+	}
 	d.Logger.Println(color.Sprintf(message, args...))
 }
 
 // Console log Default.
 func (d *Log) Default(message string, args ...any) {
+	if d.log(LEVEL_INFO, message, args) {
+		return
+	}
 	d.Logger.Println(color.Tag("default").Sprintf(message, args...))
 }
 
 // Console log Info.
 func (d *Log) Info(message string, args ...any) {
+	if d.log(LEVEL_INFO, message, args) {
+		return
+	}
 	d.Logger.Println(color.Info.Sprintf(message, args...))
 }
 
 // Console Debug Debug.
 func (d *Log) Debug(message string, args ...any) {
-	if DEBUG && d.checkNamespace(d.Prefix()) {
+	if d.log(LEVEL_DEBUG, message, args) {
+		return
+	}
+	if Enabled(d.Prefix()) {
 		d.Logger.Println(color.Debug.Sprintf(message, args...))
 	}
 }
 
 // Console log Success.
 func (d *Log) Success(message string, args ...any) {
+	if d.log(LEVEL_INFO, message, args) {
+		return
+	}
 	d.Logger.Println(color.Success.Sprintf(message, args...))
 }
 
 // Console log Error.
 func (d *Log) Error(message string, args ...any) {
+	if d.log(LEVEL_ERROR, message, args) {
+		return
+	}
 	d.Logger.Println(color.Danger.Sprintf(message, args...))
 }
 
 // Console log Warning.
 func (d *Log) Warning(message string, args ...any) {
+	if d.log(LEVEL_WARN, message, args) {
+		return
+	}
 	d.Logger.Println(color.Warn.Sprintf(message, args...))
 }
 
 // Console log Secondary.
 func (d *Log) Secondary(message string, args ...any) {
+	if d.log(LEVEL_INFO, message, args) {
+		return
+	}
 	d.Logger.Println(color.Secondary.Sprintf(message, args...))
 }
 
 // Console log Secondary.
 func (d *Log) Question(message string, args ...any) {
+	if d.log(LEVEL_INFO, message, args) {
+		return
+	}
 	d.Logger.Println(color.Question.Sprintf(message, args...))
 }
 
 // Console log Fatal.
 func (d *Log) Fatal(message string, args ...any) {
+	if d.log(LEVEL_ERROR, message, args) {
+		os.Exit(1)
+	}
 	d.Logger.Fatal(color.Error.Sprintf(message, args...))
 }
 
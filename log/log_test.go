@@ -2,8 +2,11 @@ package log
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
 	"os"
 	"regexp"
+	"strings"
 	"testing"
 )
 
@@ -43,3 +46,98 @@ func TestLog(t *testing.T) {
 	}
 	_log.SetOutput(os.Stderr)
 }
+
+func TestLevelLogger(t *testing.T) {
+	buf := new(bytes.Buffer)
+	logger := NewJSONLogger(buf)
+	_log := NewLog("engine:socket").WithLogger(logger).With("sid", "abc")
+
+	t.Run("levels", func(t *testing.T) {
+		_log.Debug("hidden")
+		if buf.Len() > 0 {
+			t.Fatalf(`*Log.Debug("hidden") = %q, want no output below the default level`, buf.String())
+		}
+
+		logger.SetLevel("engine:*", LEVEL_DEBUG)
+		logger.SetLevel("engine:socket", LEVEL_ERROR)
+		if level := logger.Level("engine:socket"); level != LEVEL_ERROR {
+			t.Fatalf(`*LevelLogger.Level() = %v, want match for %v`, level, LEVEL_ERROR)
+		}
+		if level := logger.Level("engine:polling"); level != LEVEL_DEBUG {
+			t.Fatalf(`*LevelLogger.Level() = %v, want match for %v`, level, LEVEL_DEBUG)
+		}
+		_log.Warning("hidden")
+		if buf.Len() > 0 {
+			t.Fatalf(`*Log.Warning("hidden") = %q, want no output below the namespace level`, buf.String())
+		}
+		logger.ResetLevels(LEVEL_DEBUG)
+	})
+
+	t.Run("json", func(t *testing.T) {
+		buf.Reset()
+		_log.With("transport", "polling", "err", errors.New("boom")).Debug("writing %q", "4hello")
+		var record map[string]any
+		if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+			t.Fatal("output should be valid JSON:", err)
+		}
+		for key, want := range map[string]any{"level": "debug", "namespace": "engine:socket", "msg": `writing "4hello"`, "sid": "abc", "transport": "polling", "err": "boom"} {
+			if record[key] != want {
+				t.Fatalf(`record[%q] = %v, want match for %v`, key, record[key], want)
+			}
+		}
+	})
+
+	t.Run("logfmt", func(t *testing.T) {
+		buf.Reset()
+		LogfmtEncoder(buf, &Record{Level: LEVEL_WARN, Namespace: "engine", Message: "a b", Fields: []any{"sid", "abc", "remote"}})
+		if line := buf.String(); !strings.HasSuffix(line, ` level=warn namespace=engine msg="a b" sid=abc remote=`+"\n") {
+			t.Fatalf(`LogfmtEncoder() = %q, want match for the record fields`, line)
+		}
+	})
+
+	t.Run("ParseLevel", func(t *testing.T) {
+		if level, err := ParseLevel("WARN"); err != nil || level != LEVEL_WARN {
+			t.Fatalf(`ParseLevel("WARN") = %v, %v, want match for %v`, level, err, LEVEL_WARN)
+		}
+		if _, err := ParseLevel("verbose"); err == nil {
+			t.Fatal(`ParseLevel("verbose") should fail`)
+		}
+	})
+}
+
+func TestNamespaces(t *testing.T) {
+	defer Disable()
+
+	t.Run("Enable", func(t *testing.T) {
+		Enable("engine:*, -engine:polling")
+		for namespace, want := range map[string]bool{"engine:socket": true, "engine:polling": false, "engine": false, "other": false} {
+			if enabled := Enabled(namespace); enabled != want {
+				t.Fatalf(`Enabled(%q) = %t, want match for %t`, namespace, enabled, want)
+			}
+		}
+		if spec := Namespaces(); spec != "engine:*, -engine:polling" {
+			t.Fatalf(`Namespaces() = %q, want match for %q`, spec, "engine:*, -engine:polling")
+		}
+	})
+
+	t.Run("existing logs", func(t *testing.T) {
+		buf := new(bytes.Buffer)
+		_log := NewLog("engine:ws")
+		_log.SetOutput(buf)
+
+		Enable("engine:ws")
+		_log.Debug("visible")
+		if buf.Len() == 0 {
+			t.Fatal(`*Log.Debug("visible") should write once its namespace is enabled`)
+		}
+
+		buf.Reset()
+		if spec := Disable(); spec != "engine:ws" {
+			t.Fatalf(`Disable() = %q, want match for %q`, spec, "engine:ws")
+		}
+		_log.Debug("hidden")
+		if buf.Len() > 0 {
+			t.Fatal(`*Log.Debug("hidden") should not write once disabled`)
+		}
+	})
+}
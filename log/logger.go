@@ -0,0 +1,251 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zishang520/engine.io/errors"
+)
+
+// Level of a log record.
+type Level int
+
+const (
+	LEVEL_DEBUG Level = iota
+	LEVEL_INFO
+	LEVEL_WARN
+	LEVEL_ERROR
+	LEVEL_OFF // disables a namespace
+)
+
+var levelNames = []string{"debug", "info", "warn", "error", "off"}
+
+func (l Level) String() string {
+	if l < LEVEL_DEBUG || l > LEVEL_OFF {
+		return "level(" + strconv.Itoa(int(l)) + ")"
+	}
+	return levelNames[l]
+}
+
+// Parses a level name such as "debug" or "warn".
+func ParseLevel(name string) (Level, error) {
+	for level, levelName := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(name), levelName) {
+			return Level(level), nil
+		}
+	}
+	return LEVEL_OFF, errors.New(fmt.Sprintf(`unknown log level "%s"`, name)).Err()
+}
+
+// Record is a single log entry.
+type Record struct {
+	Time      time.Time
+	Level     Level
+	Namespace string
+	Message   string
+	Fields    []any // alternating keys and values
+}
+
+// Logger receives the records of every Log it is attached to, implement it to
+// forward the engine logs to your own logging system.
+type Logger interface {
+	// Reports whether records of the namespace and level should be built at all.
+	Enabled(namespace string, level Level) bool
+
+	Log(*Record)
+}
+
+// Encoder serializes a record, including the trailing newline, into buf.
+type Encoder func(buf *bytes.Buffer, record *Record)
+
+// Encodes records as one JSON object per line.
+func JSONEncoder(buf *bytes.Buffer, record *Record) {
+	buf.WriteString(`{"time":`)
+	buf.WriteString(strconv.Quote(record.Time.Format(time.RFC3339Nano)))
+	buf.WriteString(`,"level":"`)
+	buf.WriteString(record.Level.String())
+	buf.WriteString(`","namespace":`)
+	writeJSONValue(buf, record.Namespace)
+	buf.WriteString(`,"msg":`)
+	writeJSONValue(buf, record.Message)
+	for i := 0; i < len(record.Fields); i += 2 {
+		buf.WriteByte(',')
+		writeJSONValue(buf, fmt.Sprint(record.Fields[i]))
+		buf.WriteByte(':')
+		writeJSONValue(buf, fieldValue(record.Fields, i+1))
+	}
+	buf.WriteString("}\n")
+}
+
+// Encodes records in the logfmt format.
+func LogfmtEncoder(buf *bytes.Buffer, record *Record) {
+	buf.WriteString("time=")
+	buf.WriteString(record.Time.Format(time.RFC3339Nano))
+	buf.WriteString(" level=")
+	buf.WriteString(record.Level.String())
+	buf.WriteString(" namespace=")
+	writeLogfmtValue(buf, record.Namespace)
+	buf.WriteString(" msg=")
+	writeLogfmtValue(buf, record.Message)
+	for i := 0; i < len(record.Fields); i += 2 {
+		buf.WriteByte(' ')
+		buf.WriteString(strings.Map(func(r rune) rune {
+			if r <= ' ' || r == '=' || r == '"' {
+				return '_'
+			}
+			return r
+		}, fmt.Sprint(record.Fields[i])))
+		buf.WriteByte('=')
+		switch value := fieldValue(record.Fields, i+1).(type) {
+		case nil:
+		case string:
+			writeLogfmtValue(buf, value)
+		default:
+			writeLogfmtValue(buf, fmt.Sprint(value))
+		}
+	}
+	buf.WriteByte('\n')
+}
+
+func fieldValue(fields []any, i int) any {
+	if i >= len(fields) {
+		return nil
+	}
+	switch value := fields[i].(type) {
+	case error:
+		return value.Error()
+	case fmt.Stringer:
+		return value.String()
+	case time.Duration:
+		return value.String()
+	default:
+		return value
+	}
+}
+
+func writeJSONValue(buf *bytes.Buffer, value any) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		data, _ = json.Marshal(fmt.Sprint(value))
+	}
+	buf.Write(data)
+}
+
+func writeLogfmtValue(buf *bytes.Buffer, value string) {
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n\\") {
+		buf.WriteString(strconv.Quote(value))
+		return
+	}
+	buf.WriteString(value)
+}
+
+type namespaceLevel struct {
+	pattern string
+	regexp  *regexp.Regexp
+	level   Level
+}
+
+// LevelLogger writes encoded records to an io.Writer, the minimum level can be
+// configured per namespace while the logger is in use.
+type LevelLogger struct {
+	encoder Encoder
+
+	w      io.Writer
+	levels []*namespaceLevel // most specific pattern first
+	level  Level             // used when no pattern matches
+
+	mu sync.RWMutex
+}
+
+// Returns a logger writing records encoded by encoder to w, only records of
+// LEVEL_INFO and above are written until configured otherwise.
+func NewLevelLogger(w io.Writer, encoder Encoder) *LevelLogger {
+	return &LevelLogger{encoder: encoder, w: w, level: LEVEL_INFO}
+}
+
+// Returns a logger writing JSON lines to w.
+func NewJSONLogger(w io.Writer) *LevelLogger {
+	return NewLevelLogger(w, JSONEncoder)
+}
+
+// Returns a logger writing logfmt lines to w.
+func NewLogfmtLogger(w io.Writer) *LevelLogger {
+	return NewLevelLogger(w, LogfmtEncoder)
+}
+
+// Sets the minimum level of the namespaces matching pattern, `*` matches any
+// sequence of characters and the pattern "*" changes the default level. The
+// most specific, that is longest, matching pattern applies.
+func (l *LevelLogger) SetLevel(pattern string, level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if pattern = strings.TrimSpace(pattern); pattern == "" || pattern == "*" {
+		l.level = level
+		return
+	}
+	for _, nl := range l.levels {
+		if nl.pattern == pattern {
+			nl.level = level
+			return
+		}
+	}
+	l.levels = append(l.levels, &namespaceLevel{
+		pattern: pattern,
+		regexp:  regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, `.*`) + "$"),
+		level:   level,
+	})
+	sort.SliceStable(l.levels, func(i, j int) bool { return len(l.levels[i].pattern) > len(l.levels[j].pattern) })
+}
+
+// Returns the minimum level of the namespace.
+func (l *LevelLogger) Level(namespace string) Level {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	for _, nl := range l.levels {
+		if nl.regexp.MatchString(namespace) {
+			return nl.level
+		}
+	}
+	return l.level
+}
+
+// Removes the namespace levels and sets the default level.
+func (l *LevelLogger) ResetLevels(level Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.levels = nil
+	l.level = level
+}
+
+// Sets the output destination.
+func (l *LevelLogger) SetOutput(w io.Writer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.w = w
+}
+
+func (l *LevelLogger) Enabled(namespace string, level Level) bool {
+	return level < LEVEL_OFF && level >= l.Level(namespace)
+}
+
+func (l *LevelLogger) Log(record *Record) {
+	buf := new(bytes.Buffer)
+	l.encoder(buf, record)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.w.Write(buf.Bytes())
+}
@@ -0,0 +1,58 @@
+// Package metrics provides the instrumentation interface of the engine and an
+// in-memory registry exposing it in the Prometheus text format.
+//
+// The metrics are aggregated over the server, no series is labelled by session
+// as their number would grow with every connection. The figures of a socket
+// are read from the socket itself, such as Socket.Latency, or listed by the
+// admin handler.
+package metrics
+
+// Metrics recorded by the engine.
+const (
+	BYTES_RECEIVED    = "engine_bytes_received_total"    // counter{transport}
+	BYTES_SENT        = "engine_bytes_sent_total"        // counter{transport}
+	PACKETS_RECEIVED  = "engine_packets_received_total"  // counter{type}
+	PACKETS_SENT      = "engine_packets_sent_total"      // counter{type}
+	HANDSHAKES        = "engine_handshakes_total"        // counter{transport,protocol}
+	CONNECTION_ERRORS = "engine_connection_errors_total" // counter{code}
+	UPGRADES          = "engine_upgrades_total"          // counter{from,to,result=success|failure|timeout}
+	CLIENTS           = "engine_clients"                 // gauge
+	PING_RTT          = "engine_ping_rtt_seconds"        // histogram
+)
+
+var descriptions map[string]string = map[string]string{
+	BYTES_RECEIVED:    "Packet payload bytes received from clients.",
+	BYTES_SENT:        "Packet payload bytes sent to clients.",
+	PACKETS_RECEIVED:  "Packets received from clients by type.",
+	PACKETS_SENT:      "Packets sent to clients by type.",
+	HANDSHAKES:        "Successful handshakes by transport and protocol.",
+	CONNECTION_ERRORS: "Rejected handshake, request and upgrade attempts by error code.",
+	UPGRADES:          "Transport upgrade attempts by outcome.",
+	CLIENTS:           "Number of connected clients.",
+	PING_RTT:          "Round-trip time measured from the heartbeat.",
+}
+
+// Labels of a single series.
+type Labels map[string]string
+
+// Recorder receives the measurements of the engine, implement it to forward
+// them to your own monitoring system.
+type Recorder interface {
+	// Adds value to a counter.
+	Count(name string, value float64, labels Labels)
+
+	// Adds value, which may be negative, to a gauge.
+	Gauge(name string, value float64, labels Labels)
+
+	// Records an observation of a histogram.
+	Observe(name string, value float64, labels Labels)
+}
+
+type discard struct{}
+
+func (discard) Count(string, float64, Labels)   {}
+func (discard) Gauge(string, float64, Labels)   {}
+func (discard) Observe(string, float64, Labels) {}
+
+// Discard is a Recorder that drops every measurement.
+var Discard Recorder = discard{}
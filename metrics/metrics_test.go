@@ -0,0 +1,112 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A response writer blocked until release is closed.
+type stalledWriter struct {
+	*httptest.ResponseRecorder
+	writing chan struct{}
+	release chan struct{}
+	once    sync.Once
+}
+
+func (w *stalledWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() { close(w.writing) })
+	<-w.release
+	return w.ResponseRecorder.Write(p)
+}
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry(0.1, 1)
+
+	r.Count(PACKETS_RECEIVED, 1, Labels{"type": "message"})
+	r.Count(PACKETS_RECEIVED, 2, Labels{"type": "message"})
+	r.Gauge(CLIENTS, 1, nil)
+	r.Gauge(CLIENTS, -1, nil)
+	r.Gauge(CLIENTS, 1, nil)
+	r.Observe(PING_RTT, 0.05, nil)
+	r.Observe(PING_RTT, 0.5, nil)
+	r.Observe(PING_RTT, 5, nil)
+
+	t.Run("Count", func(t *testing.T) {
+		if v := r.Value(PACKETS_RECEIVED, Labels{"type": "message"}); v != 3 {
+			t.Fatalf(`*Registry.Value(PACKETS_RECEIVED) = %v, want match for %v`, v, 3)
+		}
+	})
+
+	t.Run("Gauge", func(t *testing.T) {
+		if v := r.Value(CLIENTS, nil); v != 1 {
+			t.Fatalf(`*Registry.Value(CLIENTS) = %v, want match for %v`, v, 1)
+		}
+	})
+
+	t.Run("Kind", func(t *testing.T) {
+		r.Gauge(PACKETS_RECEIVED, 10, Labels{"type": "message"})
+		if v := r.Value(PACKETS_RECEIVED, Labels{"type": "message"}); v != 3 {
+			t.Fatalf(`*Registry.Value(PACKETS_RECEIVED) = %v, want match for %v`, v, 3)
+		}
+	})
+
+	t.Run("ServeHTTP", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+
+		if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+			t.Fatalf(`Content-Type = %q, want match for %q`, ct, "text/plain; version=0.0.4")
+		}
+
+		body := w.Body.String()
+		for _, line := range []string{
+			"# TYPE engine_clients gauge",
+			"engine_clients 1",
+			"# HELP engine_packets_received_total Packets received from clients by type.",
+			`engine_packets_received_total{type="message"} 3`,
+			"# TYPE engine_ping_rtt_seconds histogram",
+			`engine_ping_rtt_seconds_bucket{le="0.1"} 1`,
+			`engine_ping_rtt_seconds_bucket{le="1"} 2`,
+			`engine_ping_rtt_seconds_bucket{le="+Inf"} 3`,
+			"engine_ping_rtt_seconds_sum 5.55",
+			"engine_ping_rtt_seconds_count 3",
+		} {
+			if !strings.Contains(body, line+"\n") {
+				t.Fatalf("output does not contain %q:\n%s", line, body)
+			}
+		}
+	})
+
+	t.Run("StalledScraper", func(t *testing.T) {
+		r := NewRegistry()
+		// more than the buffer of the response
+		for i := 0; i < 200; i++ {
+			r.Count(PACKETS_RECEIVED, 1, Labels{"type": strconv.Itoa(i)})
+		}
+		w := &stalledWriter{ResponseRecorder: httptest.NewRecorder(), writing: make(chan struct{}), release: make(chan struct{})}
+		go r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+		<-w.writing
+		defer close(w.release)
+
+		counted := make(chan struct{})
+		go func() {
+			r.Count(PACKETS_RECEIVED, 1, Labels{"type": "message"})
+			close(counted)
+		}()
+		select {
+		case <-counted:
+		case <-time.After(5 * time.Second):
+			t.Fatal("*Registry.Count() waited for a stalled scraper")
+		}
+	})
+
+	t.Run("escape", func(t *testing.T) {
+		if l := formatLabels(Labels{"b": `"x"`, "a": "1\n"}); l != `a="1\n",b="\"x\""` {
+			t.Fatalf(`formatLabels() = %q, want match for %q`, l, `a="1\n",b="\"x\""`)
+		}
+	})
+}
@@ -0,0 +1,254 @@
+package metrics
+
+import (
+	"bufio"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Default histogram buckets, in the unit of the observed values.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+const (
+	counterKind   = "counter"
+	gaugeKind     = "gauge"
+	histogramKind = "histogram"
+)
+
+type series struct {
+	labels string
+
+	value float64
+
+	// histogram only
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type family struct {
+	kind   string
+	help   string
+	series map[string]*series
+}
+
+// Registry is an in-memory Recorder which serves its metrics in the Prometheus
+// text exposition format.
+type Registry struct {
+	buckets  []float64
+	families map[string]*family
+	help     map[string]string
+
+	mu sync.RWMutex
+}
+
+// NewRegistry returns an empty registry, histograms use the given buckets or
+// DefaultBuckets when none are given.
+func NewRegistry(buckets ...float64) *Registry {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	r := &Registry{
+		buckets:  buckets,
+		families: map[string]*family{},
+		help:     map[string]string{},
+	}
+	for name, help := range descriptions {
+		r.help[name] = help
+	}
+	return r
+}
+
+// Describe sets the help text of a metric.
+func (r *Registry) Describe(name string, help string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.help[name] = help
+	if f, ok := r.families[name]; ok {
+		f.help = help
+	}
+}
+
+func (r *Registry) Count(name string, value float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s := r.series(name, counterKind, labels); s != nil {
+		s.value += value
+	}
+}
+
+func (r *Registry) Gauge(name string, value float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s := r.series(name, gaugeKind, labels); s != nil {
+		s.value += value
+	}
+}
+
+func (r *Registry) Observe(name string, value float64, labels Labels) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.series(name, histogramKind, labels)
+	if s == nil {
+		return
+	}
+	if s.counts == nil {
+		s.counts = make([]uint64, len(r.buckets))
+	}
+	for i, le := range r.buckets {
+		if value <= le {
+			s.counts[i]++
+		}
+	}
+	s.sum += value
+	s.count++
+}
+
+// Returns the current value of a counter or gauge series.
+func (r *Registry) Value(name string, labels Labels) float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if f, ok := r.families[name]; ok {
+		if s, ok := f.series[formatLabels(labels)]; ok {
+			return s.value
+		}
+	}
+	return 0
+}
+
+// Returns the series of a metric, the caller must hold the write lock. A name
+// already registered with another kind is ignored.
+func (r *Registry) series(name string, kind string, labels Labels) *series {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{kind: kind, help: r.help[name], series: map[string]*series{}}
+		r.families[name] = f
+	} else if f.kind != kind {
+		return nil
+	}
+
+	key := formatLabels(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: key}
+		f.series[key] = s
+	}
+	return s
+}
+
+// A family copied to be written once the lock is released.
+type snapshot struct {
+	name   string
+	kind   string
+	help   string
+	series []series
+}
+
+// Copies the families sorted by name, and their series sorted by labels.
+func (r *Registry) snapshot() []snapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	families := make([]snapshot, 0, len(r.families))
+	for name, f := range r.families {
+		family := snapshot{name: name, kind: f.kind, help: f.help, series: make([]series, 0, len(f.series))}
+		for _, s := range f.series {
+			copied := *s
+			copied.counts = append([]uint64(nil), s.counts...)
+			family.series = append(family.series, copied)
+		}
+		sort.Slice(family.series, func(i, j int) bool { return family.series[i].labels < family.series[j].labels })
+		families = append(families, family)
+	}
+	sort.Slice(families, func(i, j int) bool { return families[i].name < families[j].name })
+	return families
+}
+
+// Serves the metrics in the Prometheus text exposition format. The metrics are
+// copied first, a slow scraper does not hold the sockets recording them.
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	families := r.snapshot()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+
+	buf := bufio.NewWriter(w)
+	defer buf.Flush()
+
+	for _, f := range families {
+		name := f.name
+		if f.help != "" {
+			buf.WriteString("# HELP " + name + " " + escape(f.help, false) + "\n")
+		}
+		buf.WriteString("# TYPE " + name + " " + f.kind + "\n")
+
+		for _, s := range f.series {
+			if f.kind != histogramKind {
+				buf.WriteString(name + braces(s.labels) + " " + formatFloat(s.value) + "\n")
+				continue
+			}
+			for i, le := range r.buckets {
+				buf.WriteString(name + "_bucket" + braces(join(s.labels, `le="`+formatFloat(le)+`"`)) + " " + strconv.FormatUint(s.counts[i], 10) + "\n")
+			}
+			buf.WriteString(name + "_bucket" + braces(join(s.labels, `le="+Inf"`)) + " " + strconv.FormatUint(s.count, 10) + "\n")
+			buf.WriteString(name + "_sum" + braces(s.labels) + " " + formatFloat(s.sum) + "\n")
+			buf.WriteString(name + "_count" + braces(s.labels) + " " + strconv.FormatUint(s.count, 10) + "\n")
+		}
+	}
+}
+
+// Returns the labels as sorted `name="value"` pairs.
+func formatLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	pairs := make([]string, 0, len(names))
+	for _, name := range names {
+		pairs = append(pairs, name+`="`+escape(labels[name], true)+`"`)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func join(labels string, pair string) string {
+	if labels == "" {
+		return pair
+	}
+	return labels + "," + pair
+}
+
+func braces(labels string) string {
+	if labels == "" {
+		return ""
+	}
+	return "{" + labels + "}"
+}
+
+func escape(s string, quote bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quote {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -20,8 +20,25 @@ const (
 	ERROR   Type = "error"
 )
 
+type Priority int
+
+// Write priorities, packets of a higher priority are flushed first.
+const (
+	PRIORITY_BULK    Priority = -1
+	PRIORITY_NORMAL  Priority = 0
+	PRIORITY_HIGH    Priority = 1
+	PRIORITY_CONTROL Priority = 2 // reserved for protocol packets
+)
+
 type Options struct {
 	Compress bool `json:"compress"`
+
+	// Write priority of a message, PRIORITY_CONTROL is reserved for protocol packets.
+	Priority Priority `json:"priority,omitempty"`
+
+	// Key of a bulk message, a queued bulk message is superseded by a newer one
+	// with the same key according to the socket's bulk policy.
+	Key string `json:"key,omitempty"`
 }
 
 type Packet struct {
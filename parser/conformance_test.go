@@ -0,0 +1,128 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// A test vector of the reference JavaScript implementation, the binary fields
+// are base64 encoded.
+type conformanceVector struct {
+	Name           string              `json:"name"`
+	Protocol       int                 `json:"protocol"`
+	SupportsBinary bool                `json:"supportsBinary,omitempty"`
+	Packets        []conformancePacket `json:"packets"`
+	Payload        string              `json:"payload,omitempty"`
+	BinaryPayload  []byte              `json:"binaryPayload,omitempty"`
+}
+
+type conformancePacket struct {
+	Type          packet.Type `json:"type"`
+	Data          *string     `json:"data,omitempty"`
+	BinaryData    []byte      `json:"binaryData,omitempty"`
+	Encoded       string      `json:"encoded,omitempty"`
+	BinaryEncoded []byte      `json:"binaryEncoded,omitempty"`
+}
+
+func (c conformancePacket) packet() *packet.Packet {
+	switch {
+	case c.BinaryData != nil:
+		return &packet.Packet{Type: c.Type, Data: types.NewBytesBuffer(c.BinaryData)}
+	case c.Data != nil:
+		return &packet.Packet{Type: c.Type, Data: types.NewStringBufferString(*c.Data)}
+	}
+	return &packet.Packet{Type: c.Type}
+}
+
+func (c conformancePacket) encoded() types.BufferInterface {
+	if c.BinaryEncoded != nil {
+		return types.NewBytesBuffer(c.BinaryEncoded)
+	}
+	return types.NewStringBufferString(c.Encoded)
+}
+
+func (c conformancePacket) check(t *testing.T, got *packet.Packet) {
+	if c.BinaryData != nil {
+		checkPacket(t, got, c.Type, c.BinaryData, true)
+	} else if c.Data != nil {
+		checkPacket(t, got, c.Type, []byte(*c.Data), false)
+	} else {
+		checkPacket(t, got, c.Type, nil, false)
+	}
+}
+
+func (v conformanceVector) packets() (packets []*packet.Packet) {
+	for _, p := range v.Packets {
+		packets = append(packets, p.packet())
+	}
+	return packets
+}
+
+func (v conformanceVector) payload() types.BufferInterface {
+	if v.BinaryPayload != nil {
+		return types.NewBytesBuffer(v.BinaryPayload)
+	}
+	return types.NewStringBufferString(v.Payload)
+}
+
+func conformanceVectors(tb testing.TB, protocol int) (vectors []conformanceVector) {
+	data, err := os.ReadFile("testdata/conformance.json")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	var all []conformanceVector
+	if err := json.Unmarshal(data, &all); err != nil {
+		tb.Fatal(err)
+	}
+	for _, v := range all {
+		if v.Protocol == protocol {
+			vectors = append(vectors, v)
+		}
+	}
+	return vectors
+}
+
+func TestConformance(t *testing.T) {
+	for _, p := range []Parser{Parserv3(), Parserv4()} {
+		for _, v := range conformanceVectors(t, p.Protocol()) {
+			p, v := p, v
+			t.Run(fmt.Sprintf("v%d/%s", p.Protocol(), v.Name), func(t *testing.T) {
+				for _, c := range v.Packets {
+					encoded, err := p.EncodePacket(c.packet(), v.SupportsBinary)
+					if err != nil {
+						t.Fatalf(`EncodePacket() = %v, want match for nil`, err)
+					}
+					if want := c.encoded().Bytes(); !bytes.Equal(encoded.Bytes(), want) {
+						t.Fatalf(`EncodePacket() = %q, want match for %q`, encoded.Bytes(), want)
+					}
+					decoded, err := p.DecodePacket(c.encoded())
+					if err != nil {
+						t.Fatalf(`DecodePacket() = %v, want match for nil`, err)
+					}
+					c.check(t, decoded)
+				}
+
+				payload, err := p.EncodePayload(v.packets(), v.SupportsBinary)
+				if err != nil {
+					t.Fatalf(`EncodePayload() = %v, want match for nil`, err)
+				}
+				if want := v.payload().Bytes(); !bytes.Equal(payload.Bytes(), want) {
+					t.Fatalf(`EncodePayload() = %q, want match for %q`, payload.Bytes(), want)
+				}
+				packets := p.DecodePayload(v.payload())
+				if len(packets) != len(v.Packets) {
+					t.Fatalf(`DecodePayload() decoded %d packets, want match for %d`, len(packets), len(v.Packets))
+				}
+				for i, c := range v.Packets {
+					c.check(t, packets[i])
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package parser
+
+import (
+	"github.com/zishang520/engine.io/errors"
+)
+
+// Failures reported by the PayloadDecoder, compare with errors.Is.
+var (
+	ErrPayloadTooLarge = errors.New("payload too large").WithCode("PARSER_PAYLOAD_TOO_LARGE")
+	ErrPacketTooLarge  = errors.New("packet too large").WithCode("PARSER_PACKET_TOO_LARGE")
+	ErrTooManyPackets  = errors.New("too many packets in payload").WithCode("PARSER_TOO_MANY_PACKETS")
+	ErrInvalidPacket   = errors.New("invalid packet").WithCode("PARSER_INVALID_PACKET")
+)
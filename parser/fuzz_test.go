@@ -0,0 +1,218 @@
+package parser
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// Builds the packet of the round trip targets, the type is folded into the
+// packet types.
+func fuzzPacket(typ byte, data []byte, binary bool) *packet.Packet {
+	p := &packet.Packet{Type: PACKET_TYPES_REVERSE['0'+typ%7]}
+	if binary {
+		p.Data = types.NewBytesBuffer(data)
+	} else {
+		p.Data = types.NewStringBuffer(data)
+	}
+	return p
+}
+
+func packetData(t *testing.T, p *packet.Packet) []byte {
+	if p.Data == nil {
+		return nil
+	}
+	data, err := io.ReadAll(p.Data)
+	if err != nil {
+		t.Fatalf("reading the packet data failed: %v", err)
+	}
+	return data
+}
+
+func checkPacket(t *testing.T, got *packet.Packet, typ packet.Type, data []byte, binary bool) {
+	if got.Type != typ {
+		t.Fatalf(`packet.Type = %q, want match for %q`, got.Type, typ)
+	}
+	if _, isBinary := got.Data.(*types.BytesBuffer); isBinary != binary {
+		t.Fatalf(`packet.Data = %T, want binary %t`, got.Data, binary)
+	}
+	if b := packetData(t, got); !bytes.Equal(b, data) {
+		t.Fatalf(`packet.Data = %q, want match for %q`, b, data)
+	}
+}
+
+// Decoding arbitrary input must neither panic nor hang.
+func FuzzParserv3DecodePacket(f *testing.F) {
+	for _, v := range conformanceVectors(f, 3) {
+		for _, c := range v.Packets {
+			f.Add(c.encoded().Bytes(), c.BinaryEncoded == nil, false)
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte, text bool, utf8decode bool) {
+		if text {
+			Parserv3().DecodePacket(types.NewStringBuffer(data), utf8decode)
+		} else {
+			Parserv3().DecodePacket(types.NewBytesBuffer(data), utf8decode)
+		}
+	})
+}
+
+func FuzzParserv3DecodePayload(f *testing.F) {
+	for _, v := range conformanceVectors(f, 3) {
+		f.Add(v.payload().Bytes(), v.BinaryPayload == nil)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, text bool) {
+		var packets []*packet.Packet
+		if text {
+			packets = Parserv3().DecodePayload(types.NewStringBuffer(data))
+		} else {
+			packets = Parserv3().DecodePayload(types.NewBytesBuffer(data))
+		}
+		if len(packets) > len(data) {
+			t.Fatalf(`DecodePayload() decoded %d packets out of %d bytes`, len(packets), len(data))
+		}
+	})
+}
+
+func FuzzParserv4DecodePacket(f *testing.F) {
+	for _, v := range conformanceVectors(f, 4) {
+		for _, c := range v.Packets {
+			f.Add(c.encoded().Bytes(), c.BinaryEncoded == nil)
+		}
+	}
+	f.Fuzz(func(t *testing.T, data []byte, text bool) {
+		if text {
+			Parserv4().DecodePacket(types.NewStringBuffer(data))
+		} else {
+			Parserv4().DecodePacket(types.NewBytesBuffer(data))
+		}
+	})
+}
+
+func FuzzParserv4DecodePayload(f *testing.F) {
+	for _, v := range conformanceVectors(f, 4) {
+		f.Add([]byte(v.Payload))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if packets := Parserv4().DecodePayload(types.NewStringBuffer(data)); len(packets) > len(data) {
+			t.Fatalf(`DecodePayload() decoded %d packets out of %d bytes`, len(packets), len(data))
+		}
+	})
+}
+
+// Decode(Encode(p)) == p, the data is valid UTF-8 as the text packets of v3
+// are counted in UTF-16 code units.
+func FuzzParserv3RoundTrip(f *testing.F) {
+	f.Add(byte(4), []byte("hello"), false, false)
+	f.Add(byte(4), []byte("€😀"), false, true)
+	f.Add(byte(4), []byte{0, 1, 2, 0xff}, true, true)
+	f.Add(byte(4), []byte{0, 1, 2, 0xff}, true, false)
+	f.Add(byte(2), []byte{}, false, true)
+	f.Fuzz(func(t *testing.T, typ byte, data []byte, binary bool, supportsBinary bool) {
+		if !utf8.Valid(data) {
+			t.Skip()
+		}
+		p := Parserv3()
+		typeOf := PACKET_TYPES_REVERSE['0'+typ%7]
+
+		encoded, err := p.EncodePacket(fuzzPacket(typ, data, binary), supportsBinary)
+		if err != nil {
+			t.Fatalf(`EncodePacket() = %v, want match for nil`, err)
+		}
+		decoded, err := p.DecodePacket(encoded)
+		if err != nil {
+			t.Fatalf(`DecodePacket() = %v, want match for nil`, err)
+		}
+		checkPacket(t, decoded, typeOf, data, binary)
+
+		// the text and binary packets share the payload
+		payload, err := p.EncodePayload([]*packet.Packet{fuzzPacket(typ, data, binary), fuzzPacket(typ, data, !binary), fuzzPacket(typ, data, binary)}, supportsBinary)
+		if err != nil {
+			t.Fatalf(`EncodePayload() = %v, want match for nil`, err)
+		}
+		packets := p.DecodePayload(payload)
+		if len(packets) != 3 {
+			t.Fatalf(`DecodePayload() decoded %d packets, want match for %d`, len(packets), 3)
+		}
+		for i, decoded := range packets {
+			checkPacket(t, decoded, typeOf, data, binary != (i == 1))
+		}
+	})
+}
+
+// Decode(Encode(p)) == p, the text packets of a v4 payload must not hold the
+// separator.
+func FuzzParserv4RoundTrip(f *testing.F) {
+	f.Add(byte(4), []byte("hello"), false, false)
+	f.Add(byte(4), []byte("€😀"), false, true)
+	f.Add(byte(4), []byte{0, 1, 2, 0xff}, true, true)
+	f.Add(byte(4), []byte{0, 1, 2, 0xff}, true, false)
+	f.Add(byte(2), []byte("probe"), false, true)
+	f.Fuzz(func(t *testing.T, typ byte, data []byte, binary bool, supportsBinary bool) {
+		if binary {
+			// binary packets are messages
+			typ = 4
+		}
+		p := Parserv4()
+		typeOf := PACKET_TYPES_REVERSE['0'+typ%7]
+
+		encoded, err := p.EncodePacket(fuzzPacket(typ, data, binary), supportsBinary)
+		if err != nil {
+			t.Fatalf(`EncodePacket() = %v, want match for nil`, err)
+		}
+		decoded, err := p.DecodePacket(encoded)
+		if err != nil {
+			t.Fatalf(`DecodePacket() = %v, want match for nil`, err)
+		}
+		checkPacket(t, decoded, typeOf, data, binary)
+
+		if !binary && bytes.IndexByte(data, SEPARATOR) >= 0 {
+			return
+		}
+		payload, err := p.EncodePayload([]*packet.Packet{fuzzPacket(typ, data, binary), fuzzPacket(typ, data, binary)})
+		if err != nil {
+			t.Fatalf(`EncodePayload() = %v, want match for nil`, err)
+		}
+		packets := p.DecodePayload(payload)
+		if len(packets) != 2 {
+			t.Fatalf(`DecodePayload() decoded %d packets, want match for %d`, len(packets), 2)
+		}
+		for _, decoded := range packets {
+			checkPacket(t, decoded, typeOf, data, binary)
+		}
+	})
+}
+
+// The streaming decoder decodes the payloads like Parserv4().DecodePayload.
+func FuzzPayloadDecoder(f *testing.F) {
+	for _, v := range conformanceVectors(f, 4) {
+		f.Add([]byte(v.Payload))
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoder := NewPayloadDecoder(bytes.NewReader(data), PayloadLimits{})
+		var packets []*packet.Packet
+		for {
+			packet, err := decoder.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return
+			}
+			packets = append(packets, packet)
+		}
+
+		want := Parserv4().DecodePayload(types.NewStringBuffer(data))
+		if len(packets) != len(want) {
+			t.Fatalf(`*PayloadDecoder.Next() decoded %d packets, want match for %d`, len(packets), len(want))
+		}
+		for i, p := range packets {
+			_, binary := want[i].Data.(*types.BytesBuffer)
+			checkPacket(t, p, want[i].Type, packetData(t, want[i]), binary)
+		}
+	})
+}
@@ -6,7 +6,6 @@ import (
 	"io"
 	"strconv"
 	"strings"
-	"unicode/utf8"
 
 	"github.com/zishang520/engine.io/errors"
 	"github.com/zishang520/engine.io/packet"
@@ -42,7 +41,8 @@ func (p *parserv3) EncodePacket(data *packet.Packet, supportsBinary bool, utf8en
 
 	switch v := data.Data.(type) {
 	case *types.StringBuffer, *strings.Reader:
-		encode := types.NewStringBuffer(nil)
+		encode := types.AcquireStringBuffer()
+		encode.Grow(1 + v.(interface{ Len() int }).Len())
 		// Sending data as a utf-8 string
 		if err := encode.WriteByte(PACKET_TYPES[data.Type]); err != nil {
 			return nil, err
@@ -62,7 +62,10 @@ func (p *parserv3) EncodePacket(data *packet.Packet, supportsBinary bool, utf8en
 		// Encode Buffer data
 		if !supportsBinary {
 			// Encodes a packet with binary data in a base64 string
-			encode := types.NewStringBuffer(nil)
+			encode := types.AcquireStringBuffer()
+			if l, ok := v.(interface{ Len() int }); ok {
+				encode.Grow(2 + base64.StdEncoding.EncodedLen(l.Len()))
+			}
 			if _, err := encode.Write([]byte{'b', PACKET_TYPES[data.Type]}); err != nil {
 				return nil, err
 			}
@@ -74,17 +77,17 @@ func (p *parserv3) EncodePacket(data *packet.Packet, supportsBinary bool, utf8en
 			}
 			return encode, nil
 		}
-		encode := types.NewBytesBuffer(nil)
+		encode := types.AcquireBytesBuffer()
 		if err := encode.WriteByte(PACKET_TYPES[data.Type] - '0'); err != nil {
 			return nil, err
 		}
-		if _, err := io.Copy(encode, v); err != nil {
+		if _, err := encode.ReadFrom(v); err != nil {
 			return nil, err
 		}
 		return encode, nil
 	}
 	// default nil
-	encode := types.NewStringBuffer(nil)
+	encode := types.AcquireStringBuffer()
 	if err := encode.WriteByte(PACKET_TYPES[data.Type]); err != nil {
 		return nil, err
 	}
@@ -92,6 +95,9 @@ func (p *parserv3) EncodePacket(data *packet.Packet, supportsBinary bool, utf8en
 }
 
 // Decodes a packet. Data also available as an ArrayBuffer if requested.
+//
+// Unless utf8decode is set, the data of the decoded packet aliases the unread
+// content of data instead of copying it, data must not be modified afterwards.
 func (p *parserv3) DecodePacket(data types.BufferInterface, utf8decode ...bool) (*packet.Packet, error) {
 	utf8decode = append(utf8decode, false)
 	if data == nil {
@@ -115,25 +121,24 @@ func (p *parserv3) DecodePacket(data types.BufferInterface, utf8decode ...bool)
 			if !ok {
 				return ERROR_PACKET, errors.New(fmt.Sprintf(`Parsing error, unknown data type [%c]`, msgType)).Err()
 			}
-			decode := types.NewBytesBuffer(nil)
-			if _, err := decode.ReadFrom(base64.NewDecoder(base64.StdEncoding, v)); err != nil {
+			encoded := v.Next(v.Len())
+			decoded := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+			n, err := base64.StdEncoding.Decode(decoded, encoded)
+			if err != nil {
 				return ERROR_PACKET, err
 			}
-			return &packet.Packet{Type: packetType, Data: decode}, nil
+			return &packet.Packet{Type: packetType, Data: types.NewBytesBuffer(decoded[:n])}, nil
 		}
 		packetType, ok := PACKET_TYPES_REVERSE[msgType]
 		if !ok {
 			return ERROR_PACKET, errors.New(fmt.Sprintf(`Parsing error, unknown data type [%c]`, msgType)).Err()
 		}
+		if !utf8decode[0] {
+			return &packet.Packet{Type: packetType, Data: types.NewStringBuffer(v.Next(v.Len()))}, nil
+		}
 		decode := types.NewStringBuffer(nil)
-		if utf8decode[0] {
-			if _, err := decode.ReadFrom(utils.NewUtf8Decoder(v)); err != nil {
-				return ERROR_PACKET, err
-			}
-		} else {
-			if _, err := decode.ReadFrom(v); err != nil {
-				return ERROR_PACKET, err
-			}
+		if _, err := decode.ReadFrom(utils.NewUtf8Decoder(v)); err != nil {
+			return ERROR_PACKET, err
 		}
 		return &packet.Packet{Type: packetType, Data: decode}, nil
 	}
@@ -143,11 +148,7 @@ func (p *parserv3) DecodePacket(data types.BufferInterface, utf8decode ...bool)
 	if !ok {
 		return ERROR_PACKET, errors.New(fmt.Sprintf(`Parsing error, unknown data type [%c]`, msgType+'0')).Err()
 	}
-	decode := types.NewBytesBuffer(nil)
-	if _, err := io.Copy(decode, data); err != nil {
-		return ERROR_PACKET, err
-	}
-	return &packet.Packet{Type: packetType, Data: decode}, nil
+	return &packet.Packet{Type: packetType, Data: types.NewBytesBuffer(data.Next(data.Len()))}, nil
 }
 
 func (p *parserv3) hasBinary(packets []*packet.Packet) bool {
@@ -186,7 +187,7 @@ func (p *parserv3) EncodePayload(packets []*packet.Packet, supportsBinary ...boo
 		return p.encodePayloadAsBinary(packets)
 	}
 
-	enPayload := types.NewStringBuffer(nil)
+	enPayload := types.AcquireStringBuffer()
 
 	if len(packets) == 0 {
 		if _, err := enPayload.WriteString(`0:`); err != nil {
@@ -200,7 +201,11 @@ func (p *parserv3) EncodePayload(packets []*packet.Packet, supportsBinary ...boo
 		if err != nil {
 			return nil, err
 		}
-		if _, err := enPayload.WriteString(strconv.FormatInt(int64(utils.Utf16Count(buf.Bytes())), 10) + ":" + buf.String()); err != nil {
+		enPayload.WriteString(strconv.FormatInt(int64(utils.Utf16Count(buf.Bytes())), 10))
+		enPayload.WriteByte(':')
+		_, err = buf.WriteTo(enPayload)
+		types.ReleaseBuffer(buf)
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -208,42 +213,30 @@ func (p *parserv3) EncodePayload(packets []*packet.Packet, supportsBinary ...boo
 	return enPayload, nil
 }
 
+// Encodes a packet of a binary payload, the strings are sent as their UTF-8
+// bytes and their length counts the bytes, as does the reference
+// implementation.
 func (p *parserv3) encodeOneBinaryPacket(packet *packet.Packet) (types.BufferInterface, error) {
 	if packet == nil {
 		return nil, errors.New("packet must not be nil").Err()
 	}
-	binarypacket := types.NewBytesBuffer(nil)
+	binarypacket := types.AcquireBytesBuffer()
 
-	buf, err := p.EncodePacket(packet, true, true)
+	buf, err := p.EncodePacket(packet, true)
 	if err != nil {
 		return nil, err
 	}
+	defer types.ReleaseBuffer(buf)
 
+	// is binary (true binary = 1, string = 0)
+	isBinary := byte(1)
 	if _, ok := buf.(*types.StringBuffer); ok {
-		encodingLength := strconv.FormatInt(int64(utils.Utf16Count(buf.Bytes())), 10) // JS length
-		if err := binarypacket.WriteByte(0); err != nil {
-			return nil, err
-		}
-		for i, l := 0, len(encodingLength); i < l; i++ {
-			if err := binarypacket.WriteByte(encodingLength[i] - '0'); err != nil {
-				return nil, err
-			}
-		}
-		if err := binarypacket.WriteByte(0xFF); err != nil {
-			return nil, err
-		}
-		if _, err := buf.WriteTo(utils.NewUtf8Encoder(binarypacket)); err != nil {
-			return nil, err
-		}
-
-		return binarypacket, nil
+		isBinary = 0
 	}
-
-	encodingLength := strconv.FormatInt(int64(buf.Len()), 10)
-	// is binary (true binary = 1)
-	if err := binarypacket.WriteByte(1); err != nil {
+	if err := binarypacket.WriteByte(isBinary); err != nil {
 		return nil, err
 	}
+	encodingLength := strconv.FormatInt(int64(buf.Len()), 10)
 	for i, l := 0, len(encodingLength); i < l; i++ {
 		if err := binarypacket.WriteByte(encodingLength[i] - '0'); err != nil {
 			return nil, err
@@ -267,7 +260,7 @@ func (p *parserv3) encodeOneBinaryPacket(packet *packet.Packet) (types.BufferInt
 // Example:
 // 1 3 255 1 2 3, if the binary contents are interpreted as 8 bit integers
 func (p *parserv3) encodePayloadAsBinary(packets []*packet.Packet) (types.BufferInterface, error) {
-	enPayload := types.NewBytesBuffer(nil)
+	enPayload := types.AcquireBytesBuffer()
 
 	if len(packets) == 0 {
 		return enPayload, nil
@@ -278,7 +271,9 @@ func (p *parserv3) encodePayloadAsBinary(packets []*packet.Packet) (types.Buffer
 		if err != nil {
 			return nil, err
 		}
-		if _, err := enPayload.ReadFrom(buf); err != nil {
+		_, err = enPayload.ReadFrom(buf)
+		types.ReleaseBuffer(buf)
+		if err != nil {
 			return nil, err
 		}
 	}
@@ -323,6 +318,7 @@ func (p *parserv3) DecodePayload(data types.BufferInterface) (packets []*packet.
 				packet, err := p.DecodePacket(msg, false)
 				if err != nil {
 					// parser error in individual packet - ignoring payload
+					parser_log.Debug("ignoring payload with undecodable packet: %v", err)
 					return packets
 				}
 				packets = append(packets, packet)
@@ -333,81 +329,47 @@ func (p *parserv3) DecodePayload(data types.BufferInterface) (packets []*packet.
 	return p.decodePayloadAsBinary(data)
 }
 
-// Decodes data when a payload is maybe expected. Strings are decoded by
-// interpreting each byte as a key code for entries marked to start with 0. See
-// description of encodePayloadAsBinary
+// Decodes data when a payload is maybe expected. The length of each packet
+// counts its bytes, see encodePayloadAsBinary.
 func (p *parserv3) decodePayloadAsBinary(bufferTail types.BufferInterface) (packets []*packet.Packet) {
-	PACKETLEN := 0
 	for bufferTail.Len() > 0 {
 		startByte, err := bufferTail.ReadByte()
 		if err != nil {
-			// parser error in individual packet - ignoring payload
 			return packets
 		}
 		isString := startByte == 0x00
 		length, err := bufferTail.ReadBytes(0xFF)
 		if err != nil {
+			// parser error in individual packet - ignoring payload
 			return packets
 		}
-		_l := len(length)
-		if _l < 1 {
-			return packets
-		}
-		lenByte := length[:_l-1]
-		for k, l := 0, len(lenByte); k < l; k++ {
-			lenByte[k] = lenByte[k] + '0'
+		packetLen := 0
+		for _, digit := range length[:len(length)-1] {
+			if digit > 9 {
+				return packets
+			}
+			if packetLen = packetLen*10 + int(digit); packetLen > bufferTail.Len() {
+				// truncated payload
+				return packets
+			}
 		}
-		packetLen, err := strconv.ParseInt(string(lenByte), 10, 0)
-		if err != nil {
-			return packets
+		data := bufferTail.Next(packetLen)
+		if len(data) == 0 {
+			continue
 		}
-		PACKETLEN = int(packetLen)
+
+		var encoded types.BufferInterface
 		if isString {
-			data := types.NewStringBuffer(nil)
-			buf := make([]byte, 0, 4) // rune bytes
-			for k := 0; k < PACKETLEN; {
-				// read utf8
-				for len(buf) < 4 {
-					r, _, err := bufferTail.ReadRune()
-					if err != nil {
-						if err == io.EOF {
-							break
-						}
-						return packets
-					}
-					if !utf8.ValidRune(r) {
-						r = 0xFFFD
-					}
-					buf = append(buf, byte(r))
-				}
-				r, l := utf8.DecodeRune(buf)
-				k += utils.Utf16Len(r)
-				if _, err := data.Write(utils.Utf8decodeBytes(buf[0:l])); err != nil {
-					return packets
-				}
-				buf = buf[l:]
-			}
-			if cursor := len(utils.Utf8encodeBytes(buf)); cursor > 0 {
-				bufferTail.Seek(-int64(cursor), io.SeekCurrent)
-			}
-			if data.Len() > 0 {
-				packet, err := p.DecodePacket(data, false)
-				if err != nil {
-					// parser error in individual packet - ignoring payload
-					return packets
-				}
-				packets = append(packets, packet)
-			}
+			encoded = types.NewStringBuffer(data)
 		} else {
-			if data := bufferTail.Next(PACKETLEN); len(data) > 0 {
-				packet, err := p.DecodePacket(types.NewBytesBuffer(data), false)
-				if err != nil {
-					// parser error in individual packet - ignoring payload
-					return packets
-				}
-				packets = append(packets, packet)
-			}
+			encoded = types.NewBytesBuffer(data)
+		}
+		packet, err := p.DecodePacket(encoded, false)
+		if err != nil {
+			// parser error in individual packet - ignoring payload
+			return packets
 		}
+		packets = append(packets, packet)
 	}
 
 	return packets
@@ -1,7 +1,6 @@
 package parser
 
 import (
-	"bufio"
 	"bytes"
 	"encoding/base64"
 	"fmt"
@@ -44,7 +43,8 @@ func (p *parserv4) EncodePacket(data *packet.Packet, supportsBinary bool, _ ...b
 
 	switch v := data.Data.(type) {
 	case *types.StringBuffer, *strings.Reader:
-		encode := types.NewStringBuffer(nil)
+		encode := types.AcquireStringBuffer()
+		encode.Grow(1 + v.(interface{ Len() int }).Len())
 		if err := encode.WriteByte(_type); err != nil {
 			return nil, err
 		}
@@ -56,7 +56,10 @@ func (p *parserv4) EncodePacket(data *packet.Packet, supportsBinary bool, _ ...b
 	case io.Reader:
 		if !supportsBinary {
 			// only 'message' packets can contain binary, so the type prefix is not needed
-			encode := types.NewStringBuffer(nil)
+			encode := types.AcquireStringBuffer()
+			if l, ok := v.(interface{ Len() int }); ok {
+				encode.Grow(1 + base64.StdEncoding.EncodedLen(l.Len()))
+			}
 			if err := encode.WriteByte('b'); err != nil {
 				return nil, err
 			}
@@ -68,19 +71,21 @@ func (p *parserv4) EncodePacket(data *packet.Packet, supportsBinary bool, _ ...b
 			return encode, nil
 		}
 		// plain string
-		encode := types.NewBytesBuffer(nil)
-		if _, err := io.Copy(encode, v); err != nil {
+		encode := types.AcquireBytesBuffer()
+		if _, err := encode.ReadFrom(v); err != nil {
 			return nil, err
 		}
 		return encode, nil
 	}
-	encode := types.NewStringBuffer(nil)
+	encode := types.AcquireStringBuffer()
 	if err := encode.WriteByte(_type); err != nil {
 		return nil, err
 	}
 	return encode, nil
 }
 
+// The data of the decoded packet aliases the unread content of data instead of
+// copying it, data must not be modified afterwards.
 func (p *parserv4) DecodePacket(data types.BufferInterface, _ ...bool) (*packet.Packet, error) {
 	if data == nil {
 		return ERROR_PACKET, errors.New(`parser error`).Err()
@@ -94,33 +99,27 @@ func (p *parserv4) DecodePacket(data types.BufferInterface, _ ...bool) (*packet.
 			return ERROR_PACKET, err
 		}
 		if msgType == 'b' {
-			decode := types.NewBytesBuffer(nil)
-			if _, err := decode.ReadFrom(base64.NewDecoder(base64.StdEncoding, v)); err != nil {
+			encoded := v.Next(v.Len())
+			decoded := make([]byte, base64.StdEncoding.DecodedLen(len(encoded)))
+			n, err := base64.StdEncoding.Decode(decoded, encoded)
+			if err != nil {
 				return ERROR_PACKET, err
 			}
-			return &packet.Packet{Type: packet.MESSAGE, Data: decode}, nil
+			return &packet.Packet{Type: packet.MESSAGE, Data: types.NewBytesBuffer(decoded[:n])}, nil
 		}
 		packetType, ok := PACKET_TYPES_REVERSE[msgType]
 		if !ok {
 			return ERROR_PACKET, errors.New(fmt.Sprintf(`Parsing error, unknown data type [%c]`, msgType)).Err()
 		}
-		stringBuffer := types.NewStringBuffer(nil)
-		if _, err := stringBuffer.ReadFrom(v); err != nil {
-			return ERROR_PACKET, err
-		}
-		return &packet.Packet{Type: packetType, Data: stringBuffer}, nil
+		return &packet.Packet{Type: packetType, Data: types.NewStringBuffer(v.Next(v.Len()))}, nil
 	}
 
 	// binary
-	decode := types.NewBytesBuffer(nil)
-	if _, err := io.Copy(decode, data); err != nil {
-		return ERROR_PACKET, err
-	}
-	return &packet.Packet{Type: packet.MESSAGE, Data: decode}, nil
+	return &packet.Packet{Type: packet.MESSAGE, Data: types.NewBytesBuffer(data.Next(data.Len()))}, nil
 }
 
 func (p *parserv4) EncodePayload(packets []*packet.Packet, _ ...bool) (types.BufferInterface, error) {
-	enPayload := types.NewStringBuffer(nil)
+	enPayload := types.AcquireStringBuffer()
 
 	for _, packet := range packets {
 		if buf, err := p.EncodePacket(packet, false); err != nil {
@@ -131,7 +130,9 @@ func (p *parserv4) EncodePayload(packets []*packet.Packet, _ ...bool) (types.Buf
 					return nil, err
 				}
 			}
-			if _, err := buf.WriteTo(enPayload); err != nil {
+			_, err := buf.WriteTo(enPayload)
+			types.ReleaseBuffer(buf)
+			if err != nil {
 				return nil, err
 			}
 		}
@@ -140,23 +141,21 @@ func (p *parserv4) EncodePayload(packets []*packet.Packet, _ ...bool) (types.Buf
 	return enPayload, nil
 }
 
+// The packets alias the content of data instead of copying it, data must not
+// be modified afterwards.
 func (p *parserv4) DecodePayload(data types.BufferInterface) (packets []*packet.Packet) {
-	scanner := bufio.NewScanner(data)
-	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
-		if atEOF && len(data) == 0 {
-			return 0, nil, nil
-		}
-		if i := bytes.IndexByte(data, SEPARATOR); i >= 0 {
-			return i + 1, data[0:i], nil
-		}
-		if atEOF {
-			return len(data), data, nil
+	payload := data.Next(data.Len())
+	for len(payload) > 0 {
+		var encoded []byte
+		if i := bytes.IndexByte(payload, SEPARATOR); i >= 0 {
+			encoded, payload = payload[:i], payload[i+1:]
+		} else {
+			encoded, payload = payload, nil
 		}
-		return 0, nil, nil
-	})
-	for scanner.Scan() {
-		if packet, err := p.DecodePacket(types.NewStringBuffer(scanner.Bytes())); err == nil {
+		if packet, err := p.DecodePacket(types.NewStringBuffer(encoded)); err == nil {
 			packets = append(packets, packet)
+		} else {
+			parser_log.Debug("ignoring undecodable packet: %v", err)
 		}
 	}
 
@@ -2,10 +2,12 @@ package parser
 
 import (
 	"bytes"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
 
+	"github.com/zishang520/engine.io/errors"
 	"github.com/zishang520/engine.io/packet"
 	"github.com/zishang520/engine.io/types"
 )
@@ -128,7 +130,7 @@ func TestParserv3(t *testing.T) {
 		if err != nil {
 			t.Fatal("Error with EncodePacket:", err)
 		}
-		check := []byte{0x00, 0x05, 0x08, 0xFF, 48, 116, 101, 115, 116, 195, 131, 194, 166, 195, 130, 194, 181, 195, 130, 194, 139, 195, 131, 194, 168, 195, 130, 194, 175, 195, 130, 194, 149, 195, 131, 194, 164, 195, 130, 194, 184, 195, 130, 194, 173, 195, 131, 194, 166, 195, 130, 194, 150, 195, 130, 194, 135, 195, 131, 194, 165, 195, 130, 194, 146, 195, 130, 194, 140, 195, 131, 194, 168, 195, 130, 194, 161, 195, 130, 194, 168, 195, 131, 194, 166, 195, 130, 194, 131, 195, 130, 194, 133, 195, 131, 194, 165, 195, 130, 194, 173, 195, 130, 194, 151, 195, 131, 194, 167, 195, 130, 194, 172, 195, 130, 194, 166, 195, 131, 194, 162, 195, 130, 194, 157, 195, 130, 194, 164, 195, 131, 194, 175, 195, 130, 194, 184, 195, 130, 194, 143, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 167, 195, 130, 194, 161, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 146, 195, 130, 194, 155, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 167, 195, 130, 194, 147, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 143, 195, 130, 194, 190, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 146, 195, 130, 194, 159}
+		check := []byte{0x00, 0x05, 0x08, 0xFF, 48, 116, 101, 115, 116, 230, 181, 139, 232, 175, 149, 228, 184, 173, 230, 150, 135, 229, 146, 140, 232, 161, 168, 230, 131, 133, 229, 173, 151, 231, 172, 166, 226, 157, 164, 239, 184, 143, 240, 159, 167, 161, 240, 159, 146, 155, 240, 159, 167, 147, 240, 159, 143, 190, 240, 159, 146, 159}
 		if b := data.Bytes(); !bytes.Equal(b, check) {
 			t.Fatalf(`encodeOneBinaryPacket value not as expected: %v, want match for %v`, b, check)
 		}
@@ -300,7 +302,7 @@ func TestParserv3(t *testing.T) {
 		if err != nil {
 			t.Fatal("Error with EncodePayload:", err)
 		}
-		check := []byte{1, 4, 255, 0, 65, 66, 67, 0, 5, 8, 255, 49, 116, 101, 115, 116, 195, 131, 194, 166, 195, 130, 194, 181, 195, 130, 194, 139, 195, 131, 194, 168, 195, 130, 194, 175, 195, 130, 194, 149, 195, 131, 194, 164, 195, 130, 194, 184, 195, 130, 194, 173, 195, 131, 194, 166, 195, 130, 194, 150, 195, 130, 194, 135, 195, 131, 194, 165, 195, 130, 194, 146, 195, 130, 194, 140, 195, 131, 194, 168, 195, 130, 194, 161, 195, 130, 194, 168, 195, 131, 194, 166, 195, 130, 194, 131, 195, 130, 194, 133, 195, 131, 194, 165, 195, 130, 194, 173, 195, 130, 194, 151, 195, 131, 194, 167, 195, 130, 194, 172, 195, 130, 194, 166, 195, 131, 194, 162, 195, 130, 194, 157, 195, 130, 194, 164, 195, 131, 194, 175, 195, 130, 194, 184, 195, 130, 194, 143, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 167, 195, 130, 194, 161, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 146, 195, 130, 194, 155, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 167, 195, 130, 194, 147, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 143, 195, 130, 194, 190, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 146, 195, 130, 194, 159}
+		check := []byte{1, 4, 255, 0, 65, 66, 67, 0, 5, 8, 255, 49, 116, 101, 115, 116, 230, 181, 139, 232, 175, 149, 228, 184, 173, 230, 150, 135, 229, 146, 140, 232, 161, 168, 230, 131, 133, 229, 173, 151, 231, 172, 166, 226, 157, 164, 239, 184, 143, 240, 159, 167, 161, 240, 159, 146, 155, 240, 159, 167, 147, 240, 159, 143, 190, 240, 159, 146, 159}
 
 		if b := data.Bytes(); !bytes.Equal(b, check) {
 			t.Fatalf(`DecodePacket *Packet.Data value not as expected: %v, want match for %v`, b, check)
@@ -368,7 +370,7 @@ func TestParserv3(t *testing.T) {
 	})
 
 	t.Run("DecodePayload", func(t *testing.T) {
-		packs := p.DecodePayload(types.NewBytesBuffer([]byte{1, 4, 255, 0, 65, 66, 67, 0, 5, 8, 255, 49, 116, 101, 115, 116, 195, 131, 194, 166, 195, 130, 194, 181, 195, 130, 194, 139, 195, 131, 194, 168, 195, 130, 194, 175, 195, 130, 194, 149, 195, 131, 194, 164, 195, 130, 194, 184, 195, 130, 194, 173, 195, 131, 194, 166, 195, 130, 194, 150, 195, 130, 194, 135, 195, 131, 194, 165, 195, 130, 194, 146, 195, 130, 194, 140, 195, 131, 194, 168, 195, 130, 194, 161, 195, 130, 194, 168, 195, 131, 194, 166, 195, 130, 194, 131, 195, 130, 194, 133, 195, 131, 194, 165, 195, 130, 194, 173, 195, 130, 194, 151, 195, 131, 194, 167, 195, 130, 194, 172, 195, 130, 194, 166, 195, 131, 194, 162, 195, 130, 194, 157, 195, 130, 194, 164, 195, 131, 194, 175, 195, 130, 194, 184, 195, 130, 194, 143, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 167, 195, 130, 194, 161, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 146, 195, 130, 194, 155, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 167, 195, 130, 194, 147, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 143, 195, 130, 194, 190, 195, 131, 194, 176, 195, 130, 194, 159, 195, 130, 194, 146, 195, 130, 194, 159}))
+		packs := p.DecodePayload(types.NewBytesBuffer([]byte{1, 4, 255, 0, 65, 66, 67, 0, 5, 8, 255, 49, 116, 101, 115, 116, 230, 181, 139, 232, 175, 149, 228, 184, 173, 230, 150, 135, 229, 146, 140, 232, 161, 168, 230, 131, 133, 229, 173, 151, 231, 172, 166, 226, 157, 164, 239, 184, 143, 240, 159, 167, 161, 240, 159, 146, 155, 240, 159, 167, 147, 240, 159, 143, 190, 240, 159, 146, 159}))
 
 		if l := len(packs); l != 2 {
 			t.Fatalf(`*len(packs) = %d, want match for %d`, l, 2)
@@ -750,3 +752,124 @@ func TestParserv4(t *testing.T) {
 	})
 
 }
+
+func benchmarkPackets() []*packet.Packet {
+	text := strings.Repeat("hello world ", 100)
+	binary := bytes.Repeat([]byte{0, 1, 2, 3}, 256)
+	return []*packet.Packet{
+		{Type: packet.MESSAGE, Data: types.NewStringBufferString(text)},
+		{Type: packet.MESSAGE, Data: types.NewBytesBuffer(binary)},
+		{Type: packet.PING},
+		{Type: packet.MESSAGE, Data: types.NewStringBufferString(text)},
+	}
+}
+
+func BenchmarkEncodePayload(b *testing.B) {
+	for _, p := range []Parser{Parserv3(), Parserv4()} {
+		b.Run(fmt.Sprintf("v%d", p.Protocol()), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				packets := benchmarkPackets()
+				b.StartTimer()
+				data, err := p.EncodePayload(packets)
+				if err != nil {
+					b.Fatal(err)
+				}
+				types.ReleaseBuffer(data)
+			}
+		})
+	}
+}
+
+func BenchmarkDecodePayload(b *testing.B) {
+	for _, p := range []Parser{Parserv3(), Parserv4()} {
+		payload, err := p.EncodePayload(benchmarkPackets())
+		if err != nil {
+			b.Fatal(err)
+		}
+		encoded := payload.String()
+		b.Run(fmt.Sprintf("v%d", p.Protocol()), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(encoded)))
+			for i := 0; i < b.N; i++ {
+				if packets := p.DecodePayload(types.NewStringBufferString(encoded)); len(packets) != 4 {
+					b.Fatalf("DecodePayload() decoded %d packets, want %d", len(packets), 4)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkDecodePacket(b *testing.B) {
+	frame := append([]byte{'4'}, strings.Repeat("hello world ", 1000)...)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(frame)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Parserv4().DecodePacket(types.NewStringBuffer(frame)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestPayloadDecoder(t *testing.T) {
+	decodeAll := func(payload string, limits PayloadLimits) (packets []*packet.Packet, err error) {
+		decoder := NewPayloadDecoder(strings.NewReader(payload), limits)
+		for {
+			packet, err := decoder.Next()
+			if err == io.EOF {
+				return packets, nil
+			}
+			if err != nil {
+				return packets, err
+			}
+			packets = append(packets, packet)
+		}
+	}
+
+	t.Run("Packets", func(t *testing.T) {
+		large := strings.Repeat("a", 100000)
+		packets, err := decodeAll("4hello\x1ebAQID\x1e2\x1e4"+large, PayloadLimits{})
+		if err != nil {
+			t.Fatal("Error with PayloadDecoder:", err)
+		}
+		if len(packets) != 4 {
+			t.Fatalf(`PayloadDecoder decoded %d packets, want match for %d`, len(packets), 4)
+		}
+		if data := packets[0].Data.(types.BufferInterface).String(); packets[0].Type != packet.MESSAGE || data != "hello" {
+			t.Fatalf(`PayloadDecoder decoded %s %q, want match for message "hello"`, packets[0].Type, data)
+		}
+		if data := packets[1].Data.(types.BufferInterface).Bytes(); !bytes.Equal(data, []byte{1, 2, 3}) {
+			t.Fatalf(`PayloadDecoder decoded %v, want match for %v`, data, []byte{1, 2, 3})
+		}
+		if packets[2].Type != packet.PING {
+			t.Fatalf(`PayloadDecoder decoded %s, want match for %s`, packets[2].Type, packet.PING)
+		}
+		if data := packets[3].Data.(types.BufferInterface).Len(); data != len(large) {
+			t.Fatalf(`PayloadDecoder decoded %d bytes, want match for %d`, data, len(large))
+		}
+	})
+
+	t.Run("Limits", func(t *testing.T) {
+		for _, test := range []struct {
+			payload string
+			limits  PayloadLimits
+			want    error
+			decoded int
+		}{
+			{"4hello\x1e4world", PayloadLimits{MaxPayloadSize: 10}, ErrPayloadTooLarge, 1},
+			{"4hello\x1e4" + strings.Repeat("a", 10000), PayloadLimits{MaxPacketSize: 100}, ErrPacketTooLarge, 1},
+			{"2\x1e2\x1e2", PayloadLimits{MaxPackets: 2}, ErrTooManyPackets, 2},
+			{"4hello\x1e9\x1e4world", PayloadLimits{}, ErrInvalidPacket, 1},
+			{"4hello\x1e\x1e4world", PayloadLimits{}, ErrInvalidPacket, 1},
+		} {
+			packets, err := decodeAll(test.payload, test.limits)
+			if !errors.Is(err, test.want) {
+				t.Fatalf(`PayloadDecoder error = %v, want match for %v`, err, test.want)
+			}
+			if len(packets) != test.decoded {
+				t.Fatalf(`PayloadDecoder decoded %d packets before %v, want match for %d`, len(packets), err, test.decoded)
+			}
+		}
+	})
+}
@@ -0,0 +1,86 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// The limits enforced by a PayloadDecoder, a zero value disables a limit.
+type PayloadLimits struct {
+	MaxPayloadSize int64 // bytes of the encoded payload
+	MaxPacketSize  int64 // bytes of an encoded packet
+	MaxPackets     int   // packets of the payload
+}
+
+// PayloadDecoder decodes the packets of a protocol v4 payload as they are read,
+// so that neither the whole payload nor a packet beyond the limits is held in
+// memory.
+type PayloadDecoder struct {
+	r      *bufio.Reader
+	limits PayloadLimits
+	read   int64
+	count  int
+	err    error
+}
+
+func NewPayloadDecoder(r io.Reader, limits PayloadLimits) *PayloadDecoder {
+	return &PayloadDecoder{r: bufio.NewReader(r), limits: limits}
+}
+
+// Next returns the next packet of the payload, or io.EOF once the payload is
+// consumed. The payload ends with the first error, which wraps
+// ErrPayloadTooLarge, ErrPacketTooLarge, ErrTooManyPackets or ErrInvalidPacket,
+// or else is the error of the reader.
+func (d *PayloadDecoder) Next() (*packet.Packet, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+
+	var encoded []byte
+	for {
+		chunk, err := d.r.ReadSlice(SEPARATOR)
+		d.read += int64(len(chunk))
+		if d.limits.MaxPayloadSize > 0 && d.read > d.limits.MaxPayloadSize {
+			return nil, d.fail(ErrPayloadTooLarge)
+		}
+		if err == nil {
+			chunk = chunk[:len(chunk)-1]
+		}
+		if d.limits.MaxPacketSize > 0 && int64(len(encoded)+len(chunk)) > d.limits.MaxPacketSize {
+			return nil, d.fail(ErrPacketTooLarge)
+		}
+		// the chunk is only valid until the next read
+		encoded = append(encoded, chunk...)
+
+		if err == io.EOF {
+			d.err = io.EOF
+			if len(encoded) == 0 {
+				return nil, io.EOF
+			}
+			break
+		}
+		if err == nil {
+			break
+		}
+		if err != bufio.ErrBufferFull {
+			return nil, d.fail(err)
+		}
+	}
+
+	if d.count++; d.limits.MaxPackets > 0 && d.count > d.limits.MaxPackets {
+		return nil, d.fail(ErrTooManyPackets)
+	}
+	packet, err := Parserv4().DecodePacket(types.NewStringBuffer(encoded))
+	if err != nil {
+		return nil, d.fail(ErrInvalidPacket.Wrap(err))
+	}
+	return packet, nil
+}
+
+func (d *PayloadDecoder) fail(err error) error {
+	d.err = err
+	return err
+}
@@ -1,10 +1,19 @@
 package parser
 
 import (
+	"github.com/zishang520/engine.io/log"
 	"github.com/zishang520/engine.io/packet"
 	"github.com/zishang520/engine.io/types"
 )
 
+var parser_log = log.NewLog("engine:parser")
+
+// Sets the logger receiving the records of the parsers, nil falls back to the
+// default one.
+func SetLogger(logger log.Logger) {
+	parser_log.SetLogger(logger)
+}
+
 type Parser interface {
 	Protocol() int
 	EncodePacket(*packet.Packet, bool, ...bool) (types.BufferInterface, error)
@@ -0,0 +1,212 @@
+package record
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/zishang520/engine.io/internal/eioclient"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// The client of a replay, it handles the heartbeat and passes the other packets
+// of the server to received.
+type client struct {
+	session   *Session
+	eio       *eioclient.Session
+	received  func(*packet.Packet)
+	closed    func() // called when the server ends the session
+	ws        *websocket.Conn
+	muws      sync.RWMutex
+	muwrite   sync.Mutex // the packets are written one request or frame at a time
+	muupgrade sync.Mutex // held while upgrading, the polls wait for it
+
+	ctx     context.Context
+	cancel  context.CancelFunc
+	closing int32 // set once the client closes the session
+	endOnce sync.Once
+}
+
+func newClient(rawURL string, session *Session, timeout time.Duration, received func(*packet.Packet), closed func()) *client {
+	return &client{
+		session: session,
+		eio: eioclient.NewSession(&eioclient.Config{
+			URL:      rawURL,
+			Protocol: session.Protocol,
+			Base64:   session.Query.Has("b64"),
+			Query:    session.Query,
+			Timeout:  timeout,
+		}),
+		received: received,
+		closed:   closed,
+	}
+}
+
+// Opens the session over the recorded transport.
+func (c *client) open(ctx context.Context) (err error) {
+	c.ctx, c.cancel = context.WithCancel(ctx)
+	defer func() {
+		if err != nil {
+			c.end(false)
+		}
+	}()
+
+	conn, packets, err := c.eio.Open(c.ctx, c.session.Transport)
+	if err != nil {
+		return err
+	}
+	c.ws = conn
+	for _, p := range packets {
+		c.handle(p)
+	}
+
+	if c.ws != nil {
+		go c.readWebSocket(c.ws)
+	} else {
+		go c.poll()
+	}
+	if c.session.Protocol == 3 {
+		// the clients ping in v3
+		go c.ping()
+	}
+	return nil
+}
+
+// Sends a packet over the current transport.
+func (c *client) send(p *packet.Packet) error {
+	c.muwrite.Lock()
+	defer c.muwrite.Unlock()
+
+	if ws := c.websocket(); ws != nil {
+		return c.eio.WriteFrame(ws, p)
+	}
+	return c.eio.Post(c.ctx, []*packet.Packet{p})
+}
+
+// Upgrades the polling session to a WebSocket.
+func (c *client) upgrade() error {
+	c.muupgrade.Lock()
+	defer c.muupgrade.Unlock()
+
+	if c.websocket() != nil {
+		return nil
+	}
+	conn, err := c.eio.Probe(c.ctx)
+	if err == nil {
+		c.muwrite.Lock()
+		err = c.eio.WriteFrame(conn, &packet.Packet{Type: packet.UPGRADE})
+		if err == nil {
+			c.muws.Lock()
+			c.ws = conn
+			c.muws.Unlock()
+		} else {
+			conn.Close()
+		}
+		c.muwrite.Unlock()
+	}
+	if err != nil {
+		return fmt.Errorf("upgrade failed: %w", err)
+	}
+	go c.readWebSocket(conn)
+	return nil
+}
+
+// Closes the session.
+func (c *client) close() {
+	if atomic.CompareAndSwapInt32(&c.closing, 0, 1) && c.ctx.Err() == nil {
+		c.send(&packet.Packet{Type: packet.CLOSE})
+	}
+	c.end(false)
+}
+
+// Ends the session, byServer reports whether the server ended it.
+func (c *client) end(byServer bool) {
+	c.endOnce.Do(func() {
+		c.cancel()
+		if ws := c.websocket(); ws != nil {
+			ws.Close()
+		}
+		if byServer && atomic.LoadInt32(&c.closing) == 0 {
+			c.closed()
+		}
+	})
+}
+
+func (c *client) websocket() *websocket.Conn {
+	c.muws.RLock()
+	defer c.muws.RUnlock()
+
+	return c.ws
+}
+
+// Handles a packet of the server.
+func (c *client) handle(p *packet.Packet) {
+	switch p.Type {
+	case packet.PING:
+		pong := &packet.Packet{Type: packet.PONG}
+		if s, ok := p.Data.(types.BufferInterface); ok && s.Len() > 0 {
+			pong.Data = types.NewStringBuffer(s.Bytes())
+		}
+		go c.send(pong)
+	case packet.CLOSE:
+		c.end(true)
+	default:
+		c.received(p)
+	}
+}
+
+// Pings the server every ping interval, in v3.
+func (c *client) ping() {
+	ticker := time.NewTicker(c.eio.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.send(&packet.Packet{Type: packet.PING})
+		case <-c.ctx.Done():
+			return
+		}
+	}
+}
+
+// Polls the server until the session ends or is upgraded.
+func (c *client) poll() {
+	for {
+		// waits for an upgrade in progress
+		c.muupgrade.Lock()
+		upgraded := c.websocket() != nil
+		c.muupgrade.Unlock()
+		if upgraded {
+			return
+		}
+
+		packets, err := c.eio.Poll(c.ctx)
+		if err != nil {
+			c.end(c.ctx.Err() == nil)
+			return
+		}
+		for _, p := range packets {
+			if p.Type == packet.CLOSE && c.websocket() != nil {
+				// the server closes the polling transport once upgraded
+				continue
+			}
+			c.handle(p)
+		}
+	}
+}
+
+func (c *client) readWebSocket(conn *websocket.Conn) {
+	for {
+		p, err := c.eio.ReadFrame(conn)
+		if err != nil {
+			c.end(c.ctx.Err() == nil)
+			return
+		}
+		c.handle(p)
+	}
+}
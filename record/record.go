@@ -0,0 +1,167 @@
+// Package record captures the traffic of an engine server as a session log, so
+// that real sessions can be kept as regression tests, and replays it.
+//
+// The log is made of JSON lines, one Entry each, in their order of occurrence:
+//
+//	{"time":"…","sid":"…","event":"open","transport":"polling","protocol":4,"query":"b64=1"}
+//	{"time":"…","sid":"…","event":"packet","direction":"in","transport":"polling","type":"message","data":"hello"}
+//	{"time":"…","sid":"…","event":"packet","direction":"out","transport":"polling","type":"message","data":"AQI=","binary":true}
+//	{"time":"…","sid":"…","event":"http","transport":"polling","method":"GET"}
+//	{"time":"…","sid":"…","event":"upgrade","transport":"websocket"}
+//	{"time":"…","sid":"…","event":"close","reason":"transport close"}
+//
+// A recorded client is replayed against a server with ReplayClient, and a
+// recorded server against a client with ReplayServer.
+package record
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// Events of the log.
+const (
+	EVENT_OPEN    = "open"    // the handshake of a session
+	EVENT_PACKET  = "packet"  // a packet received or created by the server
+	EVENT_HTTP    = "http"    // a polling request of a session
+	EVENT_UPGRADE = "upgrade" // the session was upgraded to Transport
+	EVENT_CLOSE   = "close"   // the session was closed for Reason
+)
+
+// Directions of a packet.
+const (
+	IN  = "in"  // from the client to the server
+	OUT = "out" // from the server to the client
+)
+
+// Entry is a line of the log.
+type Entry struct {
+	Time      time.Time   `json:"time"`
+	Sid       string      `json:"sid"`
+	Event     string      `json:"event"`
+	Direction string      `json:"direction,omitempty"`
+	Transport string      `json:"transport,omitempty"`
+	Type      packet.Type `json:"type,omitempty"`
+	Data      string      `json:"data,omitempty"`
+	Binary    bool        `json:"binary,omitempty"` // Data is base64 encoded
+
+	Protocol int    `json:"protocol,omitempty"` // of an open entry
+	Query    string `json:"query,omitempty"`    // of an open entry, without the parameters of the engine
+	Method   string `json:"method,omitempty"`   // of an http entry
+	Reason   string `json:"reason,omitempty"`   // of a close entry
+}
+
+// Returns the packet of a packet entry.
+func (e *Entry) Packet() (*packet.Packet, error) {
+	p := &packet.Packet{Type: e.Type}
+	if e.Binary {
+		data, err := base64.StdEncoding.DecodeString(e.Data)
+		if err != nil {
+			return nil, fmt.Errorf("invalid binary data: %w", err)
+		}
+		p.Data = types.NewBytesBuffer(data)
+	} else if e.Data != "" || e.Type == packet.MESSAGE {
+		p.Data = types.NewStringBufferString(e.Data)
+	}
+	return p, nil
+}
+
+// Returns a packet entry, the data of p is read without being consumed.
+func packetEntry(direction string, p *packet.Packet) *Entry {
+	e := &Entry{Event: EVENT_PACKET, Direction: direction, Type: p.Type}
+	var data []byte
+	switch v := p.Data.(type) {
+	case nil:
+		return e
+	case *types.StringBuffer:
+		data = v.Bytes()
+	case types.BufferInterface:
+		data, e.Binary = v.Bytes(), true
+	case *strings.Reader:
+		data, _ = io.ReadAll(v)
+		p.Data = types.NewStringBuffer(data)
+	default:
+		data, _ = io.ReadAll(v)
+		p.Data, e.Binary = types.NewBytesBuffer(data), true
+	}
+	if e.Binary {
+		e.Data = base64.StdEncoding.EncodeToString(data)
+	} else {
+		e.Data = string(data)
+	}
+	return e
+}
+
+// Session is the log of a session.
+type Session struct {
+	Id        string
+	Transport string // of the handshake
+	Protocol  int
+	Query     url.Values // of the handshake, without the parameters of the engine
+	Entries   []*Entry   // starting with the open entry
+}
+
+// Returns the message entries of a direction, the other packets are handled by
+// the engine itself and depend on its settings rather than on the application.
+func (s *Session) Messages(direction string) (entries []*Entry) {
+	for _, e := range s.Entries {
+		if e.Event == EVENT_PACKET && e.Direction == direction && e.Type == packet.MESSAGE {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// Returns the close entry of the session, nil when it was not closed.
+func (s *Session) Closed() *Entry {
+	for i := len(s.Entries) - 1; i > 0; i-- {
+		if s.Entries[i].Event == EVENT_CLOSE {
+			return s.Entries[i]
+		}
+	}
+	return nil
+}
+
+// Reads a log and returns its sessions in the order of their handshakes, the
+// entries of the sessions opened before the recording started are dropped.
+func ReadSessions(r io.Reader) ([]*Session, error) {
+	sessions := []*Session{}
+	bySid := map[string]*Session{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		e := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), e); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if e.Event == EVENT_OPEN {
+			query, err := url.ParseQuery(e.Query)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid query: %w", line, err)
+			}
+			s := &Session{Id: e.Sid, Transport: e.Transport, Protocol: e.Protocol, Query: query}
+			sessions = append(sessions, s)
+			bySid[e.Sid] = s
+		}
+		if s, ok := bySid[e.Sid]; ok {
+			s.Entries = append(s.Entries, e)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
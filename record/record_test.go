@@ -0,0 +1,271 @@
+package record
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zishang520/engine.io/config"
+	"github.com/zishang520/engine.io/conformance"
+	"github.com/zishang520/engine.io/engine"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/types"
+)
+
+// Starts a server, its sockets send back the messages when echo is set.
+func start(t *testing.T, echo bool) (engine.Server, string) {
+	opts := config.DefaultServerOptions()
+	opts.SetAllowEIO3(true)
+	var server engine.Server
+	if echo {
+		server = conformance.EngineServer(opts).(engine.Server)
+	} else {
+		server = engine.NewServer(opts)
+	}
+	srv := httptest.NewServer(server)
+	t.Cleanup(func() {
+		server.Close()
+		srv.Close()
+	})
+	return server, srv.URL + "/engine.io/"
+}
+
+func readSession(t *testing.T, log string) *Session {
+	sessions, err := ReadSessions(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 {
+		t.Fatalf("ReadSessions() = %d sessions, want match for %d", len(sessions), 1)
+	}
+	return sessions[0]
+}
+
+// A session over WebSocket sending a text and a binary message, closed by the
+// client.
+const websocketLog = `{"time":"2026-01-01T00:00:00Z","sid":"s1","event":"open","transport":"websocket","protocol":3,"query":"b64=1"}
+{"time":"2026-01-01T00:00:00.010Z","sid":"s1","event":"packet","direction":"in","transport":"websocket","type":"message","data":"hello"}
+{"time":"2026-01-01T00:00:00.011Z","sid":"s1","event":"packet","direction":"out","transport":"websocket","type":"message","data":"hello"}
+{"time":"2026-01-01T00:00:00.020Z","sid":"s1","event":"packet","direction":"in","transport":"websocket","type":"message","data":"AQI=","binary":true}
+{"time":"2026-01-01T00:00:00.021Z","sid":"s1","event":"packet","direction":"out","transport":"websocket","type":"message","data":"AQI=","binary":true}
+{"time":"2026-01-01T00:00:00.030Z","sid":"s1","event":"close","reason":"transport close"}
+`
+
+func TestRecord(t *testing.T) {
+	server, url := start(t, true)
+	log := new(bytes.Buffer)
+	recorder := Record(server, log)
+	closed := make(chan struct{})
+	engine.OnConnection(server, func(socket engine.Socket) {
+		engine.OnClose(socket, func(engine.CloseReason, error) { close(closed) })
+	})
+
+	c := conformance.NewClient(t, url, 4, map[string][]string{"token": {"abc"}})
+	c.Open()
+	c.Send(&packet.Packet{Type: packet.MESSAGE, Data: types.NewStringBufferString("hello")}, &packet.Packet{Type: packet.MESSAGE, Data: types.NewBytesBuffer([]byte{1, 2})})
+	if packets := c.Receive(2); len(packets) != 2 {
+		t.Fatalf("received %d packets, want match for %d", len(packets), 2)
+	}
+	c.SendAndDrop(&packet.Packet{Type: packet.CLOSE})
+	select {
+	case <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the session was not closed")
+	}
+	recorder.Stop()
+	if err := recorder.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	session := readSession(t, log.String())
+	if session.Id != c.Sid || session.Transport != "polling" || session.Protocol != 4 || session.Query.Encode() != "token=abc" {
+		t.Fatalf("ReadSessions() = %+v, want match for the session %s over polling", session, c.Sid)
+	}
+	for _, direction := range []string{IN, OUT} {
+		messages := session.Messages(direction)
+		if len(messages) != 2 || messages[0].Data != "hello" || messages[1].Data != "AQI=" || !messages[1].Binary {
+			t.Fatalf("*Session.Messages(%q) = %v, want match for hello and binary AQI=", direction, messages)
+		}
+	}
+	if closed := session.Closed(); closed == nil || closed.Reason != string(engine.CLOSE_TRANSPORT_CLOSE) {
+		t.Fatalf("*Session.Closed() = %+v, want match for %q", closed, engine.CLOSE_TRANSPORT_CLOSE)
+	}
+	requests := 0
+	for _, e := range session.Entries {
+		if e.Event == EVENT_HTTP {
+			requests++
+		}
+	}
+	if requests == 0 {
+		t.Fatalf("the log holds no polling request:\n%s", log)
+	}
+
+	// the echo shows that the recorder leaves the data of the packets to the listeners
+	t.Run("Replay", func(t *testing.T) {
+		_, url := start(t, true)
+		if result := ReplayClient(context.Background(), url, session, &Options{Speed: 10}); !result.Ok() {
+			t.Fatalf("ReplayClient() = %v %v, want no divergence", result.Err, result.Divergences)
+		}
+	})
+}
+
+func TestRecorderStop(t *testing.T) {
+	server, url := start(t, false)
+	connected := make(chan string, 1)
+	engine.OnConnection(server, func(socket engine.Socket) { connected <- socket.Id() })
+
+	log := new(bytes.Buffer)
+	recorder := Record(server, log)
+	recorder.Stop()
+	recorder.Stop()
+	if n := server.ListenerCount(engine.EVENT_CONNECTION); n != 1 {
+		t.Fatalf("ListenerCount() = %d after Stop(), want match for %d", n, 1)
+	}
+
+	c := conformance.NewClient(t, url, 4, nil)
+	c.Open()
+	select {
+	case id := <-connected:
+		if id != c.Sid {
+			t.Fatalf("the connection listener received %q, want match for %q", id, c.Sid)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the connection listener of the app was removed by Stop()")
+	}
+	if log.Len() != 0 {
+		t.Fatalf("the stopped recorder wrote:\n%s", log)
+	}
+}
+
+func TestReplayClient(t *testing.T) {
+	session := readSession(t, websocketLog)
+
+	t.Run("WebSocket", func(t *testing.T) {
+		_, url := start(t, true)
+		result := ReplayClient(context.Background(), url, session, nil)
+		if !result.Ok() || result.Sent != 2 || len(result.Received) != 2 {
+			t.Fatalf("ReplayClient() = %+v, want match for 2 messages sent and received", result)
+		}
+	})
+
+	t.Run("Upgrade", func(t *testing.T) {
+		upgraded := readSession(t, strings.Replace(websocketLog, `"transport":"websocket","protocol":3`, `"transport":"polling","protocol":4`, 1)+
+			`{"time":"2026-01-01T00:00:00.001Z","sid":"s1","event":"upgrade","transport":"websocket"}`)
+		// the upgrade was recorded first
+		upgraded.Entries = append(upgraded.Entries[:1], append(upgraded.Entries[len(upgraded.Entries)-1:], upgraded.Entries[1:len(upgraded.Entries)-1]...)...)
+
+		_, url := start(t, true)
+		if result := ReplayClient(context.Background(), url, upgraded, &Options{Speed: 0}); !result.Ok() {
+			t.Fatalf("ReplayClient() = %v %v, want no divergence", result.Err, result.Divergences)
+		}
+	})
+
+	t.Run("Divergences", func(t *testing.T) {
+		// a server which answered in upper case
+		upper := readSession(t, strings.Replace(websocketLog, `"direction":"out","transport":"websocket","type":"message","data":"hello"`, `"direction":"out","transport":"websocket","type":"message","data":"HELLO"`, 1))
+		_, url := start(t, true)
+		result := ReplayClient(context.Background(), url, upper, &Options{Speed: -1, Timeout: time.Second})
+		if result.Err != nil || len(result.Divergences) != 1 {
+			t.Fatalf("ReplayClient() = %v %v, want match for 1 divergence", result.Err, result.Divergences)
+		}
+		if d := result.Divergences[0].String(); d != `message 0: want "HELLO", got "hello"` {
+			t.Fatalf("*Divergence.String() = %q, want match for %q", d, `message 0: want "HELLO", got "hello"`)
+		}
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		// sends nothing back
+		_, url := start(t, false)
+		result := ReplayClient(context.Background(), url, session, &Options{Speed: -1, Timeout: 100 * time.Millisecond})
+		if result.Err != nil || len(result.Divergences) != 2 || result.Divergences[1].Got != nil {
+			t.Fatalf("ReplayClient() = %v %v, want match for 2 missing messages", result.Err, result.Divergences)
+		}
+	})
+
+	t.Run("Rejected", func(t *testing.T) {
+		opts := config.DefaultServerOptions()
+		server := engine.NewServer(opts)
+		srv := httptest.NewServer(server)
+		defer srv.Close()
+		defer server.Close()
+
+		// v3 is disabled by default
+		polling := readSession(t, strings.Replace(websocketLog, `"transport":"websocket","protocol":3`, `"transport":"polling","protocol":3`, 1))
+		if result := ReplayClient(context.Background(), srv.URL+"/engine.io/", polling, nil); result.Err == nil || !strings.Contains(result.Err.Error(), "HTTP 400") {
+			t.Fatalf("ReplayClient() = %v, want match for HTTP 400", result.Err)
+		}
+	})
+}
+
+func TestReplayServer(t *testing.T) {
+	session := readSession(t, websocketLog)
+
+	t.Run("Replay", func(t *testing.T) {
+		server, url := start(t, false)
+		replay := ReplayServer(server, session, nil)
+
+		// the recorded client against the recorded server
+		if result := ReplayClient(context.Background(), url, session, nil); !result.Ok() {
+			t.Fatalf("ReplayClient() = %v %v, want no divergence", result.Err, result.Divergences)
+		}
+		if result := replay.Wait(context.Background()); !result.Ok() || result.Sent != 2 {
+			t.Fatalf("*ServerReplay.Wait() = %v %v, want match for 2 messages sent", result.Err, result.Divergences)
+		}
+	})
+
+	t.Run("ForcedClose", func(t *testing.T) {
+		forced := readSession(t, strings.Replace(websocketLog, "transport close", "forced close", 1))
+		server, url := start(t, false)
+		replay := ReplayServer(server, forced, &Options{Speed: 0})
+
+		if result := ReplayClient(context.Background(), url, forced, nil); !result.Ok() {
+			t.Fatalf("ReplayClient() = %v %v, want no divergence", result.Err, result.Divergences)
+		}
+		if result := replay.Wait(context.Background()); !result.Ok() {
+			t.Fatalf("*ServerReplay.Wait() = %v %v, want no divergence", result.Err, result.Divergences)
+		}
+	})
+
+	t.Run("Divergences", func(t *testing.T) {
+		server, url := start(t, false)
+		replay := ReplayServer(server, session, &Options{Speed: 0, Timeout: 200 * time.Millisecond})
+
+		// a client sending another message
+		other := readSession(t, strings.Replace(websocketLog, `"direction":"in","transport":"websocket","type":"message","data":"hello"`, `"direction":"in","transport":"websocket","type":"message","data":"bye"`, 1))
+		ReplayClient(context.Background(), url, other, &Options{Speed: 0, Timeout: 200 * time.Millisecond})
+		result := replay.Wait(context.Background())
+		if result.Err != nil || len(result.Divergences) != 1 || result.Divergences[0].Got.Data != "bye" {
+			t.Fatalf("*ServerReplay.Wait() = %v %v, want match for 1 divergence", result.Err, result.Divergences)
+		}
+	})
+
+	t.Run("NoClient", func(t *testing.T) {
+		server, _ := start(t, false)
+		replay := ReplayServer(server, session, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		if result := replay.Wait(ctx); result.Err != context.DeadlineExceeded {
+			t.Fatalf("*ServerReplay.Wait() = %v, want match for %v", result.Err, context.DeadlineExceeded)
+		}
+	})
+}
+
+func TestOptions(t *testing.T) {
+	for _, test := range []struct {
+		opts *Options
+		want float64
+	}{
+		{nil, 1},
+		{&Options{}, 1},
+		{&Options{Speed: 10}, 10},
+		{&Options{Speed: -1}, 0},
+	} {
+		if speed := test.opts.speed(); speed != test.want {
+			t.Fatalf("*Options.speed() = %v for %+v, want match for %v", speed, test.opts, test.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package record
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"sync"
+
+	"github.com/zishang520/engine.io/engine"
+	"github.com/zishang520/engine.io/events"
+	"github.com/zishang520/engine.io/log"
+	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/transports"
+	"github.com/zishang520/engine.io/types"
+	"github.com/zishang520/engine.io/utils"
+)
+
+var record_log = log.NewLog("engine:record")
+
+// The query parameters of the engine, left out of the open entries. The b64
+// parameter is kept as it changes the encoding of the binary packets.
+var engineParameters = []string{"EIO", "transport", "sid", "t", "j"}
+
+// Recorder writes the traffic of a server to a log: the handshakes, the packets
+// received and created by its sockets, their polling requests, upgrades and
+// closes. The packets sent before the "connection" event, the open packet and
+// the initial packet, are stood for by the open entry.
+type Recorder struct {
+	server   engine.Server
+	enc      *json.Encoder
+	err      error
+	stopped  bool
+	mu       sync.Mutex
+	handlers map[events.EventName]events.Listener
+}
+
+// Records the sessions opened from now on by server to w.
+func Record(server engine.Server, w io.Writer) *Recorder {
+	r := &Recorder{server: server, enc: json.NewEncoder(w)}
+	r.handlers = map[events.EventName]events.Listener{
+		engine.EVENT_CONNECTION: engine.OnConnection(server, r.onConnection),
+		engine.EVENT_HEADERS:    engine.OnHeaders(server, r.onHeaders),
+	}
+	return r
+}
+
+// Stops recording, the log is complete once Stop returns.
+func (r *Recorder) Stop() {
+	for name, listener := range r.handlers {
+		r.server.RemoveListener(name, listener)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.stopped = true
+}
+
+// Returns the first error writing the log, the recording stops on error.
+func (r *Recorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.err
+}
+
+func (r *Recorder) write(sid string, e *Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.stopped {
+		return
+	}
+	e.Time, e.Sid = r.server.Opts().Clock().Now(), sid
+	if err := r.enc.Encode(e); err != nil {
+		record_log.Debug("writing the log failed: %v", err)
+		r.err, r.stopped = err, true
+	}
+}
+
+func (r *Recorder) onConnection(socket engine.Socket) {
+	query := url.Values(socket.Request().Query().All())
+	for _, name := range engineParameters {
+		query.Del(name)
+	}
+	r.write(socket.Id(), &Entry{
+		Event:     EVENT_OPEN,
+		Transport: socket.Transport().Name(),
+		Protocol:  socket.Protocol(),
+		Query:     query.Encode(),
+	})
+
+	engine.OnPacket(socket, func(p *packet.Packet) {
+		e := packetEntry(IN, p)
+		e.Transport = socket.Transport().Name()
+		r.write(socket.Id(), e)
+	})
+	engine.OnPacketCreate(socket, func(p *packet.Packet) {
+		e := packetEntry(OUT, p)
+		e.Transport = socket.Transport().Name()
+		r.write(socket.Id(), e)
+	})
+	engine.OnUpgrade(socket, func(transport transports.Transport) {
+		r.write(socket.Id(), &Entry{Event: EVENT_UPGRADE, Transport: transport.Name()})
+	})
+	engine.OnClose(socket, func(reason engine.CloseReason, _ error) {
+		r.write(socket.Id(), &Entry{Event: EVENT_CLOSE, Reason: string(reason)})
+	})
+}
+
+// Records the polling requests of the open sessions, the handshake request is
+// recorded as the open entry.
+func (r *Recorder) onHeaders(_ *utils.ParameterBag, ctx *types.HttpContext) {
+	if sid := ctx.Query().Peek("sid"); sid != "" {
+		r.write(sid, &Entry{Event: EVENT_HTTP, Transport: "polling", Method: ctx.Method()})
+	}
+}
@@ -0,0 +1,361 @@
+package record
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zishang520/engine.io/engine"
+	"github.com/zishang520/engine.io/events"
+	"github.com/zishang520/engine.io/packet"
+)
+
+// Options of a replay.
+type Options struct {
+	// The pace of the replay: 1 replays at the original pace, 10 ten times as
+	// fast and a negative value as fast as possible. 1 by default.
+	Speed float64
+
+	// How long to wait for the messages of the other side recorded before the
+	// next one is sent, and for the session to end. 5s by default.
+	Timeout time.Duration
+}
+
+func (o *Options) speed() float64 {
+	if o == nil || o.Speed == 0 {
+		return 1
+	}
+	if o.Speed < 0 {
+		return 0
+	}
+	return o.Speed
+}
+
+func (o *Options) timeout() time.Duration {
+	if o == nil || o.Timeout <= 0 {
+		return 5 * time.Second
+	}
+	return o.Timeout
+}
+
+// Divergence is a difference between the recorded session and its replay.
+type Divergence struct {
+	Index int    // of the message among the compared ones, -1 for the end of the session
+	Want  *Entry // nil when Got was not recorded
+	Got   *Entry // nil when Want was not replayed
+}
+
+func (d *Divergence) String() string {
+	what := fmt.Sprintf("message %d", d.Index)
+	if d.Index < 0 {
+		what = "close"
+	}
+	return fmt.Sprintf("%s: want %s, got %s", what, describe(d.Want), describe(d.Got))
+}
+
+func describe(e *Entry) string {
+	switch {
+	case e == nil:
+		return "none"
+	case e.Event == EVENT_CLOSE:
+		return fmt.Sprintf("close (%s)", e.Reason)
+	case e.Binary:
+		return fmt.Sprintf("binary %q", e.Data)
+	}
+	return fmt.Sprintf("%q", e.Data)
+}
+
+// Result is the outcome of a replay.
+type Result struct {
+	Sent        int      // the messages sent
+	Received    []*Entry // the messages received
+	Divergences []*Divergence
+	Err         error // why the replay did not complete, the divergences are partial then
+}
+
+// Reports whether the replay completed without divergences.
+func (r *Result) Ok() bool {
+	return r.Err == nil && len(r.Divergences) == 0
+}
+
+// Returns the divergences of the messages received.
+func compare(want []*Entry, got []*Entry) (divergences []*Divergence) {
+	for i := 0; i < len(want) || i < len(got); i++ {
+		d := &Divergence{Index: i}
+		if i < len(want) {
+			d.Want = want[i]
+		}
+		if i < len(got) {
+			d.Got = got[i]
+		}
+		if d.Want == nil || d.Got == nil || d.Want.Type != d.Got.Type || d.Want.Binary != d.Got.Binary || d.Want.Data != d.Got.Data {
+			divergences = append(divergences, d)
+		}
+	}
+	return divergences
+}
+
+// A replay of one side of a session, the messages of the other side are
+// received and compared with the recorded ones.
+type replay struct {
+	session *Session
+	opts    *Options
+	side    string // the direction of the packets replayed
+
+	want     []*Entry
+	got      []*Entry
+	ended    bool // the other side ended the session
+	changed  chan struct{}
+	mu       sync.Mutex
+	finished chan struct{}
+	result   *Result
+}
+
+func newReplay(session *Session, opts *Options, side string) *replay {
+	other := OUT
+	if side == OUT {
+		other = IN
+	}
+	return &replay{
+		session:  session,
+		opts:     opts,
+		side:     side,
+		want:     session.Messages(other),
+		changed:  make(chan struct{}),
+		finished: make(chan struct{}),
+		result:   &Result{},
+	}
+}
+
+// Called with a message of the other side.
+func (r *replay) receive(p *packet.Packet) {
+	if p.Type != packet.MESSAGE {
+		return
+	}
+	e := packetEntry("", p)
+	e.Direction = IN
+	if r.side == IN {
+		e.Direction = OUT
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.got = append(r.got, e)
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Called once the other side ended the session.
+func (r *replay) end() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.ended {
+		r.ended = true
+		close(r.changed)
+		r.changed = make(chan struct{})
+	}
+}
+
+// Waits until n messages are received, the session ends or the timeout.
+func (r *replay) wait(ctx context.Context, n int, ended bool) {
+	timer := time.NewTimer(r.opts.timeout())
+	defer timer.Stop()
+
+	for {
+		r.mu.Lock()
+		done, changed := r.ended || (!ended && len(r.got) >= n), r.changed
+		r.mu.Unlock()
+		if done {
+			return
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Runs the entries of the replayed side at their recorded pace with act, each
+// one once the messages of the other side recorded before it are received.
+func (r *replay) run(ctx context.Context, act func(*Entry) error) {
+	defer close(r.finished)
+
+	start, recorded := time.Now(), r.session.Entries[0].Time
+	expected := 0
+	for _, e := range r.session.Entries[1:] {
+		message := e.Event == EVENT_PACKET && e.Type == packet.MESSAGE
+		switch {
+		case message && e.Direction != r.side:
+			expected++
+			continue
+		case message:
+		case e.Event == EVENT_UPGRADE && r.side == IN:
+		case e.Event == EVENT_CLOSE && r.closedBy(e) == r.side:
+		default:
+			// driven by the engine or by the other side
+			continue
+		}
+		r.wait(ctx, expected, false)
+		if speed := r.opts.speed(); speed > 0 {
+			at := start.Add(time.Duration(float64(e.Time.Sub(recorded)) / speed))
+			select {
+			case <-time.After(time.Until(at)):
+			case <-ctx.Done():
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			r.result.Err = err
+			break
+		}
+		if err := act(e); err != nil {
+			r.result.Err = err
+			break
+		}
+		if e.Event == EVENT_PACKET {
+			r.result.Sent++
+		}
+	}
+	if r.result.Err == nil {
+		r.wait(ctx, len(r.want), false)
+		if closed := r.session.Closed(); closed != nil && r.closedBy(closed) != r.side {
+			r.wait(ctx, 0, true)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.result.Received = r.got
+	r.result.Divergences = compare(r.want, r.got)
+	closed := r.session.Closed()
+	switch {
+	case closed != nil && r.closedBy(closed) != r.side && !r.ended:
+		r.result.Divergences = append(r.result.Divergences, &Divergence{Index: -1, Want: closed})
+	case r.ended && (closed == nil || r.closedBy(closed) == r.side):
+		closer := "server"
+		if r.side == OUT {
+			closer = "client"
+		}
+		r.result.Divergences = append(r.result.Divergences, &Divergence{Index: -1, Want: closed, Got: &Entry{Event: EVENT_CLOSE, Reason: "closed by the " + closer}})
+	}
+}
+
+// Returns the side which ended a session, the server only ends it on purpose,
+// the other reasons are replayed as a close of the client.
+func (r *replay) closedBy(closed *Entry) string {
+	if closed.Reason == string(engine.CLOSE_FORCED_CLOSE) {
+		return OUT
+	}
+	return IN
+}
+
+// Replays the client of a recorded session against the server at rawURL, such
+// as http://localhost:3000/engine.io/, and compares the messages it sends with
+// the recorded ones. The session is opened over the recorded transport with the
+// recorded query and upgraded when it was, the heartbeat is handled as by any
+// client.
+func ReplayClient(ctx context.Context, rawURL string, session *Session, opts *Options) *Result {
+	r := newReplay(session, opts, IN)
+	c := newClient(rawURL, session, opts.timeout(), r.receive, r.end)
+	if err := c.open(ctx); err != nil {
+		r.result.Err = err
+		return r.result
+	}
+	defer c.close()
+
+	go r.run(ctx, func(e *Entry) error {
+		switch e.Event {
+		case EVENT_UPGRADE:
+			return c.upgrade()
+		case EVENT_CLOSE:
+			c.close()
+			return nil
+		}
+		p, err := e.Packet()
+		if err != nil {
+			return err
+		}
+		return c.send(p)
+	})
+	<-r.finished
+	return r.result
+}
+
+// ServerReplay replays the server of a recorded session to the next client of
+// a server.
+type ServerReplay struct {
+	*replay
+
+	server   engine.Server
+	listener events.Listener
+	once     sync.Once
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// Replays the server of a recorded session to the next client connected to
+// server: the recorded messages are sent to it and the messages it sends are
+// compared with the recorded ones. The server handles the handshake, the
+// heartbeat and the upgrades itself according to its options, a recorded
+// forced close is replayed by closing the socket.
+func ReplayServer(server engine.Server, session *Session, opts *Options) *ServerReplay {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &ServerReplay{replay: newReplay(session, opts, OUT), server: server, ctx: ctx, cancel: cancel}
+	r.listener = func(args ...any) {
+		if socket, ok := args[0].(engine.Socket); ok {
+			r.once.Do(func() {
+				server.RemoveListener(engine.EVENT_CONNECTION, r.listener)
+				r.start(socket)
+			})
+		}
+	}
+	server.On(engine.EVENT_CONNECTION, r.listener)
+	return r
+}
+
+func (r *ServerReplay) start(socket engine.Socket) {
+	engine.OnPacket(socket, r.receive)
+	engine.OnClose(socket, func(reason engine.CloseReason, _ error) {
+		if reason != engine.CLOSE_FORCED_CLOSE {
+			r.end()
+		}
+	})
+	go r.run(r.ctx, func(e *Entry) error {
+		if e.Event == EVENT_CLOSE {
+			socket.Close(false)
+			return nil
+		}
+		p, err := e.Packet()
+		if err != nil {
+			return err
+		}
+		socket.Send(p.Data, nil, nil)
+		return nil
+	})
+}
+
+// Waits for the replay to complete, ctx bounds the wait for a client to
+// connect as well.
+func (r *ServerReplay) Wait(ctx context.Context) *Result {
+	select {
+	case <-r.finished:
+		return r.result
+	case <-ctx.Done():
+	}
+
+	// no client connected
+	r.once.Do(func() {
+		r.server.RemoveListener(engine.EVENT_CONNECTION, r.listener)
+		r.result.Err = ctx.Err()
+		close(r.finished)
+	})
+	r.cancel()
+	<-r.finished
+	return r.result
+}
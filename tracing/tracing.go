@@ -0,0 +1,158 @@
+// Package tracing provides the hooks used to trace handshakes, upgrades and the
+// packet flow of the engine, the span context is propagated from the W3C
+// `traceparent` request header.
+//
+// Inbound messages are traced as well, the context of the message span is
+// passed to the "message" and "data" listeners of a socket as second argument.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+)
+
+// Attributes of a span.
+type Attributes map[string]any
+
+// SpanContext identifies a span across process boundaries.
+type SpanContext struct {
+	TraceId    string // 32 lowercase hex characters
+	SpanId     string // 16 lowercase hex characters
+	Sampled    bool
+	TraceState string
+}
+
+// Reports whether the span context carries a trace and a span id.
+func (sc SpanContext) IsValid() bool {
+	return isHex(sc.TraceId, 32) && isHex(sc.SpanId, 16)
+}
+
+// Returns a span context for a new child span, a new trace is started when sc
+// is not valid.
+func (sc SpanContext) Child() SpanContext {
+	child := SpanContext{TraceId: sc.TraceId, Sampled: sc.Sampled, TraceState: sc.TraceState}
+	if !sc.IsValid() {
+		child = SpanContext{TraceId: randomHex(16), Sampled: true}
+	}
+	child.SpanId = randomHex(8)
+	return child
+}
+
+// Formats the span context as a `traceparent` header value.
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceId + "-" + sc.SpanId + "-" + flags
+}
+
+// Parses a `traceparent` header value.
+func ParseTraceparent(traceparent string, tracestate string) (SpanContext, bool) {
+	parts := strings.Split(strings.TrimSpace(traceparent), "-")
+	if len(parts) < 4 || !isHex(parts[0], 2) || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return SpanContext{}, false
+	}
+	if !isHex(parts[3], 2) {
+		return SpanContext{}, false
+	}
+	flags, _ := hex.DecodeString(parts[3])
+	sc := SpanContext{
+		TraceId:    parts[1],
+		SpanId:     parts[2],
+		Sampled:    flags[0]&1 == 1,
+		TraceState: tracestate,
+	}
+	if !sc.IsValid() {
+		return SpanContext{}, false
+	}
+	return sc, true
+}
+
+// Span is a traced operation.
+type Span interface {
+	SpanContext() SpanContext
+
+	SetAttributes(Attributes)
+
+	// Ends the span, err is nil when the operation succeeded.
+	End(error)
+}
+
+// Tracer starts spans, implement it to forward them to your own tracing system.
+type Tracer interface {
+	// Starts a span, its parent, if any, is SpanContextFromContext(ctx).
+	Start(ctx context.Context, name string, attributes Attributes) Span
+}
+
+type noop struct{}
+
+type noopSpan struct {
+	sc SpanContext
+}
+
+func (noop) Start(ctx context.Context, _ string, _ Attributes) Span {
+	return &noopSpan{sc: SpanContextFromContext(ctx)}
+}
+
+func (s *noopSpan) SpanContext() SpanContext { return s.sc }
+func (*noopSpan) SetAttributes(Attributes)   {}
+func (*noopSpan) End(error)                  {}
+
+// Noop is a Tracer which records nothing, it keeps the parent span context so
+// that an incoming trace is still propagated.
+var Noop Tracer = noop{}
+
+type spanContextKey struct{}
+
+// Returns a copy of ctx carrying the span context.
+func ContextWithSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	return context.WithValue(ctx, spanContextKey{}, sc)
+}
+
+// Returns the span context carried by ctx, if any.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	sc, _ := ctx.Value(spanContextKey{}).(SpanContext)
+	return sc
+}
+
+// Returns a background context carrying the span context of ctx, it is not
+// cancelled along with ctx.
+func Detach(ctx context.Context) context.Context {
+	return ContextWithSpanContext(context.Background(), SpanContextFromContext(ctx))
+}
+
+// Starts a span and returns a copy of ctx carrying its span context.
+func Start(tracer Tracer, ctx context.Context, name string, attributes Attributes) (context.Context, Span) {
+	span := tracer.Start(ctx, name, attributes)
+	return ContextWithSpanContext(ctx, span.SpanContext()), span
+}
+
+func isHex(s string, length int) bool {
+	if len(s) != length {
+		return false
+	}
+	zero := true
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '0':
+		case '1' <= c && c <= '9', 'a' <= c && c <= 'f':
+			zero = false
+		default:
+			return false
+		}
+	}
+	// all-zero ids are invalid, except for the version and flags fields
+	return !zero || length == 2
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,67 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	t.Run("valid", func(t *testing.T) {
+		sc, ok := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "congo=t61rcWkgMzE")
+		if !ok {
+			t.Fatal("ParseTraceparent() should accept a valid header")
+		}
+		if sc.TraceId != "4bf92f3577b34da6a3ce929d0e0e4736" || sc.SpanId != "00f067aa0ba902b7" || !sc.Sampled || sc.TraceState != "congo=t61rcWkgMzE" {
+			t.Fatalf(`ParseTraceparent() = %+v, want match for the header fields`, sc)
+		}
+		if h := sc.Traceparent(); h != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+			t.Fatalf(`SpanContext.Traceparent() = %q, want match for the parsed header`, h)
+		}
+	})
+
+	for name, header := range map[string]string{
+		"empty":         "",
+		"version ff":    "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"zero trace id": "00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"zero span id":  "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"uppercase":     "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"extra field":   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-00",
+		"short flags":   "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, ok := ParseTraceparent(header, ""); ok {
+				t.Fatalf("ParseTraceparent(%q) should be rejected", header)
+			}
+		})
+	}
+}
+
+func TestSpanContext(t *testing.T) {
+	parent, _ := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", "")
+
+	t.Run("Child", func(t *testing.T) {
+		child := parent.Child()
+		if !child.IsValid() || child.TraceId != parent.TraceId || child.SpanId == parent.SpanId {
+			t.Fatalf(`SpanContext.Child() = %+v, want a new span of trace %s`, child, parent.TraceId)
+		}
+		if root := (SpanContext{}).Child(); !root.IsValid() {
+			t.Fatalf(`SpanContext{}.Child() = %+v, want a valid root span`, root)
+		}
+	})
+
+	t.Run("Noop", func(t *testing.T) {
+		ctx, span := Start(Noop, ContextWithSpanContext(context.Background(), parent), "test", nil)
+		if sc := SpanContextFromContext(ctx); sc != parent || span.SpanContext() != parent {
+			t.Fatalf(`SpanContextFromContext() = %+v, want match for %+v`, sc, parent)
+		}
+	})
+
+	t.Run("Detach", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(ContextWithSpanContext(context.Background(), parent))
+		cancel()
+		detached := Detach(ctx)
+		if detached.Err() != nil || SpanContextFromContext(detached) != parent {
+			t.Fatal("Detach() should keep the span context but not the cancellation")
+		}
+	})
+}
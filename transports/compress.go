@@ -0,0 +1,49 @@
+package transports
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+type compressor interface {
+	io.WriteCloser
+	Reset(io.Writer)
+}
+
+// The compressors are reset and reused across responses, allocating one costs
+// hundreds of kilobytes for deflate and brotli.
+var compressors = map[string]*sync.Pool{
+	"gzip": {New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, 1)
+		return w
+	}},
+	"deflate": {New: func() any {
+		w, _ := flate.NewWriter(nil, 1)
+		return w
+	}},
+	"br": {New: func() any {
+		return brotli.NewWriterLevel(nil, 1)
+	}},
+}
+
+// Writes src compressed with the given encoding to dst.
+func compress(dst io.Writer, src io.Reader, encoding string) error {
+	pool, ok := compressors[encoding]
+	if !ok {
+		_, err := io.Copy(dst, src)
+		return err
+	}
+	w := pool.Get().(compressor)
+	w.Reset(dst)
+	_, err := io.Copy(w, src)
+	if e := w.Close(); err == nil {
+		err = e
+	}
+	w.Reset(nil)
+	pool.Put(w)
+	return err
+}
@@ -0,0 +1,68 @@
+package transports
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestCompress(t *testing.T) {
+	data := strings.Repeat("hello world ", 100)
+	readers := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) {
+			return gzip.NewReader(r)
+		},
+		"deflate": func(r io.Reader) (io.Reader, error) {
+			return flate.NewReader(r), nil
+		},
+		"br": func(r io.Reader) (io.Reader, error) {
+			return brotli.NewReader(r), nil
+		},
+	}
+
+	for encoding, reader := range readers {
+		encoding, reader := encoding, reader
+		t.Run(encoding, func(t *testing.T) {
+			// twice, so that the second run uses a reset compressor
+			for i := 0; i < 2; i++ {
+				buf := new(bytes.Buffer)
+				if err := compress(buf, strings.NewReader(data), encoding); err != nil {
+					t.Fatal("Error with compress:", err)
+				}
+				r, err := reader(buf)
+				if err != nil {
+					t.Fatal("Error with the decompressor:", err)
+				}
+				decompressed, err := io.ReadAll(r)
+				if err != nil {
+					t.Fatal("Error with the decompressor:", err)
+				}
+				if string(decompressed) != data {
+					t.Fatalf(`compress() = %q, want match for %q`, decompressed, data)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkCompress(b *testing.B) {
+	data := strings.Repeat("hello world ", 1000)
+	for _, encoding := range []string{"gzip", "deflate", "br"} {
+		b.Run(encoding, func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(data)))
+			buf := new(bytes.Buffer)
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				if err := compress(buf, strings.NewReader(data), encoding); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
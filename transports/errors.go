@@ -0,0 +1,16 @@
+package transports
+
+import (
+	"github.com/zishang520/engine.io/errors"
+)
+
+// Failures reported by the transports, the "error" event carries a transport
+// error wrapping one of them, compare with errors.Is.
+var (
+	ErrOverlap         = errors.New("request overlap from client").WithCode("TRANSPORT_OVERLAP")
+	ErrPrematureClose  = errors.New("connection closed prematurely").WithCode("TRANSPORT_PREMATURE_CLOSE")
+	ErrInvalidContent  = errors.New("invalid content").WithCode("TRANSPORT_INVALID_CONTENT")
+	ErrPayloadTooLarge = errors.New("payload too large").WithCode("TRANSPORT_PAYLOAD_TOO_LARGE")
+	ErrRead            = errors.New("read error").WithCode("TRANSPORT_READ_ERROR")
+	ErrWrite           = errors.New("write error").WithCode("TRANSPORT_WRITE_ERROR")
+)
@@ -0,0 +1,15 @@
+package transports
+
+import (
+	"github.com/zishang520/engine.io/events"
+)
+
+// Events emitted by a Transport.
+const (
+	EVENT_PACKET  events.EventName = "packet"  // (*packet.Packet)
+	EVENT_WRITTEN events.EventName = "written" // ([]*packet.Packet, error)
+	EVENT_HEADERS events.EventName = "headers" // (*utils.ParameterBag, *types.HttpContext)
+	EVENT_DRAIN   events.EventName = "drain"   // ()
+	EVENT_ERROR   events.EventName = "error"   // (error)
+	EVENT_CLOSE   events.EventName = "close"   // ()
+)
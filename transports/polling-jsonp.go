@@ -2,8 +2,10 @@ package transports
 
 import (
 	"encoding/json"
+	"io"
 	"net/url"
 	"regexp"
+	"strings"
 
 	"github.com/zishang520/engine.io/log"
 	"github.com/zishang520/engine.io/packet"
@@ -37,6 +39,7 @@ func (j *jsonp) New(ctx *types.HttpContext) *jsonp {
 	j.foot = ");"
 
 	j.onData = j.JSONPOnData
+	j.payloadOf = j.JSONPPayloadOf
 	j.doWrite = j.JSONPDoWrite
 	return j
 }
@@ -46,23 +49,53 @@ func (j *jsonp) New(ctx *types.HttpContext) *jsonp {
 func (j *jsonp) JSONPOnData(data types.BufferInterface) {
 	if data, err := url.ParseQuery(data.String()); err == nil {
 		if data.Has("d") {
-			_data := rSlashes.ReplaceAllStringFunc(data.Get("d"), func(m string) string {
-				if parts := rSlashes.FindStringSubmatch(m); parts[1] != "" {
-					return parts[0]
-				}
-				return "\n"
-			})
-			// client will send already escaped newlines as \\\\n and newlines as \\n
-			// \\n must be replaced with \n and \\\\n with \\n
-			j.PollingOnData(types.NewStringBufferString(rDoubleSlashes.ReplaceAllString(_data, "\\n")))
+			j.PollingOnData(types.NewStringBufferString(UnescapeJSONP(data.Get("d"))))
 		}
 	} else {
-		jsonp_log.Debug(`jsonp OnData error "%v"`, err)
+		j.log(jsonp_log).Debug(`jsonp OnData error "%v"`, err)
 	}
 }
 
+// Returns the payload of a request body, the "d" field of its form, so that
+// the v4 payloads are decoded with the limits of the polling ones.
+func (j *jsonp) JSONPPayloadOf(body io.Reader) (io.Reader, error) {
+	if j.maxHttpBufferSize > 0 {
+		// the content length may be unknown
+		body = io.LimitReader(body, j.maxHttpBufferSize+1)
+	}
+	form, err := io.ReadAll(body)
+	if err != nil {
+		return nil, ErrRead.Wrap(err)
+	}
+	if j.maxHttpBufferSize > 0 && int64(len(form)) > j.maxHttpBufferSize {
+		return nil, ErrPayloadTooLarge
+	}
+	data, err := url.ParseQuery(string(form))
+	if err != nil {
+		return nil, ErrInvalidContent.Wrap(err)
+	}
+	if !data.Has("d") {
+		return nil, ErrInvalidContent
+	}
+	return strings.NewReader(UnescapeJSONP(data.Get("d"))), nil
+}
+
+// Returns the payload of the "d" field of a JSONP request, in which the
+// clients escape the newlines.
+func UnescapeJSONP(d string) string {
+	d = rSlashes.ReplaceAllStringFunc(d, func(m string) string {
+		if parts := rSlashes.FindStringSubmatch(m); parts[1] != "" {
+			return parts[0]
+		}
+		return "\n"
+	})
+	// client will send already escaped newlines as \\\\n and newlines as \\n
+	// \\n must be replaced with \n and \\\\n with \\n
+	return rDoubleSlashes.ReplaceAllString(d, "\\n")
+}
+
 // Performs the write.
-func (j *jsonp) JSONPDoWrite(ctx *types.HttpContext, data types.BufferInterface, options *packet.Options, callback func(*types.HttpContext)) {
+func (j *jsonp) JSONPDoWrite(ctx *types.HttpContext, data types.BufferInterface, options *packet.Options, callback func(*types.HttpContext, error)) {
 	// prepare response
 	res := types.NewStringBufferString(j.head)
 	encoder := json.NewEncoder(res)
@@ -73,7 +106,9 @@ func (j *jsonp) JSONPDoWrite(ctx *types.HttpContext, data types.BufferInterface,
 		res.Truncate(res.Len() - 1) // '\n' 😑
 		res.WriteString(j.foot)
 		j.PollingDoWrite(ctx, res, options, callback)
+		types.ReleaseBuffer(res)
 	} else {
-		jsonp_log.Debug(`jsonp DoWrite error "%v"`, err)
+		j.log(jsonp_log).Debug(`jsonp DoWrite error "%v"`, err)
+		callback(ctx, ErrWrite.Wrap(err))
 	}
 }
@@ -0,0 +1,19 @@
+package transports
+
+import (
+	"testing"
+)
+
+func TestUnescapeJSONP(t *testing.T) {
+	for d, want := range map[string]string{
+		`4hello`:          "4hello",
+		`4a\nb`:           "4a\nb",
+		`4a\\nb`:          `4a\nb`,
+		`4a\nb\\nc`:       "4a\nb\\nc",
+		"4plain\nnewline": "4plain\nnewline",
+	} {
+		if got := UnescapeJSONP(d); got != want {
+			t.Fatalf("UnescapeJSONP(%q) = %q, want match for %q", d, got, want)
+		}
+	}
+}
@@ -1,8 +1,6 @@
 package transports
 
 import (
-	"compress/flate"
-	"compress/gzip"
 	"io"
 	"net/http"
 	"strconv"
@@ -10,10 +8,11 @@ import (
 	"sync"
 	"time"
 
-	"github.com/andybalholm/brotli"
+	"github.com/zishang520/engine.io/errors"
 	"github.com/zishang520/engine.io/events"
 	"github.com/zishang520/engine.io/log"
 	"github.com/zishang520/engine.io/packet"
+	"github.com/zishang520/engine.io/parser"
 	"github.com/zishang520/engine.io/types"
 	"github.com/zishang520/engine.io/utils"
 )
@@ -28,6 +27,10 @@ type polling struct {
 
 	shouldClose    types.Callable
 	mu_shouldClose sync.RWMutex
+
+	// returns the v4 payload of a request body, decoded as it is read rather
+	// than handed to onData once read
+	payloadOf func(io.Reader) (io.Reader, error)
 }
 
 // HTTP polling New.
@@ -48,6 +51,7 @@ func (p *polling) New(ctx *types.HttpContext) *polling {
 
 	p.onClose = p.PollingOnClose
 	p.onData = p.PollingOnData
+	p.payloadOf = p.PollingPayloadOf
 	p.doWrite = p.PollingDoWrite
 	p.doClose = p.PollingDoClose
 	p.send = p.PollingSend
@@ -75,20 +79,20 @@ func (p *polling) OnRequest(ctx *types.HttpContext) {
 func (p *polling) onPollRequest(ctx *types.HttpContext) {
 	p.mu_req.RLock()
 	if p.req != nil {
-		defer p.mu_req.RUnlock()
-		polling_log.Debug("request overlap")
+		p.mu_req.RUnlock()
+		p.log(polling_log).Debug("request overlap")
 		// assert: p.res, '.req and .res should be (un)set together'
-		p.OnError("overlap from client", nil)
+		p.OnError("overlap from client", ErrOverlap)
 		ctx.SetStatusCode(http.StatusInternalServerError)
 		ctx.Write(nil)
 		return
 	}
 	p.mu_req.RUnlock()
 
-	polling_log.Debug("setting request")
+	p.log(polling_log).Debug("setting request")
 
 	onClose := events.Listener(func(...any) {
-		p.OnError("poll connection closed prematurely", nil)
+		p.OnError("poll connection closed prematurely", ErrPrematureClose)
 	})
 
 	p.mu_req.Lock()
@@ -105,12 +109,12 @@ func (p *polling) onPollRequest(ctx *types.HttpContext) {
 	ctx.On("close", onClose)
 
 	p.SetWritable(true)
-	p.Emit("drain")
+	p.Emit(EVENT_DRAIN)
 
 	p.mu_shouldClose.RLock()
 	// if we're still writable but had a pending close, trigger an empty send
 	if p.Writable() && p.shouldClose != nil {
-		polling_log.Debug("triggering empty send to append close packet")
+		p.log(polling_log).Debug("triggering empty send to append close packet")
 		p.Send([]*packet.Packet{
 			&packet.Packet{
 				Type: packet.NOOP,
@@ -124,9 +128,9 @@ func (p *polling) onPollRequest(ctx *types.HttpContext) {
 func (p *polling) onDataRequest(ctx *types.HttpContext) {
 	p.mu_dataCtx.RLock()
 	if p.dataCtx != nil {
-		defer p.mu_dataCtx.RUnlock()
+		p.mu_dataCtx.RUnlock()
 		// assert: p.dataRes, '.dataReq and .dataRes should be (un)set together'
-		p.OnError("data request overlap from client", nil)
+		p.OnError("data request overlap from client", ErrOverlap)
 		ctx.SetStatusCode(http.StatusInternalServerError)
 		ctx.Write(nil)
 		return
@@ -136,7 +140,10 @@ func (p *polling) onDataRequest(ctx *types.HttpContext) {
 	isBinary := "application/octet-stream" == ctx.Headers().Peek("Content-Type")
 
 	if isBinary && p.protocol == 4 {
-		p.OnError("invalid content", nil)
+		// the request must be answered, the handler waits for it
+		ctx.SetStatusCode(http.StatusBadRequest)
+		ctx.Write(nil)
+		p.OnError("invalid content", ErrInvalidContent)
 		return
 	}
 
@@ -155,7 +162,7 @@ func (p *polling) onDataRequest(ctx *types.HttpContext) {
 
 	onClose = func(...any) {
 		cleanup()
-		p.OnError("data request connection closed prematurely", nil)
+		p.OnError("data request connection closed prematurely", ErrPrematureClose)
 	}
 
 	ctx.On("close", onClose)
@@ -167,17 +174,32 @@ func (p *polling) onDataRequest(ctx *types.HttpContext) {
 		return
 	}
 
-	var packet types.BufferInterface
-	if isBinary {
-		packet = types.NewBytesBuffer(nil)
+	var err error
+	if body := ctx.Request().Body; body != nil {
+		if p.protocol == 4 {
+			var payload io.Reader
+			if payload, err = p.payloadOf(body); err == nil {
+				err = p.decodePayload(payload)
+			}
+		} else {
+			err = p.readPayload(body, isBinary)
+		}
+		body.Close()
 	} else {
-		packet = types.NewStringBuffer(nil)
+		err = p.readPayload(http.NoBody, isBinary)
 	}
-	if rc, ok := ctx.Request().Body.(io.ReadCloser); ok && rc != nil {
-		packet.ReadFrom(rc)
-		rc.Close()
+	if err != nil {
+		p.log(polling_log).Debug("invalid payload: %v", err)
+		if errors.Is(err, ErrPayloadTooLarge) {
+			ctx.SetStatusCode(http.StatusRequestEntityTooLarge)
+		} else {
+			ctx.SetStatusCode(http.StatusBadRequest)
+		}
+		ctx.Write(nil)
+		cleanup()
+		p.OnError("invalid payload", err)
+		return
 	}
-	p.OnData(packet)
 
 	headers := utils.NewParameterBag(map[string][]string{
 		// text/html is required instead of text/plain to avoid an
@@ -191,13 +213,70 @@ func (p *polling) onDataRequest(ctx *types.HttpContext) {
 	cleanup()
 }
 
+// Reads the whole payload and hands it to onData.
+func (p *polling) readPayload(body io.Reader, isBinary bool) error {
+	var data types.BufferInterface
+	if isBinary {
+		data = types.NewBytesBuffer(nil)
+	} else {
+		data = types.NewStringBuffer(nil)
+	}
+	if p.maxHttpBufferSize > 0 {
+		// the content length may be unknown
+		body = io.LimitReader(body, p.maxHttpBufferSize+1)
+	}
+	if _, err := data.ReadFrom(body); err != nil {
+		return ErrRead.Wrap(err)
+	}
+	if p.maxHttpBufferSize > 0 && int64(data.Len()) > p.maxHttpBufferSize {
+		return ErrPayloadTooLarge
+	}
+	p.OnData(data)
+	return nil
+}
+
+// Returns the payload of a request body, the body itself.
+func (p *polling) PollingPayloadOf(body io.Reader) (io.Reader, error) {
+	return body, nil
+}
+
+// Decodes the packets of the payload as they are read, the packets read before
+// an invalid one are processed.
+func (p *polling) decodePayload(body io.Reader) error {
+	decoder := parser.NewPayloadDecoder(body, parser.PayloadLimits{
+		MaxPayloadSize: p.maxHttpBufferSize,
+		MaxPacketSize:  p.maxPacketSize,
+		MaxPackets:     p.maxPacketsPerPayload,
+	})
+	for {
+		packetData, err := decoder.Next()
+		switch {
+		case err == io.EOF:
+			return nil
+		case errors.Is(err, parser.ErrPayloadTooLarge), errors.Is(err, parser.ErrPacketTooLarge):
+			return ErrPayloadTooLarge.Wrap(err)
+		case errors.Is(err, parser.ErrTooManyPackets), errors.Is(err, parser.ErrInvalidPacket):
+			return ErrInvalidContent.Wrap(err)
+		case err != nil:
+			return ErrRead.Wrap(err)
+		}
+
+		if packet.CLOSE == packetData.Type {
+			p.log(polling_log).Debug("got xhr close packet")
+			p.OnClose()
+			return nil
+		}
+		p.OnPacket(packetData)
+	}
+}
+
 // Processes the incoming data payload.
 func (p *polling) PollingOnData(data types.BufferInterface) {
-	polling_log.Debug(`received "%s"`, data)
+	p.log(polling_log).Debug(`received "%s"`, data)
 
 	for _, packetData := range p.parser.DecodePayload(data) {
 		if packet.CLOSE == packetData.Type {
-			polling_log.Debug("got xhr close packet")
+			p.log(polling_log).Debug("got xhr close packet")
 			p.OnClose()
 			return
 		}
@@ -235,7 +314,7 @@ func (p *polling) PollingSend(packets []*packet.Packet) {
 	p.SetWritable(false)
 	p.mu_shouldClose.Lock()
 	if p.shouldClose != nil {
-		polling_log.Debug("appending close packet to payload")
+		p.log(polling_log).Debug("appending close packet to payload")
 		packets = append(packets, &packet.Packet{
 			Type: packet.CLOSE,
 		})
@@ -244,7 +323,7 @@ func (p *polling) PollingSend(packets []*packet.Packet) {
 	}
 	p.mu_shouldClose.Unlock()
 
-	option := &packet.Options{false}
+	option := &packet.Options{Compress: false}
 	for _, packetData := range packets {
 		if packetData.Options != nil && packetData.Options.Compress {
 			option.Compress = true
@@ -252,23 +331,32 @@ func (p *polling) PollingSend(packets []*packet.Packet) {
 		}
 	}
 
+	var err error
 	if p.protocol == 3 {
 		data, _ := p.parser.EncodePayload(packets, p.supportsBinary)
-		p.write(ctx, data, option)
+		err = p.write(ctx, data, option)
 	} else {
 		data, _ := p.parser.EncodePayload(packets)
-		p.write(ctx, data, option)
+		err = p.write(ctx, data, option)
 	}
+	p.OnWritten(packets, err)
 }
 
 // Writes data as response to poll request.
-func (p *polling) write(ctx *types.HttpContext, data types.BufferInterface, options *packet.Options) {
-	polling_log.Debug(`writing "%s"`, data)
-	p.DoWrite(ctx, data, options, func(ctx *types.HttpContext) { ctx.Cleanup() })
+func (p *polling) write(ctx *types.HttpContext, data types.BufferInterface, options *packet.Options) (err error) {
+	p.log(polling_log).Debug(`writing "%s"`, data)
+	err = ErrWrite.Wrap(errors.New("poll response not written").Err())
+	p.DoWrite(ctx, data, options, func(ctx *types.HttpContext, e error) {
+		err = e
+		ctx.Cleanup()
+	})
+	// the payload has been written out synchronously
+	types.ReleaseBuffer(data)
+	return err
 }
 
 // Performs the write.
-func (p *polling) PollingDoWrite(ctx *types.HttpContext, data types.BufferInterface, options *packet.Options, callback func(*types.HttpContext)) {
+func (p *polling) PollingDoWrite(ctx *types.HttpContext, data types.BufferInterface, options *packet.Options, callback func(*types.HttpContext, error)) {
 	contentType := "application/octet-stream"
 	// explicit UTF-8 is required for pages not served under utf
 	switch data.(type) {
@@ -284,8 +372,11 @@ func (p *polling) PollingDoWrite(ctx *types.HttpContext, data types.BufferInterf
 		headers.Set("Content-Length", length)
 		ctx.ResponseHeaders.With(p.Headers(ctx, headers).All())
 		ctx.SetStatusCode(http.StatusOK)
-		io.Copy(ctx, data)
-		callback(ctx)
+		if _, err := io.Copy(ctx, data); err != nil {
+			callback(ctx, ErrWrite.Wrap(err))
+			return
+		}
+		callback(ctx, nil)
 	}
 
 	if p.httpCompression == nil || options == nil || !options.Compress {
@@ -304,55 +395,39 @@ func (p *polling) PollingDoWrite(ctx *types.HttpContext, data types.BufferInterf
 		return
 	}
 
-	if buf, err := p.compress(data, encoding); err == nil {
-		headers.Set("Content-Encoding", encoding)
-		respond(buf, strconv.Itoa(buf.Len()))
+	buf, err := p.compress(data, encoding)
+	if err != nil {
+		ctx.SetStatusCode(http.StatusInternalServerError)
+		ctx.Write(nil)
+		callback(ctx, ErrWrite.Wrap(err))
+		return
 	}
+	headers.Set("Content-Encoding", encoding)
+	respond(buf, strconv.Itoa(buf.Len()))
+	types.ReleaseBuffer(buf)
 }
 
 // Compresses data.
 func (p *polling) compress(data types.BufferInterface, encoding string) (types.BufferInterface, error) {
-	polling_log.Debug("compressing")
-	buf := types.NewBytesBuffer(nil)
-	switch encoding {
-	case "gzip":
-		gz, err := gzip.NewWriterLevel(buf, 1)
-		if err != nil {
-			return nil, err
-		}
-		defer gz.Close()
-		if _, err := io.Copy(gz, data); err != nil {
-			return nil, err
-		}
-	case "deflate":
-		fl, err := flate.NewWriter(buf, 1)
-		if err != nil {
-			return nil, err
-		}
-		defer fl.Close()
-		if _, err := io.Copy(fl, data); err != nil {
-			return nil, err
-		}
-	case "br":
-		br := brotli.NewWriterLevel(buf, 1)
-		defer br.Close()
-		if _, err := io.Copy(br, data); err != nil {
-			return nil, err
-		}
+	p.log(polling_log).Debug("compressing")
+	buf := types.AcquireBytesBuffer()
+	if err := compress(buf, data, encoding); err != nil {
+		types.ReleaseBuffer(buf)
+		return nil, err
 	}
 	return buf, nil
 }
 
 // Closes the transport.
 func (p *polling) PollingDoClose(fn ...types.Callable) {
-	polling_log.Debug("closing")
+	p.log(polling_log).Debug("closing")
 
 	p.mu_dataCtx.RLock()
 	dataCtx := p.dataCtx
 	p.mu_dataCtx.RUnlock()
 
 	if dataCtx != nil && !dataCtx.IsDone() {
-		polling_log.Debug("aborting ongoing data request")
+		p.log(polling_log).Debug("aborting ongoing data request")
 		if h, ok := dataCtx.Response().(http.Hijacker); ok {
 			if netConn, _, err := h.Hijack(); err == nil {
 				if netConn.Close() == nil {
@@ -370,7 +445,7 @@ func (p *polling) PollingDoClose(fn ...types.Callable) {
 	}
 
 	if p.Writable() {
-		polling_log.Debug("transport writable - closing right away")
+		p.log(polling_log).Debug("transport writable - closing right away")
 		p.Send([]*packet.Packet{
 			&packet.Packet{
 				Type: packet.CLOSE,
@@ -378,14 +453,14 @@ func (p *polling) PollingDoClose(fn ...types.Callable) {
 		})
 		onClose()
 	} else if p.GetDiscarded() {
-		polling_log.Debug("transport discarded - closing right away")
+		p.log(polling_log).Debug("transport discarded - closing right away")
 		onClose()
 	} else {
-		polling_log.Debug("transport not writable - buffering orderly close")
-		closeTimeoutTimer := utils.SetTimeOut(onClose, p.closeTimeout)
+		p.log(polling_log).Debug("transport not writable - buffering orderly close")
+		closeTimeoutTimer := p.Clock().SetTimeout(onClose, p.closeTimeout)
 		p.mu_shouldClose.Lock()
 		p.shouldClose = func() {
-			utils.ClearTimeout(closeTimeoutTimer)
+			closeTimeoutTimer.Stop()
 			onClose()
 		}
 		p.mu_shouldClose.Unlock()
@@ -399,6 +474,6 @@ func (p *polling) Headers(ctx *types.HttpContext, headers *utils.ParameterBag) *
 	if ua := ctx.UserAgent(); (len(ua) > 0) && ((strings.Index(ua, ";MSIE") > -1) || (strings.Index(ua, "Trident/") > -1)) {
 		headers.Set("X-XSS-Protection", "0")
 	}
-	p.Emit("headers", headers, ctx)
+	p.Emit(EVENT_HEADERS, headers, ctx)
 	return headers
 }
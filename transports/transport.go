@@ -10,6 +10,7 @@ import (
 	"github.com/zishang520/engine.io/packet"
 	"github.com/zishang520/engine.io/parser"
 	"github.com/zishang520/engine.io/types"
+	"github.com/zishang520/engine.io/utils"
 )
 
 var transport_log = log.NewLog("engine:transport")
@@ -17,13 +18,16 @@ var transport_log = log.NewLog("engine:transport")
 type transport struct {
 	events.EventEmitter
 
-	maxHttpBufferSize int64
-	httpCompression   *types.HttpCompression
-	perMessageDeflate *types.PerMessageDeflate
+	maxHttpBufferSize    int64
+	maxPacketSize        int64
+	maxPacketsPerPayload int
+	httpCompression      *types.HttpCompression
+	perMessageDeflate    *types.PerMessageDeflate
 
 	sid          string
 	protocol     int // 3
 	closeTimeout time.Duration
+	clock        utils.Clock
 
 	_readyState   string //"open";
 	mu_readyState sync.RWMutex
@@ -45,13 +49,18 @@ type transport struct {
 	_writable   bool
 	mu_writable sync.RWMutex
 
-	send    func([]*packet.Packet)                                                                     // abstract
-	doClose func(...types.Callable)                                                                    // abstract
-	onData  func(types.BufferInterface)                                                                // abstract
-	doWrite func(*types.HttpContext, types.BufferInterface, *packet.Options, func(*types.HttpContext)) // abstract
-	onClose types.Callable                                                                             // abstract
+	send    func([]*packet.Packet)                                                                            // abstract
+	doClose func(...types.Callable)                                                                           // abstract
+	onData  func(types.BufferInterface)                                                                       // abstract
+	doWrite func(*types.HttpContext, types.BufferInterface, *packet.Options, func(*types.HttpContext, error)) // abstract
+	onClose types.Callable                                                                                    // abstract
 
 	musend sync.Mutex
+
+	logger  log.Logger
+	keyvals []any
+	logs    map[*log.Log]*log.Log // namespace logs carrying the fields above
+	mu_log  sync.RWMutex
 }
 
 func NewTransport(ctx *types.HttpContext) *transport {
@@ -81,6 +90,39 @@ func (t *transport) New(ctx *types.HttpContext) *transport {
 	return t
 }
 
+// Sets the logger receiving the records of the transport, they carry the
+// "transport" field and the given key/value pairs.
+func (t *transport) SetLogger(logger log.Logger, keyvals ...any) {
+	t.mu_log.Lock()
+	defer t.mu_log.Unlock()
+
+	t.logger = logger
+	t.keyvals = append(append([]any{}, keyvals...), "transport", t.name)
+	t.logs = map[*log.Log]*log.Log{}
+}
+
+// Returns the copy of the namespace log carrying the fields of the transport.
+func (t *transport) log(l *log.Log) *log.Log {
+	t.mu_log.RLock()
+	if t.logs == nil {
+		t.mu_log.RUnlock()
+		return l
+	}
+	logged, ok := t.logs[l]
+	t.mu_log.RUnlock()
+
+	if !ok {
+		t.mu_log.Lock()
+		defer t.mu_log.Unlock()
+
+		if logged, ok = t.logs[l]; !ok {
+			logged = l.WithLogger(t.logger).With(t.keyvals...)
+			t.logs[l] = logged
+		}
+	}
+	return logged
+}
+
 func (t *transport) Parser() parser.Parser {
 	return t.parser
 }
@@ -105,6 +147,14 @@ func (t *transport) SetMaxHttpBufferSize(maxHttpBufferSize int64) {
 	t.maxHttpBufferSize = maxHttpBufferSize
 }
 
+func (t *transport) SetMaxPacketSize(maxPacketSize int64) {
+	t.maxPacketSize = maxPacketSize
+}
+
+func (t *transport) SetMaxPacketsPerPayload(maxPacketsPerPayload int) {
+	t.maxPacketsPerPayload = maxPacketsPerPayload
+}
+
 func (t *transport) SetGttpCompression(httpCompression *types.HttpCompression) {
 	t.httpCompression = httpCompression
 
@@ -117,6 +167,14 @@ func (t *transport) MaxHttpBufferSize() int64 {
 	return t.maxHttpBufferSize
 }
 
+func (t *transport) MaxPacketSize() int64 {
+	return t.maxPacketSize
+}
+
+func (t *transport) MaxPacketsPerPayload() int {
+	return t.maxPacketsPerPayload
+}
+
 func (t *transport) HttpCompression() *types.HttpCompression {
 	return t.httpCompression
 
@@ -151,7 +209,7 @@ func (t *transport) OnClose() {
 	t.onClose()
 }
 
-func (t *transport) DoWrite(ctx *types.HttpContext, data types.BufferInterface, option *packet.Options, fn func(ctx *types.HttpContext)) {
+func (t *transport) DoWrite(ctx *types.HttpContext, data types.BufferInterface, option *packet.Options, fn func(*types.HttpContext, error)) {
 	t.doWrite(ctx, data, option, fn)
 }
 
@@ -167,7 +225,7 @@ func (t *transport) ReadyState() string {
 }
 
 func (t *transport) SetReadyState(state string) {
-	transport_log.Debug(`readyState updated from %s to %s (%s)`, t._readyState, state, t.Name())
+	t.log(transport_log).Debug(`readyState updated from %s to %s (%s)`, t._readyState, state, t.Name())
 	t.mu_readyState.Lock()
 	defer t.mu_readyState.Unlock()
 
@@ -178,6 +236,17 @@ func (t *transport) CloseTimeout() time.Duration {
 	return t.closeTimeout
 }
 
+func (t *transport) SetClock(clock utils.Clock) {
+	t.clock = clock
+}
+
+func (t *transport) Clock() utils.Clock {
+	if t.clock == nil {
+		return utils.SystemClock()
+	}
+	return t.clock
+}
+
 func (t *transport) Name() string {
 	return t.name
 }
@@ -207,7 +276,7 @@ func (t *transport) GetDiscarded() bool {
 
 // Called with an incoming HTTP request.
 func (t *transport) OnRequest(req *types.HttpContext) {
-	transport_log.Debug("setting request")
+	t.log(transport_log).Debug("setting request")
 	t.req = req
 }
 
@@ -224,15 +293,21 @@ func (t *transport) Close(fn ...types.Callable) {
 // Called with a transport error.
 func (t *transport) OnError(msg string, desc error) {
 	if t.ListenerCount("error") > 0 {
-		t.Emit("error", errors.NewTransportError(msg, desc).Err())
+		t.Emit(EVENT_ERROR, errors.NewTransportError(msg, desc).Err())
 	} else {
-		transport_log.Debug("ignored transport error %s (%s)", msg, desc)
+		t.log(transport_log).Debug("ignored transport error %s (%s)", msg, desc)
 	}
 }
 
 // Called with parsed out a packets from the data stream.
 func (t *transport) OnPacket(packet *packet.Packet) {
-	t.Emit("packet", packet)
+	t.Emit(EVENT_PACKET, packet)
+}
+
+// Called once packets have been written to the underlying connection, err is
+// nil when the write completed successfully.
+func (t *transport) OnWritten(packets []*packet.Packet, err error) {
+	t.Emit(EVENT_WRITTEN, packets, err)
 }
 
 // Called with the encoded packet data.
@@ -244,5 +319,5 @@ func (t *transport) TransportOnData(data types.BufferInterface) {
 // Called upon transport close.
 func (t *transport) TransportOnClose() {
 	t.SetReadyState("closed")
-	t.Emit("close")
+	t.Emit(EVENT_CLOSE)
 }
@@ -2,9 +2,11 @@ package transports
 
 import (
 	"github.com/zishang520/engine.io/events"
+	"github.com/zishang520/engine.io/log"
 	"github.com/zishang520/engine.io/packet"
 	"github.com/zishang520/engine.io/parser"
 	"github.com/zishang520/engine.io/types"
+	"github.com/zishang520/engine.io/utils"
 )
 
 type Transport interface {
@@ -13,10 +15,20 @@ type Transport interface {
 	SetSid(string)
 	SetSupportsBinary(bool)
 	SetMaxHttpBufferSize(int64)
+	SetMaxPacketSize(int64)
+	SetMaxPacketsPerPayload(int)
 	SetGttpCompression(*types.HttpCompression)
 	SetPerMessageDeflate(*types.PerMessageDeflate)
 	SetReadyState(string)
 
+	// Sets the logger receiving the records of the transport, they carry the
+	// given key/value pairs.
+	SetLogger(log.Logger, ...any)
+
+	// Sets the clock of the timeouts of the transport.
+	SetClock(utils.Clock)
+	Clock() utils.Clock
+
 	Parser() parser.Parser
 	Sid() string
 	Protocol() int
@@ -24,6 +36,8 @@ type Transport interface {
 	SupportsFraming() bool
 	HandlesUpgrades() bool
 	MaxHttpBufferSize() int64
+	MaxPacketSize() int64
+	MaxPacketsPerPayload() int
 	HttpCompression() *types.HttpCompression
 	PerMessageDeflate() *types.PerMessageDeflate
 	ReadyState() string
@@ -46,6 +60,9 @@ type Transport interface {
 	// Called with parsed out a packets from the data stream.
 	OnPacket(*packet.Packet)
 
+	// Called once packets have been written to the underlying connection.
+	OnWritten([]*packet.Packet, error)
+
 	// Called with the encoded packet data.
 	OnData(types.BufferInterface)
 
@@ -62,24 +62,28 @@ func (w *websocket) _init() {
 		if err != nil {
 			if ws.IsUnexpectedCloseError(err) {
 				w.OnClose()
+			} else if err == ws.ErrReadLimit {
+				w.OnError("Error reading data", ErrPayloadTooLarge.Wrap(err))
 			} else {
-				w.OnError("Error reading data", err)
+				w.OnError("Error reading data", ErrRead.Wrap(err))
 			}
 			break
 		}
 
+		// a fresh buffer holds each frame, the decoded packet and so the
+		// "message" listeners receive it without a further copy
 		switch mt {
 		case ws.BinaryMessage:
 			read := types.NewBytesBuffer(nil)
 			if _, err := read.ReadFrom(message); err != nil {
-				w.OnError("Error reading data", err)
+				w.OnError("Error reading data", ErrRead.Wrap(err))
 			} else {
 				w.WebSocketOnData(read)
 			}
 		case ws.TextMessage:
 			read := types.NewStringBuffer(nil)
 			if _, err := read.ReadFrom(message); err != nil {
-				w.OnError("Error reading data", err)
+				w.OnError("Error reading data", ErrRead.Wrap(err))
 			} else {
 				w.WebSocketOnData(read)
 			}
@@ -99,7 +103,7 @@ func (w *websocket) _init() {
 }
 
 func (w *websocket) WebSocketOnData(data types.BufferInterface) {
-	ws_log.Debug(`websocket received "%s"`, data)
+	w.log(ws_log).Debug(`websocket received "%s"`, data)
 	w.TransportOnData(data)
 }
 
@@ -108,17 +112,17 @@ func (w *websocket) WebSocketSend(packets []*packet.Packet) {
 	w.SetWritable(false)
 	defer func() {
 		w.SetWritable(true)
-		w.Emit("drain")
+		w.Emit(EVENT_DRAIN)
 	}()
 
 	w.musend.Lock()
 	defer w.musend.Unlock()
-	for _, packet := range packets {
-		w._send(packet)
+	for _, packetData := range packets {
+		w.OnWritten([]*packet.Packet{packetData}, w._send(packetData))
 	}
 }
 
-func (w *websocket) _send(packet *packet.Packet) {
+func (w *websocket) _send(packet *packet.Packet) error {
 	var data types.BufferInterface
 
 	if packet.WsPreEncoded != nil {
@@ -127,8 +131,8 @@ func (w *websocket) _send(packet *packet.Packet) {
 		var err error
 		data, err = w.parser.EncodePacket(packet, w.supportsBinary)
 		if err != nil {
-			ws_log.Debug(`Send Error "%s"`, err)
-			return
+			w.log(ws_log).Debug(`Send Error "%s"`, err)
+			return err
 		}
 	}
 
@@ -142,12 +146,16 @@ func (w *websocket) _send(packet *packet.Packet) {
 			compress = false
 		}
 	}
-	ws_log.Debug(`writing "%s"`, data)
+	w.log(ws_log).Debug(`writing "%s"`, data)
 
-	w.write(data, compress)
+	if packet.WsPreEncoded == nil {
+		// the frame is copied out by the connection and no longer referenced
+		defer types.ReleaseBuffer(data)
+	}
+	return w.write(data, compress)
 }
 
-func (w *websocket) write(data types.BufferInterface, compress bool) {
+func (w *websocket) write(data types.BufferInterface, compress bool) (err error) {
 	w.socket.EnableWriteCompression(compress)
 	mt := ws.BinaryMessage
 	if _, ok := data.(*types.StringBuffer); ok {
@@ -155,24 +163,30 @@ func (w *websocket) write(data types.BufferInterface, compress bool) {
 	}
 	write, err := w.socket.NextWriter(mt)
 	if err != nil {
+		err = ErrWrite.Wrap(err)
 		w.OnError("write error", err)
-		return
+		return err
 	}
 	defer func() {
-		if err := write.Close(); err != nil {
-			w.OnError("write error", err)
-			return
+		if e := write.Close(); e != nil {
+			e = ErrWrite.Wrap(e)
+			w.OnError("write error", e)
+			if err == nil {
+				err = e
+			}
 		}
 	}()
 	if _, err := io.Copy(write, data); err != nil {
+		err = ErrWrite.Wrap(err)
 		w.OnError("write error", err)
-		return
+		return err
 	}
+	return nil
 }
 
 // Closes the transport.
 func (w *websocket) WebSocketDoClose(fn ...types.Callable) {
-	ws_log.Debug(`closing`)
+	w.log(ws_log).Debug(`closing`)
 	if len(fn) > 0 {
 		(fn[0])()
 	}
@@ -1,12 +1,28 @@
 package types
 
 import (
+	"container/list"
+	"fmt"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
+
+	"github.com/zishang520/engine.io/errors"
 )
 
+// ErrInvalidCors is matched by the errors of an invalid Cors, see Cors.Validate.
+var ErrInvalidCors = errors.New("invalid cors option").WithCode("CORS_INVALID_OPTION")
+
+// OriginFunc decides whether a request origin is allowed, an error rejects the
+// request with 403 Forbidden.
+type OriginFunc func(origin string, ctx *HttpContext) (bool, error)
+
+// Origin accepts a string, a *regexp.Regexp, a bool, an OriginFunc, an
+// *AllowedOrigins or a []any of them. Methods, AllowedHeaders, Headers and
+// ExposedHeaders accept a comma separated string or a []string.
 type Cors struct {
 	Origin               any    `json:"origin,omitempty"`
 	Methods              any    `json:"methods,omitempty"`
@@ -17,6 +33,9 @@ type Cors struct {
 	Credentials          bool   `json:"credentials,omitempty"`
 	PreflightContinue    bool   `json:"preflightContinue,omitempty"`
 	OptionsSuccessStatus int    `json:"optionsSuccessStatus,omitempty"`
+	// Answers the preflights of the Private Network Access with
+	// Access-Control-Allow-Private-Network.
+	AllowPrivateNetwork bool `json:"allowPrivateNetwork,omitempty"`
 }
 
 type cors struct {
@@ -27,25 +46,195 @@ type cors struct {
 	mu      sync.RWMutex
 }
 
-func (c *cors) isOriginAllowed(origin string, allowedOrigin any) bool {
+// Reports whether the request origin is allowed by the Origin option, "*"
+// allows any origin.
+func (c *Cors) IsOriginAllowed(origin string, ctx *HttpContext) (bool, error) {
+	if c.Origin == "*" {
+		return true, nil
+	}
+	return isOriginAllowed(origin, c.Origin, ctx)
+}
+
+// Validate reports the options of an unsupported type or value, which would
+// otherwise produce no header.
+func (c *Cors) Validate() error {
+	if err := validateOrigin(c.Origin); err != nil {
+		return err
+	}
+	if err := validateTokens("methods", c.Methods); err != nil {
+		return err
+	}
+	if err := validateTokens("allowedHeaders", c.AllowedHeaders); err != nil {
+		return err
+	}
+	if err := validateTokens("headers", c.Headers); err != nil {
+		return err
+	}
+	if err := validateTokens("exposedHeaders", c.ExposedHeaders); err != nil {
+		return err
+	}
+	if c.MaxAge != "" {
+		if maxAge, err := strconv.ParseInt(c.MaxAge, 10, 64); err != nil || maxAge < 0 {
+			return invalidCors(`maxAge "%s" is not a number of seconds`, c.MaxAge)
+		}
+	}
+	if c.OptionsSuccessStatus != 0 && (c.OptionsSuccessStatus < 200 || c.OptionsSuccessStatus > 299) {
+		return invalidCors("optionsSuccessStatus %d is not a 2xx status", c.OptionsSuccessStatus)
+	}
+	return nil
+}
+
+func invalidCors(format string, args ...any) error {
+	return errors.New(fmt.Sprintf(format, args...)).WithCode(ErrInvalidCors.Code).Err()
+}
+
+func validateOrigin(origin any) error {
+	switch v := origin.(type) {
+	case nil, string, bool:
+	case []any:
+		for _, value := range v {
+			if value == nil {
+				return invalidCors("origin list holds a nil origin")
+			}
+			if err := validateOrigin(value); err != nil {
+				return err
+			}
+		}
+	case *regexp.Regexp:
+		if v == nil {
+			return invalidCors("origin is a nil *regexp.Regexp")
+		}
+	case *AllowedOrigins:
+		if v == nil {
+			return invalidCors("origin is a nil *AllowedOrigins")
+		}
+	case OriginFunc:
+		if v == nil {
+			return invalidCors("origin is a nil OriginFunc")
+		}
+	case func(string, *HttpContext) (bool, error):
+		if v == nil {
+			return invalidCors("origin is a nil func")
+		}
+	default:
+		return invalidCors("origin of type %T is not supported", origin)
+	}
+	return nil
+}
+
+func validateTokens(name string, value any) error {
+	var tokens []string
+	switch v := value.(type) {
+	case nil:
+	case string:
+		if v != "" {
+			tokens = strings.Split(v, ",")
+		}
+	case []string:
+		tokens = v
+	default:
+		return invalidCors("%s of type %T is not supported", name, value)
+	}
+	for _, token := range tokens {
+		if !isToken(strings.TrimSpace(token)) {
+			return invalidCors(`%s holds the invalid token "%s"`, name, token)
+		}
+	}
+	return nil
+}
+
+// Reports whether s is a token of RFC 7230, as are the methods and the header
+// names.
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		case strings.IndexByte("!#$%&'*+-.^_`|~", c) >= 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func isOriginAllowed(origin string, allowedOrigin any, ctx *HttpContext) (bool, error) {
 	switch v := allowedOrigin.(type) {
 	case []any:
 		for _, value := range v {
-			if c.isOriginAllowed(origin, value) {
-				return true
+			if ok, err := isOriginAllowed(origin, value, ctx); ok || err != nil {
+				return ok, err
 			}
 		}
 	case string:
-		return origin == v
+		return origin == v, nil
 	case *regexp.Regexp:
-		return v.MatchString(origin)
+		return v.MatchString(origin), nil
 	case bool:
-		return v
+		return v, nil
+	case *AllowedOrigins:
+		return v.Allowed(origin, ctx)
+	case OriginFunc:
+		return v(origin, ctx)
+	case func(string, *HttpContext) (bool, error):
+		return v(origin, ctx)
+	}
+	return false, nil
+}
+
+// The number of origins a CachedOrigin keeps the decisions of.
+const maxCachedOrigins = 1024
+
+// CachedOrigin caches the decisions of fn per origin for ttl, the errors are
+// not cached. The decisions must only depend on the origin. The decisions of
+// the origins resolved first are dropped past 1024 origins, so that a flood of
+// origins can't grow the cache.
+func CachedOrigin(fn OriginFunc, ttl time.Duration) OriginFunc {
+	type decision struct {
+		origin  string
+		allowed bool
+		expires time.Time
+	}
+	var (
+		cache = map[string]*list.Element{}
+		order = list.New() // of the decisions, oldest first
+		mu    sync.Mutex
+	)
+	return func(origin string, ctx *HttpContext) (bool, error) {
+		now := time.Now()
+		mu.Lock()
+		e, ok := cache[origin]
+		var d decision
+		if ok {
+			d = *e.Value.(*decision)
+		}
+		mu.Unlock()
+		if ok && now.Before(d.expires) {
+			return d.allowed, nil
+		}
+
+		allowed, err := fn(origin, ctx)
+		if err != nil {
+			return false, err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if e, ok := cache[origin]; ok {
+			order.Remove(e)
+		}
+		for order.Len() >= maxCachedOrigins {
+			delete(cache, order.Remove(order.Front()).(*decision).origin)
+		}
+		cache[origin] = order.PushBack(&decision{origin: origin, allowed: allowed, expires: now.Add(ttl)})
+		return allowed, nil
 	}
-	return false
 }
 
-func (c *cors) configureOrigin() *cors {
+func (c *cors) configureOrigin() (*cors, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -67,7 +256,11 @@ func (c *cors) configureOrigin() *cors {
 		}
 	} else {
 		// reflect origin
-		if c.isOriginAllowed(requestOrigin, c.options.Origin) {
+		allowed, err := isOriginAllowed(requestOrigin, c.options.Origin, c.ctx)
+		if err != nil {
+			return c, err
+		}
+		if allowed {
 			c.headers = append(c.headers, &Kv{
 				Key:   "Access-Control-Allow-Origin",
 				Value: requestOrigin,
@@ -80,7 +273,7 @@ func (c *cors) configureOrigin() *cors {
 		}
 		c.varys = append(c.varys, "Origin")
 	}
-	return c
+	return c, nil
 }
 
 func (c *cors) configureMethods() *cors {
@@ -180,13 +373,26 @@ func (c *cors) configureMaxAge() *cors {
 		defer c.mu.Unlock()
 
 		c.headers = append(c.headers, &Kv{
-			Key:   "Access-Control-Expose-Headers",
+			Key:   "Access-Control-Max-Age",
 			Value: c.options.MaxAge,
 		})
 	}
 	return c
 }
 
+func (c *cors) configurePrivateNetwork() *cors {
+	if c.options.AllowPrivateNetwork && c.ctx.Headers().Peek("Access-Control-Request-Private-Network") == "true" {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+
+		c.headers = append(c.headers, &Kv{
+			Key:   "Access-Control-Allow-Private-Network",
+			Value: "true",
+		})
+	}
+	return c
+}
+
 func parseVary(vary string) *Set[string] {
 	end := 0
 	start := 0
@@ -244,9 +450,18 @@ func corsFunc(options *Cors, ctx *HttpContext, next Callable) {
 	}
 	method := c.ctx.Method()
 
+	if _, err := c.configureOrigin(); err != nil {
+		// the origin could not be resolved
+		ctx.ResponseHeaders.Set("Vary", "Origin")
+		ctx.ResponseHeaders.Set("Content-Length", "0")
+		ctx.SetStatusCode(http.StatusForbidden)
+		ctx.Write(nil)
+		return
+	}
+
 	if http.MethodOptions == method {
 		// preflight
-		c.configureOrigin().configureCredentials().configureMethods().configureAllowedHeaders().configureMaxAge().configureExposedHeaders().applyHeaders()
+		c.configureCredentials().configureMethods().configureAllowedHeaders().configureMaxAge().configureExposedHeaders().configurePrivateNetwork().applyHeaders()
 		if options.PreflightContinue {
 			next()
 		} else {
@@ -258,7 +473,7 @@ func corsFunc(options *Cors, ctx *HttpContext, next Callable) {
 		}
 	} else {
 		// actual response
-		c.configureOrigin().configureCredentials().configureExposedHeaders().applyHeaders()
+		c.configureCredentials().configureExposedHeaders().applyHeaders()
 		next()
 	}
 }
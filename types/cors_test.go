@@ -0,0 +1,193 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestCors(t *testing.T) {
+	newContext := func(method, origin string) (*HttpContext, *httptest.ResponseRecorder) {
+		r := httptest.NewRequest(method, "/engine.io/", nil)
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		return NewHttpContext(w, r), w
+	}
+
+	t.Run("IsOriginAllowed", func(t *testing.T) {
+		tenants := func(origin string, _ *HttpContext) (bool, error) {
+			return origin == "https://tenant.example.com", nil
+		}
+		for _, test := range []struct {
+			origin any
+			want   bool
+		}{
+			{"*", true},
+			{"https://example.com", true},
+			{"https://other.com", false},
+			{regexp.MustCompile(`^https://(.+\.)?example\.com$`), true},
+			{[]any{"https://other.com", tenants}, false},
+			{[]any{"https://other.com", regexp.MustCompile(`example`)}, true},
+			{OriginFunc(tenants), false},
+			{false, false},
+		} {
+			cors := &Cors{Origin: test.origin}
+			if allowed, err := cors.IsOriginAllowed("https://example.com", nil); err != nil || allowed != test.want {
+				t.Fatalf(`*Cors.IsOriginAllowed() with %v = %t, %v, want match for %t`, test.origin, allowed, err, test.want)
+			}
+		}
+	})
+
+	t.Run("OriginFunc", func(t *testing.T) {
+		middleware := MiddlewareWrapper(&Cors{
+			Origin: func(origin string, _ *HttpContext) (bool, error) {
+				if origin == "https://down.example.com" {
+					return false, errors.New("tenant store unavailable")
+				}
+				return origin == "https://tenant.example.com", nil
+			},
+		})
+
+		ctx, _ := newContext(http.MethodGet, "https://tenant.example.com")
+		called := false
+		middleware(ctx, func() { called = true })
+		if origin := ctx.ResponseHeaders.Peek("Access-Control-Allow-Origin"); !called || origin != "https://tenant.example.com" {
+			t.Fatalf(`Access-Control-Allow-Origin = %q, want match for %q`, origin, "https://tenant.example.com")
+		}
+
+		ctx, w := newContext(http.MethodGet, "https://down.example.com")
+		called = false
+		middleware(ctx, func() { called = true })
+		if called || w.Code != http.StatusForbidden {
+			t.Fatalf(`status = %d, want match for %d`, w.Code, http.StatusForbidden)
+		}
+	})
+
+	t.Run("CachedOrigin", func(t *testing.T) {
+		calls := 0
+		fail := false
+		origin := CachedOrigin(func(origin string, _ *HttpContext) (bool, error) {
+			calls++
+			if fail {
+				return false, errors.New("tenant store unavailable")
+			}
+			return true, nil
+		}, 50*time.Millisecond)
+
+		for i := 0; i < 3; i++ {
+			if allowed, err := origin("https://tenant.example.com", nil); !allowed || err != nil {
+				t.Fatalf(`CachedOrigin() = %t, %v, want match for true`, allowed, err)
+			}
+		}
+		if calls != 1 {
+			t.Fatalf(`CachedOrigin called the resolver %d times, want match for %d`, calls, 1)
+		}
+
+		time.Sleep(60 * time.Millisecond)
+		fail = true
+		if _, err := origin("https://tenant.example.com", nil); err == nil || calls != 2 {
+			t.Fatal("CachedOrigin should resolve an expired origin again")
+		}
+	})
+
+	t.Run("CachedOriginFlood", func(t *testing.T) {
+		calls := 0
+		origin := CachedOrigin(func(origin string, _ *HttpContext) (bool, error) {
+			calls++
+			return true, nil
+		}, time.Hour)
+		name := func(i int) string { return fmt.Sprintf("https://%d.example.com", i) }
+
+		// the decisions have not expired, the oldest ones are dropped
+		for i := 0; i <= maxCachedOrigins; i++ {
+			origin(name(i), nil)
+		}
+		calls = 0
+		origin(name(maxCachedOrigins), nil)
+		origin(name(1), nil)
+		if calls != 0 {
+			t.Fatalf(`CachedOrigin called the resolver %d times for the recent origins, want match for %d`, calls, 0)
+		}
+		origin(name(0), nil)
+		if calls != 1 {
+			t.Fatal("CachedOrigin should drop the oldest origin past its capacity")
+		}
+	})
+
+	t.Run("AllowedOrigins", func(t *testing.T) {
+		origins := NewAllowedOrigins("https://example.com", regexp.MustCompile(`\.example\.org$`))
+		for origin, want := range map[string]bool{
+			"https://example.com":     true,
+			"https://app.example.org": true,
+			"https://evil.com":        false,
+		} {
+			if allowed, err := origins.Allowed(origin, nil); err != nil || allowed != want {
+				t.Fatalf(`*AllowedOrigins.Allowed(%q) = %t, %v, want match for %t`, origin, allowed, err, want)
+			}
+		}
+
+		middleware := MiddlewareWrapper(&Cors{Origin: origins})
+		ctx, _ := newContext(http.MethodGet, "https://app.example.org")
+		middleware(ctx, func() {})
+		if origin := ctx.ResponseHeaders.Peek("Access-Control-Allow-Origin"); origin != "https://app.example.org" {
+			t.Fatalf(`Access-Control-Allow-Origin = %q, want match for %q`, origin, "https://app.example.org")
+		}
+	})
+
+	t.Run("SameOrigin", func(t *testing.T) {
+		ctx, _ := newContext(http.MethodGet, "")
+		if !SameOrigin("http://example.com", ctx) || SameOrigin("http://evil.com", ctx) {
+			t.Fatalf(`SameOrigin() should only allow the host of the request %q`, ctx.Request().Host)
+		}
+	})
+
+	t.Run("Validate", func(t *testing.T) {
+		for _, test := range []struct {
+			cors  *Cors
+			valid bool
+		}{
+			{&Cors{Origin: "*", Methods: "GET, POST", MaxAge: "600"}, true},
+			{&Cors{Origin: []any{"https://example.com", regexp.MustCompile(`example`)}, ExposedHeaders: []string{"X-Request-Id"}}, true},
+			{&Cors{Origin: []string{"https://example.com"}}, false},
+			{&Cors{Origin: []any{"https://example.com", nil}}, false},
+			{&Cors{Methods: []any{"GET"}}, false},
+			{&Cors{AllowedHeaders: "X-Token, Bad Header"}, false},
+			{&Cors{MaxAge: "10m"}, false},
+			{&Cors{OptionsSuccessStatus: 404}, false},
+		} {
+			if err := test.cors.Validate(); (err == nil) != test.valid || (err != nil && !errors.Is(err, ErrInvalidCors)) {
+				t.Fatalf(`*Cors.Validate() with %+v = %v, want match for valid %t`, test.cors, err, test.valid)
+			}
+		}
+	})
+
+	t.Run("Preflight", func(t *testing.T) {
+		middleware := MiddlewareWrapper(&Cors{Origin: "*", MaxAge: "600", AllowPrivateNetwork: true})
+		ctx, w := newContext(http.MethodOptions, "https://example.com")
+		ctx.Headers().Set("Access-Control-Request-Private-Network", "true")
+		middleware(ctx, func() {})
+		if w.Code != http.StatusNoContent {
+			t.Fatalf(`status = %d, want match for %d`, w.Code, http.StatusNoContent)
+		}
+		for header, want := range map[string]string{
+			"Access-Control-Max-Age":               "600",
+			"Access-Control-Allow-Private-Network": "true",
+		} {
+			if value := ctx.ResponseHeaders.Peek(header); value != want {
+				t.Fatalf(`%s = %q, want match for %q`, header, value, want)
+			}
+		}
+
+		ctx, _ = newContext(http.MethodOptions, "https://example.com")
+		middleware(ctx, func() {})
+		if value := ctx.ResponseHeaders.Peek("Access-Control-Allow-Private-Network"); value != "" {
+			t.Fatalf(`Access-Control-Allow-Private-Network = %q, want match for %q`, value, "")
+		}
+	})
+}
@@ -29,7 +29,8 @@ type HttpContext struct {
 	pathInfo    string
 	isHostValid bool
 
-	ctx context.Context
+	ctx    context.Context
+	mu_ctx sync.RWMutex
 
 	isDone bool
 	done   chan struct{}
@@ -57,13 +58,13 @@ func NewHttpContext(w http.ResponseWriter, r *http.Request) *HttpContext {
 	c.ResponseHeaders = utils.NewParameterBag(nil)
 	c.ResponseHeaders.With(c.response.Header())
 
-	go func() {
+	go func(ctx context.Context) {
 		select {
-		case <-c.ctx.Done():
+		case <-ctx.Done():
 			c.Flush()
 			c.Emit("close")
 		}
-	}()
+	}(c.ctx)
 	return c
 }
 
@@ -133,9 +134,20 @@ func (c *HttpContext) Query() *utils.ParameterBag {
 }
 
 func (c *HttpContext) Context() context.Context {
+	c.mu_ctx.RLock()
+	defer c.mu_ctx.RUnlock()
+
 	return c.ctx
 }
 
+// SetContext replaces the context of the request, ctx must be derived from it.
+func (c *HttpContext) SetContext(ctx context.Context) {
+	c.mu_ctx.Lock()
+	defer c.mu_ctx.Unlock()
+
+	c.ctx = ctx
+}
+
 func (c *HttpContext) GetPathInfo() string {
 	if c.pathInfo == "" {
 		c.pathInfo = c.request.URL.Path
@@ -0,0 +1,41 @@
+package types
+
+import (
+	"net/url"
+	"strings"
+)
+
+// AllowedOrigins is an origin policy shared by the CORS middleware and the
+// websocket upgrades, it accepts the values of Cors.Origin and "*" for any
+// origin.
+type AllowedOrigins struct {
+	origins []any
+}
+
+func NewAllowedOrigins(origins ...any) *AllowedOrigins {
+	return &AllowedOrigins{origins: origins}
+}
+
+// Reports whether the origin is allowed, an error means it could not be
+// resolved.
+func (a *AllowedOrigins) Allowed(origin string, ctx *HttpContext) (bool, error) {
+	for _, o := range a.origins {
+		if o == "*" {
+			return true, nil
+		}
+		if ok, err := isOriginAllowed(origin, o, ctx); ok || err != nil {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// Reports whether the origin has the host of the request, the check applied to
+// the websocket upgrades by default.
+func SameOrigin(origin string, ctx *HttpContext) bool {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, ctx.Request().Host)
+}
@@ -0,0 +1,50 @@
+package types
+
+import (
+	"sync"
+)
+
+// Buffers larger than this are left to the garbage collector rather than
+// pooled, so that a burst of large messages does not pin memory.
+const maxPooledBufferSize = 64 << 10
+
+var bufferPool = sync.Pool{
+	New: func() any {
+		return new(Buffer)
+	},
+}
+
+// AcquireBytesBuffer returns an empty binary buffer from the pool, it should
+// be given back with ReleaseBuffer once its content is no longer referenced.
+func AcquireBytesBuffer() BufferInterface {
+	return &BytesBuffer{bufferPool.Get().(*Buffer)}
+}
+
+// AcquireStringBuffer returns an empty string buffer from the pool, it should
+// be given back with ReleaseBuffer once its content is no longer referenced.
+func AcquireStringBuffer() BufferInterface {
+	return &StringBuffer{bufferPool.Get().(*Buffer)}
+}
+
+// ReleaseBuffer gives the storage of a buffer back to the pool. The buffer
+// and the slices returned by its Bytes or Next methods must not be used
+// afterwards, a released buffer panics when used.
+func ReleaseBuffer(b BufferInterface) {
+	var buf **Buffer
+	switch v := b.(type) {
+	case *BytesBuffer:
+		buf = &v.Buffer
+	case *StringBuffer:
+		buf = &v.Buffer
+	default:
+		return
+	}
+	if *buf == nil {
+		return
+	}
+	if (*buf).Cap() <= maxPooledBufferSize {
+		(*buf).Reset()
+		bufferPool.Put(*buf)
+	}
+	*buf = nil
+}
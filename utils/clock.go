@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"time"
+)
+
+// A source of time and timers, the heartbeats and timeouts of the engine read
+// the time through it so that the tests can control it.
+type Clock interface {
+	// Returns the current time.
+	Now() time.Time
+
+	// Calls fn once sleep has elapsed.
+	SetTimeout(fn func(), sleep time.Duration) ClockTimer
+
+	// Calls fn every time sleep has elapsed.
+	SetInterval(fn func(), sleep time.Duration) ClockTimer
+}
+
+// A timer scheduled by a Clock.
+type ClockTimer interface {
+	// Stops the timer, returns false if it had already fired or been stopped.
+	Stop() bool
+
+	// Restarts the timer from now, even when it has already fired; a stopped
+	// timer is not restarted.
+	Refresh()
+}
+
+// The clock of the wall time, its timers are served by a TimingWheel and call
+// their function in its own goroutine.
+type wheelClock struct {
+	wheel *TimingWheel
+}
+
+type wheelClockTimer struct {
+	*WheelTimer
+}
+
+var systemClock Clock = NewWheelClock(DefaultTimingWheel())
+
+// Returns the clock of the wall time shared by the servers, its timers are
+// served by the default TimingWheel.
+func SystemClock() Clock {
+	return systemClock
+}
+
+// Creates a clock of the wall time whose timers are served by the wheel.
+func NewWheelClock(wheel *TimingWheel) Clock {
+	return &wheelClock{wheel: wheel}
+}
+
+func (c *wheelClock) Now() time.Time {
+	return time.Now()
+}
+
+func (c *wheelClock) SetTimeout(fn func(), sleep time.Duration) ClockTimer {
+	return wheelClockTimer{c.wheel.SetTimeout(fn, sleep)}
+}
+
+func (c *wheelClock) SetInterval(fn func(), sleep time.Duration) ClockTimer {
+	return wheelClockTimer{c.wheel.SetInterval(fn, sleep)}
+}
+
+func (t wheelClockTimer) Refresh() {
+	t.WheelTimer.Refresh()
+}
@@ -0,0 +1,273 @@
+package utils
+
+import (
+	"sync"
+	"time"
+)
+
+// A hierarchical timing wheel, every timer it schedules is served by a single
+// goroutine which only runs while timers are pending, instead of a goroutine
+// and a time.Timer per timer as SetTimeOut does.
+//
+// The level i of the wheel has `size` slots of tick*size^i, a timer is placed
+// in the lowest level which covers its expiration and moved down to the lower
+// levels as the time advances, so that scheduling and stopping are O(1).
+type TimingWheel struct {
+	tick time.Duration
+	size int64
+
+	levels  [][]wheelSlot
+	current int64 // the ticks elapsed since start
+	start   time.Time
+	pending int
+	running bool
+
+	mu sync.Mutex
+}
+
+// A doubly linked list of timers.
+type wheelSlot struct {
+	head *WheelTimer
+}
+
+// A timer scheduled by a TimingWheel.
+type WheelTimer struct {
+	wheel    *TimingWheel
+	fn       func()
+	sleep    time.Duration
+	interval bool
+
+	expire     int64
+	slot       *wheelSlot
+	prev, next *WheelTimer
+	stopped    bool
+	due        int // the expirations collected whose fn is not called yet
+}
+
+var defaultTimingWheel = NewTimingWheel(10*time.Millisecond, 256)
+
+// Returns the TimingWheel shared by the package functions, with a resolution of 10ms.
+func DefaultTimingWheel() *TimingWheel {
+	return defaultTimingWheel
+}
+
+// Creates a TimingWheel with the given resolution and number of slots per level.
+func NewTimingWheel(tick time.Duration, size int) *TimingWheel {
+	if tick <= 0 {
+		tick = time.Millisecond
+	}
+	if size < 2 {
+		size = 2
+	}
+	return &TimingWheel{
+		tick: tick,
+		size: int64(size),
+	}
+}
+
+// Calls fn in its own goroutine once sleep has elapsed.
+func (w *TimingWheel) SetTimeout(fn func(), sleep time.Duration) *WheelTimer {
+	t := &WheelTimer{wheel: w, fn: fn, sleep: sleep}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.schedule(t)
+	return t
+}
+
+// Calls fn in its own goroutine every time sleep has elapsed.
+func (w *TimingWheel) SetInterval(fn func(), sleep time.Duration) *WheelTimer {
+	t := &WheelTimer{wheel: w, fn: fn, sleep: sleep, interval: true}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.schedule(t)
+	return t
+}
+
+// Returns the number of pending timers.
+func (w *TimingWheel) Len() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.pending
+}
+
+// Stops the timer, returns false if it had already fired or been stopped.
+// Once it returns, fn is not called again, even when the timer has expired
+// and its goroutine is starting. It is a no-op on a nil timer.
+func (t *WheelTimer) Stop() bool {
+	if t == nil {
+		return false
+	}
+	t.wheel.mu.Lock()
+	defer t.wheel.mu.Unlock()
+
+	stopped := t.wheel.remove(t) || (t.due > 0 && !t.stopped)
+	t.stopped = true
+	return stopped
+}
+
+// Restarts the timer from now, even when it has already fired; a stopped
+// timer is not restarted. It is a no-op on a nil timer.
+func (t *WheelTimer) Refresh() *WheelTimer {
+	if t == nil {
+		return t
+	}
+	t.wheel.mu.Lock()
+	defer t.wheel.mu.Unlock()
+
+	if !t.stopped {
+		t.wheel.remove(t)
+		t.wheel.schedule(t)
+	}
+	return t
+}
+
+// Places the timer sleep from now, w.mu must be held.
+func (w *TimingWheel) schedule(t *WheelTimer) {
+	if w.pending == 0 {
+		// the wheel is idle, restart its clock
+		w.start = time.Now()
+		w.current = 0
+	}
+	ticks := int64((time.Since(w.start) + t.sleep + w.tick - 1) / w.tick)
+	if ticks <= w.current {
+		ticks = w.current + 1
+	}
+	t.expire = ticks
+	w.insert(t)
+	w.pending++
+
+	if !w.running {
+		w.running = true
+		go w.run()
+	}
+}
+
+// Links the timer in the slot of its expiration, w.mu must be held.
+func (w *TimingWheel) insert(t *WheelTimer) {
+	delta := t.expire - w.current
+	level, span := 0, int64(1)
+	for delta >= span*w.size {
+		level++
+		span *= w.size
+	}
+	for len(w.levels) <= level {
+		w.levels = append(w.levels, make([]wheelSlot, w.size))
+	}
+
+	slot := &w.levels[level][(t.expire/span)%w.size]
+	t.slot, t.prev, t.next = slot, nil, slot.head
+	if slot.head != nil {
+		slot.head.prev = t
+	}
+	slot.head = t
+}
+
+// Unlinks the timer from its slot, w.mu must be held.
+func (w *TimingWheel) remove(t *WheelTimer) bool {
+	if t.slot == nil {
+		return false
+	}
+	if t.prev != nil {
+		t.prev.next = t.next
+	} else {
+		t.slot.head = t.next
+	}
+	if t.next != nil {
+		t.next.prev = t.prev
+	}
+	t.slot, t.prev, t.next = nil, nil, nil
+	w.pending--
+	return true
+}
+
+// Advances the wheel with the time until no timer is pending.
+func (w *TimingWheel) run() {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired, running := w.collect()
+		if !running {
+			return
+		}
+		for _, t := range expired {
+			go w.fire(t)
+		}
+	}
+}
+
+// Advances the wheel to now and returns the expired timers, false when no
+// timer is pending and the wheel stops.
+func (w *TimingWheel) collect() ([]*WheelTimer, bool) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.pending == 0 {
+		w.running = false
+		return nil, false
+	}
+	var expired []*WheelTimer
+	for now := int64(time.Since(w.start) / w.tick); w.current < now; {
+		w.current++
+		expired = w.advance(expired)
+	}
+	for _, t := range expired {
+		t.due++
+	}
+	return expired, true
+}
+
+// Calls the fn of an expired timer, unless it was stopped since.
+func (w *TimingWheel) fire(t *WheelTimer) {
+	w.mu.Lock()
+	t.due--
+	stopped := t.stopped
+	w.mu.Unlock()
+
+	if !stopped {
+		t.fn()
+	}
+}
+
+// Processes the tick w.current, the timers of the upper levels whose slot
+// starts now are moved down first, then the expired timers are collected.
+// w.mu must be held.
+func (w *TimingWheel) advance(expired []*WheelTimer) []*WheelTimer {
+	span := int64(1)
+	for level := 1; level < len(w.levels); level++ {
+		span *= w.size
+	}
+	for level := len(w.levels) - 1; level > 0; level, span = level-1, span/w.size {
+		if w.current%span != 0 {
+			continue
+		}
+		slot := &w.levels[level][(w.current/span)%w.size]
+		for t := slot.head; t != nil; {
+			next := t.next
+			w.remove(t)
+			w.pending++
+			w.insert(t)
+			t = next
+		}
+	}
+
+	slot := &w.levels[0][w.current%w.size]
+	for t := slot.head; t != nil; {
+		next := t.next
+		w.remove(t)
+		expired = append(expired, t)
+		if t.interval {
+			t.expire = w.current + int64((t.sleep+w.tick-1)/w.tick)
+			if t.expire <= w.current {
+				t.expire = w.current + 1
+			}
+			w.insert(t)
+			w.pending++
+		}
+		t = next
+	}
+	return expired
+}
@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTimingWheel(t *testing.T) {
+	t.Run("SetTimeout", func(t *testing.T) {
+		w := NewTimingWheel(time.Millisecond, 4)
+		fired := make(chan time.Duration, 3)
+		start := time.Now()
+		for _, sleep := range []time.Duration{5 * time.Millisecond, 30 * time.Millisecond, 90 * time.Millisecond} {
+			sleep := sleep
+			w.SetTimeout(func() {
+				fired <- sleep
+			}, sleep)
+		}
+
+		for _, want := range []time.Duration{5 * time.Millisecond, 30 * time.Millisecond, 90 * time.Millisecond} {
+			select {
+			case got := <-fired:
+				if got != want {
+					t.Fatalf("timer of %s fired, while expecting the timer of %s", got, want)
+				}
+				if elapsed := time.Since(start); elapsed < want {
+					t.Fatalf("timer of %s fired after %s", want, elapsed)
+				}
+			case <-time.After(time.Second):
+				t.Fatalf("timer of %s did not fire", want)
+			}
+		}
+		if n := w.Len(); n != 0 {
+			t.Fatalf("*TimingWheel.Len() = %d, want %d", n, 0)
+		}
+	})
+
+	t.Run("Stop", func(t *testing.T) {
+		w := NewTimingWheel(time.Millisecond, 4)
+		var fired int32
+		timer := w.SetTimeout(func() {
+			atomic.AddInt32(&fired, 1)
+		}, 10*time.Millisecond)
+
+		if !timer.Stop() || timer.Stop() {
+			t.Fatal("*WheelTimer.Stop() should stop a pending timer once")
+		}
+		if timer.Refresh(); w.Len() != 0 {
+			t.Fatal("a stopped timer should not be refreshed")
+		}
+		time.Sleep(30 * time.Millisecond)
+		if atomic.LoadInt32(&fired) != 0 {
+			t.Fatal("a stopped timer fired")
+		}
+		if (*WheelTimer)(nil).Stop() {
+			t.Fatal("*WheelTimer.Stop() on nil should be a no-op")
+		}
+	})
+
+	t.Run("StopExpired", func(t *testing.T) {
+		// the wheel does not tick, its time is advanced by hand
+		w := NewTimingWheel(time.Hour, 4)
+		var fired int32
+		timer := w.SetTimeout(func() {
+			atomic.AddInt32(&fired, 1)
+		}, 0)
+
+		w.mu.Lock()
+		w.start = w.start.Add(-time.Hour)
+		w.mu.Unlock()
+		expired, _ := w.collect()
+		if len(expired) != 1 {
+			t.Fatalf("*TimingWheel.collect() = %v, want match for the timer", expired)
+		}
+		if !timer.Stop() || timer.Stop() {
+			t.Fatal("*WheelTimer.Stop() should stop an expired timer whose fn is not called yet")
+		}
+		w.fire(expired[0])
+		if atomic.LoadInt32(&fired) != 0 {
+			t.Fatal("a stopped timer fired")
+		}
+
+		timer = w.SetTimeout(func() {
+			atomic.AddInt32(&fired, 1)
+		}, 0)
+		w.mu.Lock()
+		w.start = w.start.Add(-time.Hour)
+		w.mu.Unlock()
+		expired, _ = w.collect()
+		w.fire(expired[0])
+		if atomic.LoadInt32(&fired) != 1 || timer.Stop() {
+			t.Fatal("*WheelTimer.Stop() should report a fired timer")
+		}
+	})
+
+	t.Run("Refresh", func(t *testing.T) {
+		w := NewTimingWheel(time.Millisecond, 4)
+		fired := make(chan time.Time, 2)
+		timer := w.SetTimeout(func() {
+			fired <- time.Now()
+		}, 40*time.Millisecond)
+
+		time.Sleep(20 * time.Millisecond)
+		refreshed := time.Now()
+		timer.Refresh()
+
+		select {
+		case at := <-fired:
+			if at.Sub(refreshed) < 40*time.Millisecond {
+				t.Fatalf("refreshed timer fired %s after the refresh", at.Sub(refreshed))
+			}
+		case <-time.After(time.Second):
+			t.Fatal("refreshed timer did not fire")
+		}
+
+		timer.Refresh()
+		select {
+		case <-fired:
+		case <-time.After(time.Second):
+			t.Fatal("a fired timer should fire again once refreshed")
+		}
+	})
+
+	t.Run("SetInterval", func(t *testing.T) {
+		w := NewTimingWheel(time.Millisecond, 4)
+		var fired int32
+		timer := w.SetInterval(func() {
+			atomic.AddInt32(&fired, 1)
+		}, 5*time.Millisecond)
+
+		time.Sleep(60 * time.Millisecond)
+		timer.Stop()
+		n := atomic.LoadInt32(&fired)
+		if n < 3 {
+			t.Fatalf("interval fired %d times, want at least %d", n, 3)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if atomic.LoadInt32(&fired) != n {
+			t.Fatal("a stopped interval fired")
+		}
+	})
+}
+
+// Compares the goroutines and memory held by 10k pending timers, as many as
+// the heartbeats of 5k sockets.
+func BenchmarkPendingTimers(b *testing.B) {
+	const timers = 10000
+	noop := func() {}
+
+	b.Run("SetTimeOut", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			pending := make([]*Timer, timers)
+			for j := range pending {
+				pending[j] = SetTimeOut(noop, time.Hour)
+			}
+			b.ReportMetric(float64(runtime.NumGoroutine()), "goroutines")
+			for _, timer := range pending {
+				ClearTimeout(timer)
+			}
+		}
+	})
+
+	b.Run("TimingWheel", func(b *testing.B) {
+		b.ReportAllocs()
+		w := NewTimingWheel(10*time.Millisecond, 256)
+		for i := 0; i < b.N; i++ {
+			pending := make([]*WheelTimer, timers)
+			for j := range pending {
+				pending[j] = w.SetTimeout(noop, time.Hour)
+			}
+			b.ReportMetric(float64(runtime.NumGoroutine()), "goroutines")
+			for _, timer := range pending {
+				timer.Stop()
+			}
+		}
+	})
+}